@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"order-service/internal/cache"
+)
+
+func (a *app) cache(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("cache expects a subcommand: stats, reload or invalidate")
+	}
+
+	switch args[0] {
+	case "stats":
+		if err := parseFlags(newFlags("cache stats"), args[1:]); err != nil {
+			return err
+		}
+		var stats cache.Stats
+		if err := a.admin.do(ctx, http.MethodGet, "/admin/cache/stats", nil, &stats); err != nil {
+			return err
+		}
+		return a.out.fields(stats,
+			"Entries", fmt.Sprint(stats.Entries),
+			"Memory (approx.)", formatBytes(stats.ApproxBytes),
+			"Hits", fmt.Sprint(stats.Hits),
+			"Misses", fmt.Sprint(stats.Misses),
+			"Hit ratio", fmt.Sprintf("%.1f%%", stats.HitRatio*100),
+			"Version", fmt.Sprint(stats.Version),
+			"Last modified", formatTime(stats.LastModified),
+		)
+
+	case "reload":
+		if err := parseFlags(newFlags("cache reload"), args[1:]); err != nil {
+			return err
+		}
+		var result struct {
+			Entries int `json:"entries"`
+		}
+		if err := a.admin.do(ctx, http.MethodPost, "/admin/cache/reload", nil, &result); err != nil {
+			return err
+		}
+		return a.out.fields(result, "Entries", fmt.Sprint(result.Entries))
+
+	case "invalidate":
+		flags := newFlags("cache invalidate")
+		if err := parseFlags(flags, args[1:], "uid"); err != nil {
+			return err
+		}
+		uid := flags.Arg(0)
+		if err := a.admin.do(ctx, http.MethodDelete, "/admin/cache/"+url.PathEscape(uid), nil, nil); err != nil {
+			if isNotFound(err) {
+				return fmt.Errorf("order %s is not cached", uid)
+			}
+			return err
+		}
+		return a.out.fields(map[string]string{"invalidated": uid}, "Invalidated", uid)
+
+	default:
+		return fmt.Errorf("unknown cache subcommand %q", args[0])
+	}
+}
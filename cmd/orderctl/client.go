@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiClient calls the service's HTTP API with the configured credentials.
+type apiClient struct {
+	base   string
+	apiKey string
+	token  string
+	http   *http.Client
+}
+
+func newAPIClient(base, apiKey, token string) *apiClient {
+	return &apiClient{
+		base:   strings.TrimRight(base, "/"),
+		apiKey: apiKey,
+		token:  token,
+		http:   &http.Client{Timeout: time.Minute},
+	}
+}
+
+// do sends a request and decodes a JSON response into out, if out is not
+// nil. Responses other than 2xx are returned as errors carrying the
+// service's message.
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values, out interface{}) error {
+	u := c.base + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &apiError{Method: method, Path: path, Status: resp.StatusCode, Message: errorMessage(body)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: invalid response: %w", method, path, err)
+	}
+	return nil
+}
+
+// apiError is a response with a status other than 2xx.
+type apiError struct {
+	Method  string
+	Path    string
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.Path, e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// isNotFound reports whether err is a 404 response.
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// errorMessage extracts the message of a {"error": "..."} body, falling
+// back to the body itself.
+func errorMessage(body []byte) string {
+	var e struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error != "" {
+		return e.Error
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"order-service/internal/models"
+
+	"github.com/nats-io/stan.go"
+)
+
+func (a *app) dlq(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("dlq expects a subcommand: list or replay")
+	}
+	switch args[0] {
+	case "list":
+		return a.dlqList(ctx, args[1:])
+	case "replay":
+		return a.dlqReplay(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown dlq subcommand %q", args[0])
+	}
+}
+
+func (a *app) dlqList(ctx context.Context, args []string) error {
+	flags := newFlags("dlq list")
+	all := flags.Bool("all", false, "include letters that were already replayed")
+	limit := flags.Int("limit", 100, "maximum number of letters to show")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	repo, err := a.db()
+	if err != nil {
+		return err
+	}
+
+	letters, err := repo.DeadLetters(ctx, *all, *limit)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(letters))
+	for i, l := range letters {
+		replayed := "-"
+		if l.ReplayedAt != nil {
+			replayed = formatTime(*l.ReplayedAt)
+		}
+		rows[i] = []string{fmt.Sprint(l.ID), l.Subject, fmt.Sprint(l.Sequence), formatTime(l.CreatedAt), replayed, truncate(l.Error, 80)}
+	}
+	if letters == nil {
+		letters = []models.DeadLetter{}
+	}
+	return a.out.print(letters, []string{"ID", "SUBJECT", "SEQUENCE", "CREATED", "REPLAYED", "ERROR"}, rows)
+}
+
+// dlqReplay publishes dead letters to their original subject, so the
+// service processes them again with its current decoding rules. A letter
+// that fails again becomes a new dead letter.
+func (a *app) dlqReplay(ctx context.Context, args []string) error {
+	flags := newFlags("dlq replay")
+	all := flags.Bool("all", false, "replay every letter that has not been replayed yet")
+	limit := flags.Int("limit", 1000, "maximum number of letters replayed with -all")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: orderctl dlq replay (-all | <id>...)")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *all == (flags.NArg() > 0) {
+		flags.Usage()
+		return errors.New("dlq replay expects either -all or letter ids")
+	}
+	repo, err := a.db()
+	if err != nil {
+		return err
+	}
+
+	var letters []models.DeadLetter
+	if *all {
+		if letters, err = repo.DeadLetters(ctx, false, *limit); err != nil {
+			return err
+		}
+	}
+	for _, arg := range flags.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid letter id %q", arg)
+		}
+		letter, err := repo.GetDeadLetter(ctx, id)
+		if err != nil {
+			return err
+		}
+		if letter == nil {
+			return fmt.Errorf("dead letter %d not found", id)
+		}
+		letters = append(letters, *letter)
+	}
+	if len(letters) == 0 {
+		fmt.Fprintln(os.Stderr, "No dead letters to replay")
+		return nil
+	}
+
+	clientID := fmt.Sprintf("%s-orderctl-%d", a.cfg.NatsClientID, os.Getpid())
+	sc, err := stan.Connect(a.cfg.NatsCluster, clientID, stan.NatsURL(a.cfg.NatsURL))
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS Streaming: %w", err)
+	}
+	defer sc.Close()
+
+	rows := make([][]string, 0, len(letters))
+	replayed := make([]int64, 0, len(letters))
+	for _, l := range letters {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := sc.Publish(l.Subject, l.Data); err != nil {
+			return fmt.Errorf("failed to publish dead letter %d: %w", l.ID, err)
+		}
+		if err := repo.MarkDeadLetterReplayed(ctx, l.ID); err != nil {
+			return err
+		}
+		replayed = append(replayed, l.ID)
+		rows = append(rows, []string{fmt.Sprint(l.ID), l.Subject})
+	}
+	return a.out.print(map[string][]int64{"replayed": replayed}, []string{"REPLAYED", "SUBJECT"}, rows)
+}
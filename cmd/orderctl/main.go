@@ -0,0 +1,211 @@
+// Command orderctl administers the order service. It reads the same
+// environment configuration as the service and talks to its HTTP API or
+// directly to PostgreSQL and NATS Streaming.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"order-service/internal/config"
+	"order-service/internal/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const usage = `Usage: orderctl [flags] <command> [arguments]
+
+Commands:
+  get <uid>                 show one order
+  list                      list orders
+  validate <file>           check messages with the service's decoding rules
+  import <file>             store NDJSON orders in the database
+  export                    write orders from the database as NDJSON
+  cache stats               show the service's cache statistics
+  cache reload              reload the service's cache from the database
+  cache invalidate <uid>    drop one order from the service's cache
+  subscription status       show the state of the NATS subscription
+  subscription pause        stop taking messages from NATS
+  subscription resume       continue taking messages from NATS
+  subscription positions    show the consumer positions stored in the database
+  dlq list                  list messages that failed processing
+  dlq replay                publish dead letters to NATS again
+  migrate status            show which migrations are applied
+  reconcile                 compare the service's cache with the database
+  rebuild                   store orders replayed from the NATS channel
+
+Run "orderctl <command> -h" for the flags of a command.
+
+Flags:
+`
+
+// errDrift is returned by commands that found problems they already
+// reported, so only the exit status is left to set.
+var errDrift = errors.New("problems found")
+
+// app holds what every command needs: the configuration, the output format
+// and lazily opened connections.
+type app struct {
+	cfg    *config.Config
+	out    *printer
+	api    *apiClient
+	admin  *apiClient
+	source string
+
+	conn *sqlx.DB
+	repo *repository.OrderRepository
+}
+
+func main() {
+	cfg := config.Load()
+
+	flags := flag.NewFlagSet("orderctl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "table", "output format: table or json")
+	apiURL := flags.String("api", envOr("ORDERCTL_API", "http://localhost:"+cfg.HTTPPort), "base URL of the service's HTTP API")
+	adminURL := flags.String("admin", envOr("ORDERCTL_ADMIN", adminBase(cfg.AdminAddr)), "base URL of the service's admin listener")
+	apiKey := flags.String("api-key", os.Getenv("ORDERCTL_API_KEY"), "API key sent as X-API-Key")
+	token := flags.String("token", os.Getenv("ORDERCTL_TOKEN"), "JWT sent as a bearer token")
+	source := flags.String("source", "api", "where get and list read orders: api or db")
+	flags.Parse(os.Args[1:])
+
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		fail(err)
+	}
+	if *source != "api" && *source != "db" {
+		fail(fmt.Errorf("invalid -source %q, expected api or db", *source))
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	a := &app{
+		cfg:    cfg,
+		out:    out,
+		api:    newAPIClient(*apiURL, *apiKey, *token),
+		admin:  newAPIClient(*adminURL, *apiKey, *token),
+		source: *source,
+	}
+	defer a.close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := a.run(ctx, flags.Arg(0), flags.Args()[1:]); err != nil {
+		a.close()
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		if errors.Is(err, errDrift) {
+			os.Exit(1)
+		}
+		fail(err)
+	}
+}
+
+func (a *app) run(ctx context.Context, command string, args []string) error {
+	switch command {
+	case "get":
+		return a.get(ctx, args)
+	case "list":
+		return a.list(ctx, args)
+	case "validate":
+		return a.validate(ctx, args)
+	case "import":
+		return a.importOrders(ctx, args)
+	case "export":
+		return a.export(ctx, args)
+	case "cache":
+		return a.cache(ctx, args)
+	case "subscription":
+		return a.subscription(ctx, args)
+	case "dlq":
+		return a.dlq(ctx, args)
+	case "migrate":
+		return a.migrate(ctx, args)
+	case "reconcile":
+		return a.reconcile(ctx, args)
+	case "rebuild":
+		return a.rebuild(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q, run orderctl -h for a list", command)
+	}
+}
+
+// db connects to PostgreSQL on first use.
+func (a *app) db() (*repository.OrderRepository, error) {
+	if a.repo != nil {
+		return a.repo, nil
+	}
+	db, err := repository.NewPostgresDB(a.cfg.DBHost, a.cfg.DBPort, a.cfg.DBUser, a.cfg.DBPassword, a.cfg.DBName)
+	if err != nil {
+		return nil, err
+	}
+	a.conn = db
+	a.repo = repository.NewOrderRepository(db)
+	return a.repo, nil
+}
+
+func (a *app) close() {
+	if a.conn != nil {
+		a.conn.Close()
+		a.conn, a.repo = nil, nil
+	}
+}
+
+// newFlags returns the flag set of a subcommand.
+func newFlags(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// parseFlags parses the flags of a subcommand and checks the number of
+// positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string, positional ...string) error {
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: orderctl %s", flags.Name())
+		for _, p := range positional {
+			fmt.Fprintf(flags.Output(), " <%s>", p)
+		}
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != len(positional) {
+		flags.Usage()
+		return fmt.Errorf("%s expects %d argument(s), got %d", flags.Name(), len(positional), flags.NArg())
+	}
+	return nil
+}
+
+// adminBase returns the URL of the admin listener at addr. An address
+// without a host listens on every interface, so localhost reaches it.
+func adminBase(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "orderctl:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// migrationStatus is the state of one migration.
+type migrationStatus struct {
+	Version   string `json:"version"`
+	Status    string `json:"status"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+func (a *app) migrate(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "status" {
+		return fmt.Errorf("migrate expects a subcommand: status")
+	}
+	flags := newFlags("migrate status")
+	dir := flags.String("dir", "migrations", "directory with the migration files")
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(*dir, "*.sql"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no migrations found in %s", *dir)
+	}
+	repo, err := a.db()
+	if err != nil {
+		return err
+	}
+
+	applied, err := repo.AppliedMigrations(ctx)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "42P01" {
+		// schema_migrations is created by 006_schema_migrations.sql.
+		fmt.Fprintln(os.Stderr, "The database does not track migrations yet")
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var statuses []migrationStatus
+	pending := 0
+	for _, file := range files {
+		version := strings.TrimSuffix(filepath.Base(file), ".sql")
+		status := migrationStatus{Version: version, Status: "pending"}
+		if at, ok := applied[version]; ok {
+			status.Status = "applied"
+			status.AppliedAt = formatTime(at)
+			delete(applied, version)
+		} else {
+			pending++
+		}
+		statuses = append(statuses, status)
+	}
+	// Applied migrations without a file point at a checkout older than
+	// the database.
+	var unknown []string
+	for version := range applied {
+		unknown = append(unknown, version)
+	}
+	sort.Strings(unknown)
+	for _, version := range unknown {
+		statuses = append(statuses, migrationStatus{Version: version, Status: "no file", AppliedAt: formatTime(applied[version])})
+	}
+
+	rows := make([][]string, len(statuses))
+	for i, s := range statuses {
+		rows[i] = []string{s.Version, s.Status, s.AppliedAt}
+	}
+	if err := a.out.print(statuses, []string{"VERSION", "STATUS", "APPLIED_AT"}, rows); err != nil {
+		return err
+	}
+	if pending > 0 {
+		fmt.Fprintf(os.Stderr, "%d migration(s) pending, run make migrate\n", pending)
+		return errDrift
+	}
+	return nil
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/nats"
+	"order-service/internal/repository"
+)
+
+// errLimit stops a stream once enough orders were read.
+var errLimit = errors.New("limit reached")
+
+func (a *app) get(ctx context.Context, args []string) error {
+	flags := newFlags("get")
+	if err := parseFlags(flags, args, "uid"); err != nil {
+		return err
+	}
+	uid := flags.Arg(0)
+
+	var order *models.Order
+	if a.source == "db" {
+		repo, err := a.db()
+		if err != nil {
+			return err
+		}
+		if order, err = repo.GetOrder(ctx, uid); err != nil {
+			return err
+		}
+	} else {
+		order = new(models.Order)
+		err := a.api.do(ctx, http.MethodGet, "/api/orders/"+url.PathEscape(uid), nil, order)
+		if isNotFound(err) {
+			order = nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if order == nil {
+		return fmt.Errorf("order %s not found", uid)
+	}
+
+	return a.out.fields(order,
+		"Order UID", order.OrderUID,
+		"Track number", order.TrackNumber,
+		"Entry", order.Entry,
+		"Created", formatTime(order.DateCreated),
+		"Customer", order.CustomerID,
+		"Delivery service", order.DeliveryService,
+		"Recipient", order.Delivery.Name+", "+order.Delivery.City,
+		"Payment", fmt.Sprintf("%s via %s (%s)", order.Payment.Amount,
+			order.Payment.Provider, order.Payment.Transaction),
+		"Items", fmt.Sprint(len(order.Items)),
+	)
+}
+
+// filterFlags registers the order filter flags shared by list and export.
+func filterFlags(flags *flag.FlagSet) func() (models.OrderFilter, error) {
+	customer := flags.String("customer", "", "only orders of this customer_id")
+	delivery := flags.String("delivery-service", "", "only orders of this delivery service")
+	entry := flags.String("entry", "", "only orders with this entry")
+	currency := flags.String("currency", "", "only orders paid in this currency")
+	from := flags.String("from", "", "only orders created at or after this date (YYYY-MM-DD or RFC 3339)")
+	to := flags.String("to", "", "only orders created up to this date (YYYY-MM-DD, inclusive, or RFC 3339)")
+
+	return func() (models.OrderFilter, error) {
+		f := models.OrderFilter{CustomerID: *customer, DeliveryService: *delivery, Entry: *entry, Currency: *currency}
+		var err error
+		if f.From, _, err = parseDate(*from); err != nil {
+			return f, fmt.Errorf("invalid -from: %w", err)
+		}
+		var dateOnly bool
+		if f.To, dateOnly, err = parseDate(*to); err != nil {
+			return f, fmt.Errorf("invalid -to: %w", err)
+		}
+		if dateOnly {
+			f.To = f.To.AddDate(0, 0, 1)
+		}
+		return f, nil
+	}
+}
+
+// parseDate accepts the same dates as the API: RFC 3339 or YYYY-MM-DD. A
+// bare "to" date includes that day.
+func parseDate(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	return t, true, err
+}
+
+// filterQuery encodes a filter as the API's query parameters.
+func filterQuery(f models.OrderFilter) url.Values {
+	q := url.Values{}
+	for key, value := range map[string]string{
+		"customer_id":      f.CustomerID,
+		"delivery_service": f.DeliveryService,
+		"entry":            f.Entry,
+		"currency":         f.Currency,
+	} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	if !f.From.IsZero() {
+		q.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		q.Set("to", f.To.Format(time.RFC3339))
+	}
+	return q
+}
+
+func (a *app) list(ctx context.Context, args []string) error {
+	flags := newFlags("list")
+	filter := filterFlags(flags)
+	limit := flags.Int("limit", 50, "maximum number of orders to show, 0 for all")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+
+	var orders []models.Order
+	if a.source == "db" {
+		repo, err := a.db()
+		if err != nil {
+			return err
+		}
+		err = repo.StreamOrders(ctx, f, func(order *models.Order) error {
+			if *limit > 0 && len(orders) == *limit {
+				return errLimit
+			}
+			orders = append(orders, *order)
+			return nil
+		})
+		if err != nil && !errors.Is(err, errLimit) {
+			return err
+		}
+	} else {
+		if err := a.api.do(ctx, http.MethodGet, "/api/orders", filterQuery(f), &orders); err != nil {
+			return err
+		}
+		if *limit > 0 && len(orders) > *limit {
+			orders = orders[:*limit]
+		}
+	}
+
+	rows := make([][]string, len(orders))
+	for i := range orders {
+		rows[i] = orderRow(&orders[i])
+	}
+	return a.out.print(orders, orderHeader, rows)
+}
+
+// validationResult is the outcome of checking one message.
+type validationResult struct {
+	Message  int    `json:"message"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (a *app) validate(ctx context.Context, args []string) error {
+	flags := newFlags("validate")
+	if err := parseFlags(flags, args, "file"); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	messages, err := splitMessages(data)
+	if err != nil {
+		return err
+	}
+
+	results := make([]validationResult, len(messages))
+	rows := make([][]string, len(messages))
+	invalid := 0
+	for i, msg := range messages {
+		results[i].Message = i + 1
+		order, err := nats.DecodeMessage(msg)
+		if err == nil {
+			results[i].OrderUID = order.OrderUID
+			err = nats.Validate(ctx, order)
+		}
+		status := "ok"
+		if err != nil {
+			invalid++
+			results[i].Error = err.Error()
+			status = err.Error()
+		}
+		rows[i] = []string{fmt.Sprint(i + 1), results[i].OrderUID, status}
+	}
+
+	if err := a.out.print(results, []string{"MESSAGE", "ORDER_UID", "RESULT"}, rows); err != nil {
+		return err
+	}
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d messages are invalid\n", invalid, len(messages))
+		return errDrift
+	}
+	return nil
+}
+
+// splitMessages returns the messages in a file: a protobuf envelope, or
+// one or more JSON documents such as an NDJSON file.
+func splitMessages(data []byte) ([][]byte, error) {
+	if nats.DetectFormat(data) == nats.FormatProtobuf {
+		return [][]byte{data}, nil
+	}
+
+	var messages [][]byte
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var msg json.RawMessage
+		err := dec.Decode(&msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("message %d is not valid JSON: %w", len(messages)+1, err)
+		}
+		messages = append(messages, msg)
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("the file contains no messages")
+	}
+	return messages, nil
+}
+
+func (a *app) importOrders(ctx context.Context, args []string) error {
+	flags := newFlags("import")
+	batchSize := flags.Int("batch", 500, "orders written per transaction")
+	if err := parseFlags(flags, args, "file"); err != nil {
+		return err
+	}
+	if *batchSize < 1 || *batchSize > repository.MaxBatchSize {
+		return fmt.Errorf("-batch must be between 1 and %d", repository.MaxBatchSize)
+	}
+
+	in, err := openInput(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	repo, err := a.db()
+	if err != nil {
+		return err
+	}
+
+	var (
+		batch                        []*models.Order
+		read, written, existing, bad int
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		stored, err := repo.SaveOrders(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to store orders %d-%d: %w", written+existing+bad+1, read, err)
+		}
+		for _, ok := range stored {
+			if ok {
+				written++
+			} else {
+				existing++
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		read++
+		order, err := nats.DecodeMessage(line)
+		if err == nil {
+			err = nats.Validate(ctx, order)
+		}
+		if err != nil {
+			bad++
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", read, err)
+			continue
+		}
+		if batch = append(batch, order); len(batch) == *batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	// Orders that were already stored are left untouched by SaveOrders
+	summary := map[string]int{"read": read, "written": written, "existing": existing, "invalid": bad}
+	if err := a.out.fields(summary, "Read", fmt.Sprint(read), "Written", fmt.Sprint(written),
+		"Existing", fmt.Sprint(existing), "Invalid", fmt.Sprint(bad)); err != nil {
+		return err
+	}
+	if written > 0 {
+		fmt.Fprintln(os.Stderr, `Run "orderctl cache reload" to make the running service serve them`)
+	}
+	if bad > 0 {
+		return errDrift
+	}
+	return nil
+}
+
+func (a *app) export(ctx context.Context, args []string) error {
+	flags := newFlags("export")
+	filter := filterFlags(flags)
+	output := flags.String("out", "-", `file to write, "-" for stdout; a .gz name is compressed`)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	f, err := filter()
+	if err != nil {
+		return err
+	}
+	repo, err := a.db()
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	count := 0
+	enc := json.NewEncoder(out)
+	err = repo.StreamOrders(ctx, f, func(order *models.Order) error {
+		count++
+		return enc.Encode(order)
+	})
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d orders\n", count)
+	return nil
+}
+
+// openInput opens a file for reading, decompressing .gz files.
+func openInput(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return readCloser{gz, f}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	file *os.File
+}
+
+func (r readCloser) Close() error { return r.file.Close() }
+
+// createOutput creates a file for writing, compressing .gz files. "-" is
+// stdout.
+func createOutput(name string) (io.WriteCloser, error) {
+	if name == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+	return gzipFile{gzip.NewWriter(f), f}, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (g gzipFile) Close() error {
+	err := g.Writer.Close()
+	if closeErr := g.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
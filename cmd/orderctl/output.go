@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// printer writes command results as aligned tables or as indented JSON.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "table":
+		return &printer{w: w}, nil
+	case "json":
+		return &printer{w: w, json: true}, nil
+	default:
+		return nil, fmt.Errorf("invalid output format %q, expected table or json", format)
+	}
+}
+
+// print writes v as JSON, or the rows under header as a table.
+func (p *printer) print(v interface{}, header []string, rows [][]string) error {
+	if p.json {
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	if header != nil {
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// fields prints name/value pairs, one per line.
+func (p *printer) fields(v interface{}, pairs ...string) error {
+	rows := make([][]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		rows = append(rows, []string{pairs[i] + ":", pairs[i+1]})
+	}
+	return p.print(v, nil, rows)
+}
+
+var orderHeader = []string{"ORDER_UID", "DATE_CREATED", "CUSTOMER", "DELIVERY", "ITEMS", "AMOUNT"}
+
+func orderRow(o *models.Order) []string {
+	return []string{
+		o.OrderUID,
+		formatTime(o.DateCreated),
+		o.CustomerID,
+		o.DeliveryService,
+		fmt.Sprint(len(o.Items)),
+		o.Payment.Amount.String(),
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// truncate shortens s to at most n runes for table cells.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// formatBytes writes a size with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"order-service/internal/nats"
+	"order-service/internal/repository"
+
+	"github.com/nats-io/stan.go"
+)
+
+// rebuild replays the NATS channel into the database with a subscription of
+// its own, so orders lost from the database can be restored although the
+// service's durable subscription has acknowledged them long ago.
+func (a *app) rebuild(ctx context.Context, args []string) error {
+	flags := newFlags("rebuild")
+	all := flags.Bool("all", false, "replay every message the channel still holds")
+	seq := flags.Uint64("seq", 0, "replay from this sequence number")
+	since := flags.String("since", "", "replay from this time: YYYY-MM-DD, RFC 3339 or a duration such as 24h")
+	subject := flags.String("subject", a.cfg.NatsSubject, "channel to replay")
+	dbName := flags.String("db", a.cfg.DBName, "database to write to")
+	dryRun := flags.Bool("dry-run", false, "only count the orders that would be written")
+	batchSize := flags.Int("batch", 500, "orders written per transaction")
+	idle := flags.Duration("idle", 5*time.Second, "stop once no message arrived for this long")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *batchSize < 1 || *batchSize > repository.MaxBatchSize {
+		return fmt.Errorf("-batch must be between 1 and %d", repository.MaxBatchSize)
+	}
+	if *idle <= 0 {
+		return errors.New("-idle must be positive")
+	}
+
+	opts := nats.RebuildOptions{Subject: *subject, StartSequence: *seq, DryRun: *dryRun, BatchSize: *batchSize, Idle: *idle}
+	positions := 0
+	if *all {
+		positions++
+	}
+	if *seq > 0 {
+		positions++
+	}
+	if *since != "" {
+		positions++
+		start, err := parseSince(*since)
+		if err != nil {
+			return err
+		}
+		opts.StartTime = start
+	}
+	if positions != 1 {
+		flags.Usage()
+		return errors.New("rebuild expects exactly one of -all, -seq or -since")
+	}
+
+	// The target may differ from the service's database, so it gets its
+	// own connection rather than a.db()
+	db, err := repository.NewPostgresDB(a.cfg.DBHost, a.cfg.DBPort, a.cfg.DBUser, a.cfg.DBPassword, *dbName)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	clientID := fmt.Sprintf("%s-rebuild-%d", a.cfg.NatsClientID, os.Getpid())
+	sc, err := stan.Connect(a.cfg.NatsCluster, clientID, stan.NatsURL(a.cfg.NatsURL))
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS Streaming: %w", err)
+	}
+	defer sc.Close()
+
+	var reported time.Time
+	opts.Progress = func(p nats.RebuildProgress) {
+		if time.Since(reported) < time.Second {
+			return
+		}
+		reported = time.Now()
+		fmt.Fprintf(os.Stderr, "Sequence %d: %d received, %d new, %d existing, %d invalid\n",
+			p.LastSequence, p.Received, p.New, p.Existing, p.Invalid)
+	}
+
+	progress, err := nats.Rebuild(ctx, sc, repository.NewOrderRepository(db), opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Stopped after sequence %d\n", progress.LastSequence)
+		return err
+	}
+
+	newLabel := "Written"
+	if *dryRun {
+		newLabel = "Would write"
+	}
+	if err := a.out.fields(progress,
+		"Sequences", fmt.Sprintf("%d-%d", progress.FirstSequence, progress.LastSequence),
+		"Received", fmt.Sprint(progress.Received),
+		newLabel, fmt.Sprint(progress.New),
+		"Existing", fmt.Sprint(progress.Existing),
+		"Invalid", fmt.Sprint(progress.Invalid),
+	); err != nil {
+		return err
+	}
+	if progress.Received == 0 {
+		fmt.Fprintln(os.Stderr, "No messages were received from that position")
+	} else if progress.New > 0 && !*dryRun && *dbName == a.cfg.DBName {
+		fmt.Fprintln(os.Stderr, `Run "orderctl cache reload" to make the running service serve them`)
+	}
+	return nil
+}
+
+// parseSince accepts a date as parseDate does, or a duration back from now.
+func parseSince(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, _, err := parseDate(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -since %q, expected a date or a duration", value)
+	}
+	return t, nil
+}
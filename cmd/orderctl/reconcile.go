@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"order-service/internal/reconcile"
+)
+
+// reconcile asks the service to compare its cache with the database, or
+// shows the result of the latest periodic run.
+func (a *app) reconcile(ctx context.Context, args []string) error {
+	flags := newFlags("reconcile")
+	repair := flags.Bool("repair", false, "correct the cache from the database")
+	last := flags.Bool("last", false, "show the latest report instead of running")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *repair && *last {
+		return fmt.Errorf("-repair and -last cannot be combined")
+	}
+
+	var report reconcile.Report
+	var err error
+	if *last {
+		err = a.admin.do(ctx, http.MethodGet, "/admin/reconcile", nil, &report)
+	} else {
+		query := url.Values{}
+		if *repair {
+			query.Set("repair", "true")
+		}
+		err = a.admin.do(ctx, http.MethodPost, "/admin/reconcile", query, &report)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(report.Drifts))
+	for i, d := range report.Drifts {
+		repaired := "no"
+		if d.Repaired {
+			repaired = "yes"
+		}
+		rows[i] = []string{d.OrderUID, d.Problem, orDash(d.CacheHash), orDash(d.DBHash), repaired}
+	}
+	if err := a.out.print(report, []string{"ORDER_UID", "PROBLEM", "CACHE_HASH", "DB_HASH", "REPAIRED"}, rows); err != nil {
+		return err
+	}
+
+	if report.Error != "" {
+		return fmt.Errorf("reconciliation failed: %s", report.Error)
+	}
+	fmt.Fprintf(os.Stderr, "Checked %d stored and %d cached orders since %s: %d missing in cache, %d missing in DB, %d differing, %d repaired\n",
+		report.Stored, report.Cached, formatTime(report.Since),
+		report.MissingInCache, report.MissingInDB, report.Differing, report.Repaired)
+	if report.Truncated {
+		fmt.Fprintf(os.Stderr, "Only the first %d drifts are listed\n", len(report.Drifts))
+	}
+	if report.Drifted() > report.Repaired {
+		return errDrift
+	}
+	return nil
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"order-service/internal/models"
+	"order-service/internal/nats"
+)
+
+func (a *app) subscription(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("subscription expects a subcommand: status, pause, resume or positions")
+	}
+	if args[0] == "positions" {
+		return a.subscriptionPositions(ctx, args[1:])
+	}
+
+	method, path := http.MethodGet, "/admin/subscription"
+	switch args[0] {
+	case "status":
+	case "pause", "resume":
+		method, path = http.MethodPost, path+"/"+args[0]
+	default:
+		return fmt.Errorf("unknown subscription subcommand %q", args[0])
+	}
+	if err := parseFlags(newFlags("subscription "+args[0]), args[1:]); err != nil {
+		return err
+	}
+
+	var state nats.SubscriptionState
+	if err := a.admin.do(ctx, method, path, nil, &state); err != nil {
+		return err
+	}
+	status := "active"
+	if state.Paused {
+		status = "paused"
+		if state.PausedAt != nil {
+			status += " since " + formatTime(*state.PausedAt)
+		}
+	}
+	durable := state.Durable
+	if durable == "" {
+		durable = "-"
+	}
+	return a.out.fields(state,
+		"Subject", state.Subject,
+		"Start from", state.StartFrom,
+		"Durable", durable,
+		"Connected", fmt.Sprint(state.Connected),
+		"Status", status,
+		"Workers", fmt.Sprint(state.Workers),
+		"Received", fmt.Sprint(state.Received),
+		"Acked", fmt.Sprint(state.Acked),
+		"Failed", fmt.Sprint(state.Failed),
+		"Dead lettered", fmt.Sprint(state.DeadLettered),
+		"Skipped", fmt.Sprint(state.Skipped),
+		"Last sequence", fmt.Sprint(state.LastSequence),
+		"Position", fmt.Sprint(state.Position),
+	)
+}
+
+// subscriptionPositions shows the consumer positions stored in the
+// database, which are readable while the service is down.
+func (a *app) subscriptionPositions(ctx context.Context, args []string) error {
+	if err := parseFlags(newFlags("subscription positions"), args); err != nil {
+		return err
+	}
+	repo, err := a.db()
+	if err != nil {
+		return err
+	}
+
+	positions, err := repo.ConsumerPositions(ctx)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(positions))
+	for i, p := range positions {
+		rows[i] = []string{p.Subject, fmt.Sprint(p.Sequence), formatTime(p.UpdatedAt)}
+	}
+	if positions == nil {
+		positions = []models.ConsumerPosition{}
+	}
+	return a.out.print(positions, []string{"SUBJECT", "SEQUENCE", "UPDATED"}, rows)
+}
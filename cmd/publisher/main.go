@@ -1,17 +1,27 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"math/rand"
+	"os"
 	"time"
 
+	"order-service/internal/config"
 	"order-service/internal/models"
+	"order-service/internal/money"
+	"order-service/internal/nats"
+	"order-service/internal/tracing"
 
 	"github.com/nats-io/stan.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("publisher")
+
 const (
 	natsURL      = "nats://localhost:4222"
 	natsCluster  = "test-cluster"
@@ -40,7 +50,7 @@ var (
 	products = []struct {
 		name  string
 		brand string
-		price int
+		price int64 // major units of the order currency
 	}{
 		{"Mascaras", "Vivienne Sabo", 453},
 		{"Lipstick", "MAC", 890},
@@ -64,40 +74,70 @@ var (
 )
 
 func main() {
-	log.Println("Starting NATS Publisher...")
+	formatName := flag.String("format", string(nats.FormatJSON), "message format: json or protobuf")
+	flag.Parse()
+
+	format, err := nats.ParseFormat(*formatName)
+	if err != nil {
+		slog.Error("Invalid --format", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("Starting NATS Publisher", "format", format)
+
+	cfg := config.Load()
+	shutdownTracing, err := tracing.Setup("order-publisher", cfg.TracingExporter, cfg.TracingFile)
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
 	rand.Seed(time.Now().UnixNano())
 
 	// Connect to NATS Streaming
 	sc, err := stan.Connect(natsCluster, natsClientID, stan.NatsURL(natsURL))
 	if err != nil {
-		log.Fatalf("Failed to connect to NATS Streaming: %v", err)
+		slog.Error("Failed to connect to NATS Streaming", "error", err)
+		os.Exit(1)
 	}
 	defer sc.Close()
 
-	log.Println("Connected to NATS Streaming")
+	slog.Info("Connected to NATS Streaming")
 
 	// Generate and publish sample orders
 	numOrders := 40
 	for i := 1; i <= numOrders; i++ {
 		order := generateSampleOrder(i)
-
-		data, err := json.Marshal(order)
-		if err != nil {
-			log.Printf("Failed to marshal order: %v", err)
+		if err := publish(sc, &order, format); err != nil {
+			slog.Error("Failed to publish order", "order_uid", order.OrderUID, "error", err)
 			continue
 		}
 
-		err = sc.Publish(natsSubject, data)
-		if err != nil {
-			log.Printf("Failed to publish order: %v", err)
-			continue
-		}
-
-		log.Printf("Published order: %s (Customer: %s)", order.OrderUID, order.Delivery.Name)
+		slog.Info("Published order", "order_uid", order.OrderUID, "customer_id", order.CustomerID)
 		time.Sleep(300 * time.Millisecond)
 	}
 
-	log.Printf("All %d orders published successfully", numOrders)
+	slog.Info("All orders published", "count", numOrders)
+}
+
+// publish sends order in an envelope carrying the context of a new
+// producer span, so the service's processing joins the same trace.
+func publish(sc stan.Conn, order *models.Order, format nats.Format) (err error) {
+	ctx, span := tracer.Start(context.Background(), "orders publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "stan"),
+			attribute.String("messaging.destination.name", natsSubject),
+			attribute.String("messaging.message.format", string(format)),
+			attribute.String("order.uid", order.OrderUID),
+		))
+	defer func() { tracing.End(span, err) }()
+
+	data, err := nats.EncodeOrder(ctx, order, format, natsClientID)
+	if err != nil {
+		return err
+	}
+	return sc.Publish(natsSubject, data)
 }
 
 func generateSampleOrder(num int) models.Order {
@@ -117,23 +157,29 @@ func generateSampleOrder(num int) models.Order {
 	// Generate random items (1-4 items per order)
 	numItems := rand.Intn(4) + 1
 	items := make([]models.Item, numItems)
-	totalAmount := 0
-	goodsTotal := 0
+	var totalAmount, goodsTotal money.Amount
+
+	// Amounts are published in minor units of the order currency
+	minor := func(major int64) money.Amount {
+		m, _ := money.FromMajor(major, currency)
+		return m.Amount
+	}
 
 	for j := 0; j < numItems; j++ {
 		product := products[rand.Intn(len(products))]
 		sale := rand.Intn(50) + 10 // 10-60% sale
-		totalPrice := product.price * (100 - sale) / 100
+		price := minor(product.price)
+		totalPrice := price * money.Amount(100-sale) / 100
 
 		items[j] = models.Item{
 			ChrtID:      9934930 + num*100 + j,
 			TrackNumber: trackNumber,
-			Price:       product.price,
+			Price:       money.New(price, currency),
 			Rid:         fmt.Sprintf("ab4219087a764ae0btest%d%d", num, j),
 			Name:        product.name,
 			Sale:        sale,
 			Size:        fmt.Sprintf("%d", rand.Intn(10)),
-			TotalPrice:  totalPrice,
+			TotalPrice:  money.New(totalPrice, currency),
 			NmID:        2389212 + num*10 + j,
 			Brand:       product.brand,
 			Status:      202,
@@ -141,7 +187,7 @@ func generateSampleOrder(num int) models.Order {
 		goodsTotal += totalPrice
 	}
 
-	deliveryCost := rand.Intn(1000) + 500 // 500-1500
+	deliveryCost := minor(int64(rand.Intn(1000) + 500)) // 500-1500
 	totalAmount = goodsTotal + deliveryCost
 
 	// Generate phone number
@@ -172,12 +218,12 @@ func generateSampleOrder(num int) models.Order {
 			RequestID:    "",
 			Currency:     currency,
 			Provider:     provider,
-			Amount:       totalAmount,
+			Amount:       money.New(totalAmount, currency),
 			PaymentDt:    time.Now().Add(-time.Duration(rand.Intn(720)) * time.Hour).Unix(), // Random time in last 30 days
 			Bank:         bank,
-			DeliveryCost: deliveryCost,
-			GoodsTotal:   goodsTotal,
-			CustomFee:    rand.Intn(300),
+			DeliveryCost: money.New(deliveryCost, currency),
+			GoodsTotal:   money.New(goodsTotal, currency),
+			CustomFee:    money.New(minor(int64(rand.Intn(300))), currency),
 		},
 		Items:             items,
 		Locale:            []string{"en", "ru"}[rand.Intn(2)],
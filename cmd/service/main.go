@@ -2,102 +2,296 @@ package main
 
 import (
 	"context"
-	"log"
+	"expvar"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"order-service/internal/auth"
 	"order-service/internal/cache"
+	"order-service/internal/config"
+	"order-service/internal/events"
+	grpcserver "order-service/internal/grpc"
 	httpserver "order-service/internal/http"
+	"order-service/internal/logging"
+	"order-service/internal/masking"
+	"order-service/internal/models"
+	"order-service/internal/money"
 	"order-service/internal/nats"
+	"order-service/internal/outbox"
+	"order-service/internal/ratelimit"
+	"order-service/internal/reconcile"
 	"order-service/internal/repository"
-)
-
-const (
-	// Database configuration
-	dbHost     = "localhost"
-	dbPort     = "5432"
-	dbUser     = "orderuser"
-	dbPassword = "orderpass"
-	dbName     = "ordersdb"
-
-	// NATS Streaming configuration
-	natsURL      = "nats://localhost:4222"
-	natsCluster  = "test-cluster"
-	natsClientID = "order-service"
-	natsSubject  = "orders"
-
-	// HTTP server configuration
-	httpPort = "8080"
+	"order-service/internal/retention"
+	"order-service/internal/tracing"
+	"order-service/internal/webhook"
 )
 
 func main() {
-	log.Println("Starting Order Service...")
+	cfg := config.Load()
+
+	if err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
+	}
+	slog.Info("Starting Order Service")
+
+	shutdownTracing, err := tracing.Setup("order-service", cfg.TracingExporter, cfg.TracingFile)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// Connect to PostgreSQL
-	log.Println("Connecting to PostgreSQL...")
-	db, err := repository.NewPostgresDB(dbHost, dbPort, dbUser, dbPassword, dbName)
+	slog.Info("Connecting to PostgreSQL", "host", cfg.DBHost, "port", cfg.DBPort, "database", cfg.DBName)
+	db, err := repository.NewPostgresDB(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 	defer db.Close()
-	log.Println("Successfully connected to PostgreSQL")
+	slog.Info("Connected to PostgreSQL")
 
 	// Initialize repository
 	repo := repository.NewOrderRepository(db)
 
+	// Create upcoming partitions and archive the ones past retention
+	archiver := retention.NewArchiver(repo, retention.Config{
+		Dir:           cfg.ArchiveDir,
+		RetainMonths:  cfg.RetentionMonths,
+		PremakeMonths: cfg.PartitionPremakeMonths,
+		Interval:      cfg.RetentionInterval,
+	})
+	retentionCtx, stopRetention := context.WithCancel(context.Background())
+	defer stopRetention()
+	go archiver.Run(retentionCtx)
+
 	// Initialize cache
 	orderCache := cache.NewOrderCache()
 
-	// Restore cache from database
+	// Publish every stored order to the live feed
+	broker := events.NewBroker(cfg.EventsHistory, cfg.EventsBuffer)
+	orderCache.OnSet(func(order *models.Order) {
+		broker.PublishOrder(order)
+	})
+
+	// Restore recent orders from database
 	ctx := context.Background()
-	if err := orderCache.RestoreFromDB(ctx, repo); err != nil {
-		log.Printf("Warning: Failed to restore cache from DB: %v", err)
+	if err := orderCache.RestoreFromDB(ctx, repo, restoreSince(cfg.CacheRestoreWindow)); err != nil {
+		slog.Warn("Failed to restore cache from DB", "error", err)
+	}
+
+	// Compare the cache with the database in the background
+	reconciler := reconcile.New(orderCache, repo, reconcile.Config{
+		Window:   cfg.CacheRestoreWindow,
+		Interval: cfg.ReconcileInterval,
+		Repair:   cfg.ReconcileRepair,
+	})
+	expvar.Publish("reconcile", expvar.Func(func() interface{} { return reconciler.Metrics() }))
+	expvar.Publish("cache", expvar.Func(func() interface{} { return orderCache.Stats() }))
+	reconcileCtx, stopReconciler := context.WithCancel(context.Background())
+	defer stopReconciler()
+	go reconciler.Run(reconcileCtx)
+
+	// Orders from NATS and gRPC go through the same pipeline
+	if cfg.NatsBatchSize > repository.MaxBatchSize {
+		fatal("Invalid NATS_BATCH_SIZE", fmt.Errorf("at most %d orders fit in one batch", repository.MaxBatchSize))
+	}
+	pipeline := nats.NewPipeline(repo, orderCache)
+	startFrom, err := nats.ParseStartPosition(cfg.NatsStartFrom)
+	if err != nil {
+		fatal("Invalid NATS_START_FROM", err)
 	}
 
 	// Connect to NATS Streaming with retry
 	var subscriber *nats.Subscriber
 	for i := 0; i < 10; i++ {
-		subscriber, err = nats.NewSubscriber(natsURL, natsCluster, natsClientID, repo, orderCache)
+		subscriber, err = nats.NewSubscriber(cfg.NatsURL, cfg.NatsCluster, cfg.NatsClientID, pipeline,
+			nats.WithConcurrency(cfg.NatsWorkers, cfg.NatsMaxInflight),
+			nats.WithBatching(cfg.NatsBatchSize, cfg.NatsBatchLinger),
+			nats.WithDeadLetters(repo),
+			nats.WithStoredPosition(repo, startFrom))
 		if err == nil {
 			break
 		}
-		log.Printf("Failed to connect to NATS Streaming (attempt %d/10): %v", i+1, err)
+		slog.Warn("Failed to connect to NATS Streaming", "attempt", i+1, "of", 10, "error", err)
 		time.Sleep(2 * time.Second)
 	}
 
+	var relay *outbox.Relay
 	if err != nil {
-		log.Printf("Warning: Could not connect to NATS Streaming after 10 attempts: %v", err)
-		log.Println("Service will start without NATS subscription")
+		slog.Warn("Could not connect to NATS Streaming, starting without subscription", "error", err)
 	} else {
 		defer subscriber.Close()
-		log.Println("Successfully connected to NATS Streaming")
+		slog.Info("Connected to NATS Streaming", "url", cfg.NatsURL)
 
 		// Subscribe to orders channel
-		if err := subscriber.Subscribe(natsSubject); err != nil {
-			log.Fatalf("Failed to subscribe to NATS subject: %v", err)
+		if err := subscriber.Subscribe(cfg.NatsSubject); err != nil {
+			fatal("Failed to subscribe to NATS subject", err)
+		}
+
+		// Publish stored orders downstream
+		subjects, err := outbox.ParseSubjects(cfg.OutboxSubjects)
+		if err != nil {
+			fatal("Invalid OUTBOX_SUBJECTS", err)
+		}
+		relay = outbox.NewRelay(repo, subscriber, outbox.Config{
+			Subjects:    subjects,
+			Producer:    cfg.NatsClientID,
+			Interval:    cfg.OutboxInterval,
+			BatchSize:   cfg.OutboxBatchSize,
+			MaxAttempts: cfg.OutboxMaxAttempts,
+		})
+		relayCtx, stopRelay := context.WithCancel(context.Background())
+		defer stopRelay()
+		go relay.Run(relayCtx)
+	}
+
+	// Deliver order events to webhook subscribers
+	dispatcher := webhook.NewDispatcher(repo, nil, webhook.Config{
+		Timeout:     cfg.WebhookTimeout,
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		BackoffBase: cfg.WebhookBackoffBase,
+		BackoffMax:  cfg.WebhookBackoffMax,
+	})
+	dispatchCtx, stopDispatcher := context.WithCancel(context.Background())
+	defer stopDispatcher()
+	go dispatcher.Run(dispatchCtx)
+
+	// Load exchange rates for the reporting currency
+	rates := money.NewRateTable()
+	if cfg.RatesFile != "" {
+		loaded, err := money.LoadRatesFile(cfg.RatesFile)
+		if err != nil {
+			slog.Warn("Failed to load exchange rates", "file", cfg.RatesFile, "error", err)
+		} else {
+			rates = loaded
+			slog.Info("Loaded exchange rates", "count", len(rates.All()), "file", cfg.RatesFile)
+		}
+	}
+
+	// Load PII masking policy
+	policy := masking.DefaultPolicy()
+	if cfg.MaskingPolicyFile != "" {
+		loaded, err := masking.LoadPolicyFile(cfg.MaskingPolicyFile)
+		if err != nil {
+			fatal("Failed to load masking policy", err)
 		}
+		policy = loaded
+	}
+
+	opts := []httpserver.Option{
+		httpserver.WithReporting(rates, cfg.ReportingCurrency),
+		httpserver.WithMasking(policy, masking.Role(cfg.MaskingDefaultRole)),
+		httpserver.WithRateLimits(httpserver.RateLimits{
+			Default: ratelimit.Limit{Rate: cfg.RateLimitRPS, Burst: cfg.RateLimitBurst},
+			Routes: map[string]ratelimit.Limit{
+				httpserver.RouteOrdersList:   {Rate: cfg.RateLimitListRPS, Burst: cfg.RateLimitListBurst},
+				httpserver.RouteOrdersExport: {Rate: cfg.RateLimitListRPS, Burst: cfg.RateLimitListBurst},
+			},
+			PerIP:       ratelimit.Limit{Rate: cfg.RateLimitIPRPS, Burst: cfg.RateLimitIPBurst},
+			MaxInFlight: cfg.MaxInFlight,
+		}),
+		httpserver.WithExport(repo),
+		httpserver.WithEvents(broker),
+		httpserver.WithWebhooks(repo),
+		httpserver.WithCacheControl(cacheControl{orderCache, repo, cfg.CacheRestoreWindow}),
+		httpserver.WithReconciler(reconciler),
+		httpserver.WithRuntimeConfig(cfg.Redacted()),
+	}
+	if relay != nil {
+		opts = append(opts, httpserver.WithOutbox(relay))
+	}
+	if subscriber != nil {
+		opts = append(opts, httpserver.WithSubscription(subscriber))
+	}
+
+	// Load authentication config, shared by the HTTP and gRPC APIs
+	grpcOpts := []grpcserver.Option{
+		grpcserver.WithEvents(broker),
+		grpcserver.WithSubmitter(pipeline),
+		grpcserver.WithMasking(policy, masking.Role(cfg.MaskingDefaultRole)),
+	}
+	if cfg.AuthConfigFile != "" {
+		authn, err := auth.LoadFile(cfg.AuthConfigFile)
+		if err != nil {
+			fatal("Failed to load auth config", err)
+		}
+		opts = append(opts, httpserver.WithAuth(authn))
+		grpcOpts = append(grpcOpts, grpcserver.WithAuth(authn))
+	} else {
+		slog.Warn("AUTH_CONFIG_FILE is not set, HTTP and gRPC APIs are unauthenticated and SubmitOrder is refused")
 	}
 
 	// Start HTTP server
-	server := httpserver.NewServer(orderCache)
+	server := httpserver.NewServer(orderCache, opts...)
 
 	// Run HTTP server in goroutine
 	go func() {
-		if err := server.Start(httpPort); err != nil {
-			log.Fatalf("HTTP server error: %v", err)
+		if err := server.Start(cfg.HTTPPort); err != nil {
+			fatal("HTTP server error", err)
 		}
 	}()
 
-	log.Printf("Service started successfully!")
-	log.Printf("HTTP server: http://localhost:%s", httpPort)
-	log.Printf("Cache size: %d orders", orderCache.Size())
+	// Start admin server, which refuses every request without authentication
+	switch {
+	case cfg.AdminAddr == "none":
+	case cfg.AuthConfigFile == "":
+		slog.Warn("AUTH_CONFIG_FILE is not set, admin server is disabled")
+	default:
+		go func() {
+			if err := server.StartAdmin(cfg.AdminAddr); err != nil {
+				fatal("Admin HTTP server error", err)
+			}
+		}()
+	}
+
+	// Start gRPC server
+	grpcServer := grpcserver.NewServer(orderCache, grpcOpts...)
+	go func() {
+		if err := grpcServer.Start(cfg.GRPCPort); err != nil {
+			fatal("gRPC server error", err)
+		}
+	}()
+
+	slog.Info("Service started",
+		"http_port", cfg.HTTPPort,
+		"grpc_port", cfg.GRPCPort,
+		"cached_orders", orderCache.Size(),
+	)
 
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan
 
-	log.Println("Shutting down gracefully...")
+	slog.Info("Shutting down gracefully")
+	grpcServer.Stop()
+}
+
+// restoreSince returns the oldest creation date of cached orders, or the
+// zero time when the window is 0 and every order is cached.
+func restoreSince(window time.Duration) time.Time {
+	if window <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-window)
+}
+
+// cacheControl reloads the cache with the same window as at startup.
+type cacheControl struct {
+	*cache.OrderCache
+	repo   cache.Repository
+	window time.Duration
+}
+
+func (c cacheControl) Reload(ctx context.Context) (int, error) {
+	return c.OrderCache.Reload(ctx, c.repo, restoreSince(c.window))
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
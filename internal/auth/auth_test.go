@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func encodeSegments(t *testing.T, header, payload interface{}) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b64(h) + "." + b64(p)
+}
+
+func signHS256(t *testing.T, secret []byte, kid string, payload interface{}) string {
+	t.Helper()
+	signed := encodeSegments(t, map[string]string{"alg": "HS256", "typ": "JWT", "kid": kid}, payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + b64(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, payload interface{}) string {
+	t.Helper()
+	signed := encodeSegments(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}, payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + b64(sig)
+}
+
+func bearerRequest(token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestPrincipalHasScope(t *testing.T) {
+	p := &Principal{Scopes: []string{ScopeOrdersRead}}
+	if !p.HasScope(ScopeOrdersRead) {
+		t.Error("Expected orders:read scope")
+	}
+	if p.HasScope(ScopeAnalyticsRead) {
+		t.Error("Did not expect analytics:read scope")
+	}
+
+	admin := &Principal{Scopes: []string{ScopeAdmin}}
+	if !admin.HasScope(ScopeOrdersWrite) {
+		t.Error("Expected admin to imply every scope")
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	a, err := NewAPIKeyAuthenticator([]APIKey{
+		{Name: "support", Hash: HashAPIKey("secret-key"), Scopes: []string{ScopeOrdersRead}, Role: "support"},
+	})
+	if err != nil {
+		t.Fatalf("NewAPIKeyAuthenticator failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "secret-key")
+	p, err := a.Authenticate(req)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if p.Subject != "apikey:support" || p.Role != "support" {
+		t.Errorf("Unexpected principal: %+v", p)
+	}
+
+	if _, err := a.Authenticate(bearerRequest("secret-key")); err != nil {
+		t.Errorf("Expected bearer API key to be accepted, got %v", err)
+	}
+
+	req.Header.Set("X-API-Key", "wrong")
+	if _, err := a.Authenticate(req); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials, got %v", err)
+	}
+
+	if _, err := a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestAPIKeyRejectsPlaintextHash(t *testing.T) {
+	_, err := NewAPIKeyAuthenticator([]APIKey{{Name: "bad", Hash: "secret-key"}})
+	if err == nil {
+		t.Error("Expected error for unhashed key")
+	}
+}
+
+func TestJWTAuthenticatorHMAC(t *testing.T) {
+	secret := []byte("test-secret")
+	key := JWK{Kty: "oct", Kid: "hmac", K: b64(secret)}
+	if err := key.parse(); err != nil {
+		t.Fatal(err)
+	}
+	a := NewJWTAuthenticator([]JWK{key}, "issuer", "order-service")
+
+	token := signHS256(t, secret, "hmac", map[string]interface{}{
+		"sub":   "user1",
+		"iss":   "issuer",
+		"aud":   []string{"order-service"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "orders:read analytics:read",
+		"role":  "analyst",
+	})
+
+	p, err := a.Authenticate(bearerRequest(token))
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if p.Subject != "user1" || p.Role != "analyst" {
+		t.Errorf("Unexpected principal: %+v", p)
+	}
+	if !p.HasScope(ScopeOrdersRead) || !p.HasScope(ScopeAnalyticsRead) {
+		t.Errorf("Expected scopes from token, got %v", p.Scopes)
+	}
+}
+
+func TestJWTAuthenticatorRejects(t *testing.T) {
+	secret := []byte("test-secret")
+	key := JWK{Kty: "oct", Kid: "hmac", K: b64(secret)}
+	if err := key.parse(); err != nil {
+		t.Fatal(err)
+	}
+	a := NewJWTAuthenticator([]JWK{key}, "issuer", "")
+
+	valid := map[string]interface{}{"sub": "u", "iss": "issuer", "exp": time.Now().Add(time.Hour).Unix()}
+
+	cases := map[string]string{
+		"expired": signHS256(t, secret, "hmac", map[string]interface{}{
+			"sub": "u", "iss": "issuer", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"wrong issuer": signHS256(t, secret, "hmac", map[string]interface{}{
+			"sub": "u", "iss": "other", "exp": time.Now().Add(time.Hour).Unix()}),
+		"no expiry":    signHS256(t, secret, "hmac", map[string]interface{}{"sub": "u", "iss": "issuer"}),
+		"wrong secret": signHS256(t, []byte("other-secret"), "hmac", valid),
+		"alg none":     encodeSegments(t, map[string]string{"alg": "none"}, valid) + ".",
+	}
+
+	for name, token := range cases {
+		if _, err := a.Authenticate(bearerRequest(token)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s: expected ErrInvalidCredentials, got %v", name, err)
+		}
+	}
+}
+
+func TestJWTAuthenticatorRSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := JWK{
+		Kty: "RSA",
+		Kid: "rsa",
+		N:   b64(priv.N.Bytes()),
+		E:   b64(big.NewInt(int64(priv.E)).Bytes()),
+	}
+	if err := key.parse(); err != nil {
+		t.Fatal(err)
+	}
+	a := NewJWTAuthenticator([]JWK{key}, "", "")
+
+	token := signRS256(t, priv, "rsa", map[string]interface{}{
+		"sub": "service",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"scp": []string{ScopeAdmin},
+	})
+
+	p, err := a.Authenticate(bearerRequest(token))
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if !p.HasScope(ScopeOrdersWrite) {
+		t.Errorf("Expected admin scope, got %v", p.Scopes)
+	}
+
+	// HMAC tokens must not validate against an RSA key
+	forged := signHS256(t, priv.N.Bytes(), "rsa", map[string]interface{}{
+		"sub": "attacker", "exp": time.Now().Add(time.Hour).Unix()})
+	if _, err := a.Authenticate(bearerRequest(forged)); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected algorithm confusion to be rejected, got %v", err)
+	}
+}
+
+func TestChain(t *testing.T) {
+	keys, err := NewAPIKeyAuthenticator([]APIKey{{Name: "k", Hash: HashAPIKey("key")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := Chain{keys, NewJWTAuthenticator(nil, "", "")}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-API-Key", "key")
+	if _, err := chain.Authenticate(req); err != nil {
+		t.Errorf("Expected API key to pass the chain, got %v", err)
+	}
+
+	if _, err := chain.Authenticate(bearerRequest("a.b.c")); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Expected JWT authenticator to reject token, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config is the on-disk authentication configuration.
+type Config struct {
+	APIKeys []APIKey   `json:"api_keys"`
+	JWT     *JWTConfig `json:"jwt"`
+}
+
+// LoadFile reads the authentication config at path and builds an
+// authenticator trying API keys first and JWTs second.
+func LoadFile(path string) (Authenticator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse auth config: %w", err)
+	}
+
+	var chain Chain
+	if len(cfg.APIKeys) > 0 {
+		keys, err := NewAPIKeyAuthenticator(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if cfg.JWT != nil {
+		jwks, err := LoadJWKSFile(cfg.JWT.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, NewJWTAuthenticator(jwks, cfg.JWT.Issuer, cfg.JWT.Audience))
+	}
+	return chain, nil
+}
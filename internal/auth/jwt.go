@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"order-service/internal/masking"
+)
+
+// JWK is a single JSON Web Key. Symmetric ("oct") and RSA keys are supported.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+
+	secret []byte
+	public *rsa.PublicKey
+}
+
+// LoadJWKSFile reads a JWK Set document ({"keys": [...]}) from path.
+func LoadJWKSFile(path string) ([]JWK, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+
+	for i := range set.Keys {
+		if err := set.Keys[i].parse(); err != nil {
+			return nil, err
+		}
+	}
+	return set.Keys, nil
+}
+
+func (k *JWK) parse() error {
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return fmt.Errorf("jwk %q: invalid symmetric key", k.Kid)
+		}
+		k.secret = secret
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("jwk %q: invalid modulus", k.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return fmt.Errorf("jwk %q: invalid exponent", k.Kid)
+		}
+		k.public = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	default:
+		return fmt.Errorf("jwk %q: unsupported key type %q", k.Kid, k.Kty)
+	}
+	return nil
+}
+
+var hashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+}
+
+func (k *JWK) verify(alg string, signed, sig []byte) bool {
+	hash, ok := hashes[alg]
+	if !ok || (k.Alg != "" && k.Alg != alg) {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(alg, "HS") && k.secret != nil:
+		var mac = hmac.New(sha256.New, k.secret)
+		switch hash {
+		case crypto.SHA384:
+			mac = hmac.New(sha512.New384, k.secret)
+		case crypto.SHA512:
+			mac = hmac.New(sha512.New, k.secret)
+		}
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), sig)
+
+	case strings.HasPrefix(alg, "RS") && k.public != nil:
+		h := hash.New()
+		h.Write(signed)
+		return rsa.VerifyPKCS1v15(k.public, hash, h.Sum(nil), sig) == nil
+	}
+	return false
+}
+
+// JWTConfig configures bearer token validation.
+type JWTConfig struct {
+	JWKSFile string `json:"jwks_file"`
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+}
+
+// JWTAuthenticator validates HMAC- or RSA-signed bearer tokens against a
+// local key set.
+type JWTAuthenticator struct {
+	keys     []JWK
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+func NewJWTAuthenticator(keys []JWK, issuer, audience string) *JWTAuthenticator {
+	return &JWTAuthenticator{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   30 * time.Second,
+		now:      time.Now,
+	}
+}
+
+type claims struct {
+	Subject   string       `json:"sub"`
+	Issuer    string       `json:"iss"`
+	Audience  audience     `json:"aud"`
+	ExpiresAt int64        `json:"exp"`
+	NotBefore int64        `json:"nbf"`
+	Scope     string       `json:"scope"`
+	Scp       []string     `json:"scp"`
+	Role      masking.Role `json:"role"`
+}
+
+// audience accepts both the string and array forms of the "aud" claim.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := bearerToken(r)
+	if !ok || strings.Count(token, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+
+	c, err := a.validate(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	scopes := c.Scp
+	if c.Scope != "" {
+		scopes = append(scopes, strings.Fields(c.Scope)...)
+	}
+	return &Principal{Subject: c.Subject, Scopes: scopes, Role: c.Role}, nil
+}
+
+func (a *JWTAuthenticator) validate(token string) (*claims, error) {
+	parts := strings.Split(token, ".")
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("malformed header")
+	}
+	if _, ok := hashes[header.Alg]; !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for i := range a.keys {
+		if header.Kid != "" && a.keys[i].Kid != header.Kid {
+			continue
+		}
+		if a.keys[i].verify(header.Alg, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("signature verification failed")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed payload")
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, fmt.Errorf("malformed claims")
+	}
+
+	now := a.now()
+	if c.ExpiresAt == 0 {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(a.leeway)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if c.NotBefore != 0 && now.Add(a.leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+	if a.issuer != "" && c.Issuer != a.issuer {
+		return nil, fmt.Errorf("unexpected issuer")
+	}
+	if a.audience != "" && !contains(c.Audience, a.audience) {
+		return nil, fmt.Errorf("unexpected audience")
+	}
+
+	return &c, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -3,19 +3,33 @@ package cache
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"order-service/internal/models"
 )
 
 type OrderCache struct {
-	mu     sync.RWMutex
-	orders map[string]*models.Order
+	mu           sync.RWMutex
+	orders       map[string]*models.Order
+	version      uint64
+	lastModified time.Time
+	onSet        []func(*models.Order)
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	// reloadMu serialises Reload. While a reload reads the database,
+	// pending collects the orders set in the meantime so the swap does
+	// not lose them.
+	reloadMu sync.Mutex
+	pending  map[string]*models.Order
 }
 
 type Repository interface {
-	GetAllOrders(ctx context.Context) ([]models.Order, error)
+	GetOrdersSince(ctx context.Context, since time.Time) ([]models.Order, error)
 }
 
 func NewOrderCache() *OrderCache {
@@ -26,14 +40,50 @@ func NewOrderCache() *OrderCache {
 
 func (c *OrderCache) Set(orderUID string, order *models.Order) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
 	c.orders[orderUID] = order
+	if c.pending != nil {
+		c.pending[orderUID] = order
+	}
+	c.touch()
+	listeners := c.onSet
+	c.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(order)
+	}
+}
+
+// OnSet registers fn to be called after every Set. Every write path stores
+// orders through Set, so this is where change notifications hook in.
+func (c *OrderCache) OnSet(fn func(*models.Order)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onSet = append(c.onSet, fn)
+}
+
+// touch records a change to the cache contents. Callers must hold c.mu.
+func (c *OrderCache) touch() {
+	c.version++
+	c.lastModified = time.Now()
+}
+
+// Version returns a counter that changes whenever the cache contents change,
+// together with the time of the last change.
+func (c *OrderCache) Version() (uint64, time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version, c.lastModified
 }
 
 func (c *OrderCache) Get(orderUID string) (*models.Order, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	order, exists := c.orders[orderUID]
+	if exists {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
 	return order, exists
 }
 
@@ -48,16 +98,33 @@ func (c *OrderCache) GetAll() []models.Order {
 	return orders
 }
 
+// Delete removes an order from the cache and reports whether it was
+// cached.
+func (c *OrderCache) Delete(orderUID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, exists := c.orders[orderUID]
+	if !exists {
+		return false
+	}
+	delete(c.orders, orderUID)
+	delete(c.pending, orderUID)
+	c.touch()
+	return true
+}
+
 func (c *OrderCache) Size() int {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return len(c.orders)
 }
 
-func (c *OrderCache) RestoreFromDB(ctx context.Context, repo Repository) error {
-	log.Println("Restoring cache from database...")
+// RestoreFromDB loads the orders created since the given time into the
+// cache. A zero since loads every order.
+func (c *OrderCache) RestoreFromDB(ctx context.Context, repo Repository, since time.Time) error {
+	slog.InfoContext(ctx, "Restoring cache from database", "since", since)
 
-	orders, err := repo.GetAllOrders(ctx)
+	orders, err := repo.GetOrdersSince(ctx, since)
 	if err != nil {
 		return fmt.Errorf("failed to restore cache from DB: %w", err)
 	}
@@ -68,7 +135,44 @@ func (c *OrderCache) RestoreFromDB(ctx context.Context, repo Repository) error {
 	for i := range orders {
 		c.orders[orders[i].OrderUID] = &orders[i]
 	}
+	c.touch()
 
-	log.Printf("Cache restored successfully. Total orders in cache: %d\n", len(c.orders))
+	slog.InfoContext(ctx, "Cache restored", "orders", len(c.orders))
 	return nil
 }
+
+// Reload replaces the cache contents with the orders created since the
+// given time, dropping entries that are no longer in the database. Orders
+// set while the database is read are kept. It returns the number of cached
+// orders.
+func (c *OrderCache) Reload(ctx context.Context, repo Repository, since time.Time) (int, error) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	c.mu.Lock()
+	c.pending = make(map[string]*models.Order)
+	c.mu.Unlock()
+
+	orders, err := repo.GetOrdersSince(ctx, since)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending := c.pending
+	c.pending = nil
+	if err != nil {
+		return 0, fmt.Errorf("failed to reload cache from DB: %w", err)
+	}
+
+	reloaded := make(map[string]*models.Order, len(orders)+len(pending))
+	for i := range orders {
+		reloaded[orders[i].OrderUID] = &orders[i]
+	}
+	for uid, order := range pending {
+		reloaded[uid] = order
+	}
+	c.orders = reloaded
+	c.touch()
+
+	slog.InfoContext(ctx, "Cache reloaded", "orders", len(c.orders))
+	return len(c.orders), nil
+}
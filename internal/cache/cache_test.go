@@ -13,11 +13,17 @@ type mockRepository struct {
 	err    error
 }
 
-func (m *mockRepository) GetAllOrders(ctx context.Context) ([]models.Order, error) {
+func (m *mockRepository) GetOrdersSince(ctx context.Context, since time.Time) ([]models.Order, error) {
 	if m.err != nil {
 		return nil, m.err
 	}
-	return m.orders, nil
+	var orders []models.Order
+	for _, order := range m.orders {
+		if !order.DateCreated.Before(since) {
+			orders = append(orders, order)
+		}
+	}
+	return orders, nil
 }
 
 func TestNewOrderCache(t *testing.T) {
@@ -105,7 +111,7 @@ func TestRestoreFromDB(t *testing.T) {
 
 	mockRepo := &mockRepository{orders: mockOrders}
 
-	err := cache.RestoreFromDB(context.Background(), mockRepo)
+	err := cache.RestoreFromDB(context.Background(), mockRepo, time.Time{})
 	if err != nil {
 		t.Fatalf("RestoreFromDB failed: %v", err)
 	}
@@ -125,6 +131,27 @@ func TestRestoreFromDB(t *testing.T) {
 	}
 }
 
+func TestRestoreFromDBWindow(t *testing.T) {
+	cache := NewOrderCache()
+	now := time.Now()
+
+	mockRepo := &mockRepository{orders: []models.Order{
+		{OrderUID: "old", DateCreated: now.Add(-90 * 24 * time.Hour)},
+		{OrderUID: "recent", DateCreated: now.Add(-time.Hour)},
+	}}
+
+	if err := cache.RestoreFromDB(context.Background(), mockRepo, now.Add(-30*24*time.Hour)); err != nil {
+		t.Fatalf("RestoreFromDB failed: %v", err)
+	}
+
+	if _, exists := cache.Get("recent"); !exists {
+		t.Error("Expected recent order to be restored")
+	}
+	if _, exists := cache.Get("old"); exists {
+		t.Error("Expected order outside the window not to be restored")
+	}
+}
+
 func TestCacheConcurrency(t *testing.T) {
 	cache := NewOrderCache()
 
@@ -160,3 +187,149 @@ func TestCacheConcurrency(t *testing.T) {
 		<-done
 	}
 }
+
+func TestCacheVersion(t *testing.T) {
+	cache := NewOrderCache()
+
+	v0, _ := cache.Version()
+	cache.Set("order1", &models.Order{OrderUID: "order1"})
+	v1, modified := cache.Version()
+
+	if v1 == v0 {
+		t.Error("Expected version to change after Set")
+	}
+	if modified.IsZero() {
+		t.Error("Expected last modified time to be set")
+	}
+
+	v2, _ := cache.Version()
+	if v2 != v1 {
+		t.Error("Expected version to stay the same without changes")
+	}
+}
+
+func TestCacheOnSet(t *testing.T) {
+	cache := NewOrderCache()
+
+	var notified []string
+	cache.OnSet(func(order *models.Order) {
+		notified = append(notified, order.OrderUID)
+	})
+
+	cache.Set("order1", &models.Order{OrderUID: "order1"})
+	cache.RestoreFromDB(context.Background(), &mockRepository{
+		orders: []models.Order{{OrderUID: "order2"}},
+	}, time.Time{})
+
+	if len(notified) != 1 || notified[0] != "order1" {
+		t.Errorf("Expected only Set to notify, got %v", notified)
+	}
+}
+
+func TestCacheDelete(t *testing.T) {
+	cache := NewOrderCache()
+	cache.Set("order1", &models.Order{OrderUID: "order1"})
+	version, _ := cache.Version()
+
+	if !cache.Delete("order1") {
+		t.Error("Expected Delete to report the cached order")
+	}
+	if _, exists := cache.Get("order1"); exists {
+		t.Error("Expected order to be removed")
+	}
+	if v, _ := cache.Version(); v == version {
+		t.Error("Expected Delete to change the version")
+	}
+	if cache.Delete("order1") {
+		t.Error("Expected Delete of a missing order to report false")
+	}
+}
+
+// blockingRepository returns its orders once release is closed.
+type blockingRepository struct {
+	mockRepository
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingRepository) GetOrdersSince(ctx context.Context, since time.Time) ([]models.Order, error) {
+	close(b.started)
+	<-b.release
+	return b.mockRepository.GetOrdersSince(ctx, since)
+}
+
+func TestReloadReplacesContents(t *testing.T) {
+	cache := NewOrderCache()
+	cache.Set("stale", &models.Order{OrderUID: "stale"})
+
+	repo := &blockingRepository{
+		mockRepository: mockRepository{orders: []models.Order{{OrderUID: "stored"}}},
+		started:        make(chan struct{}),
+		release:        make(chan struct{}),
+	}
+	done := make(chan int)
+	go func() {
+		n, err := cache.Reload(context.Background(), repo, time.Time{})
+		if err != nil {
+			t.Errorf("Reload failed: %v", err)
+		}
+		done <- n
+	}()
+
+	// An order arriving while the database is read survives the reload.
+	<-repo.started
+	cache.Set("arrived", &models.Order{OrderUID: "arrived"})
+	close(repo.release)
+
+	if n := <-done; n != 2 {
+		t.Errorf("Expected 2 cached orders, got %d", n)
+	}
+	for uid, want := range map[string]bool{"stale": false, "stored": true, "arrived": true} {
+		if _, exists := cache.Get(uid); exists != want {
+			t.Errorf("Order %s: expected cached=%v", uid, want)
+		}
+	}
+}
+
+func TestReloadKeepsContentsOnError(t *testing.T) {
+	cache := NewOrderCache()
+	cache.Set("order1", &models.Order{OrderUID: "order1"})
+
+	_, err := cache.Reload(context.Background(), &mockRepository{err: context.DeadlineExceeded}, time.Time{})
+	if err == nil {
+		t.Fatal("Expected Reload to fail")
+	}
+	if _, exists := cache.Get("order1"); !exists {
+		t.Error("Expected cache contents to be kept after a failed reload")
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	cache := NewOrderCache()
+	cache.Set("order1", &models.Order{
+		OrderUID: "order1",
+		Items:    []models.Item{{Name: "Mascaras"}, {Name: "Lipstick"}},
+	})
+
+	cache.Get("order1")
+	cache.Get("order1")
+	cache.Get("order1")
+	cache.Get("missing")
+
+	stats := cache.Stats()
+	if stats.Entries != 1 || stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats.HitRatio != 0.75 {
+		t.Errorf("Expected hit ratio 0.75, got %v", stats.HitRatio)
+	}
+	if stats.ApproxBytes <= 0 {
+		t.Errorf("Expected a memory estimate, got %d", stats.ApproxBytes)
+	}
+
+	before := stats.ApproxBytes
+	cache.Set("order2", &models.Order{OrderUID: "order2"})
+	if after := cache.Stats().ApproxBytes; after <= before {
+		t.Errorf("Expected the estimate to grow with a second order, got %d then %d", before, after)
+	}
+}
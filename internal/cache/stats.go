@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"reflect"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// mapEntryOverhead approximates what a map entry costs beyond its key
+// bytes: the key's string header, the value pointer and bucket metadata.
+const mapEntryOverhead = 48
+
+// Stats describes the contents and use of the cache.
+type Stats struct {
+	Entries int `json:"entries"`
+	// ApproxBytes estimates the memory held by cached orders: their
+	// fields, strings and slices, without allocator overhead.
+	ApproxBytes  int64     `json:"approx_bytes"`
+	Hits         uint64    `json:"hits"`
+	Misses       uint64    `json:"misses"`
+	HitRatio     float64   `json:"hit_ratio"`
+	Version      uint64    `json:"version"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Stats returns the cache statistics. Hits and misses count Get calls since
+// the cache was created. The memory estimate walks every order, so this is
+// meant for occasional inspection rather than the request path.
+func (c *OrderCache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := Stats{
+		Entries:      len(c.orders),
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		Version:      c.version,
+		LastModified: c.lastModified,
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	for uid, order := range c.orders {
+		stats.ApproxBytes += int64(len(uid)) + mapEntryOverhead + orderSize(order)
+	}
+	return stats
+}
+
+// orderSize approximates the bytes held by an order.
+func orderSize(order *models.Order) int64 {
+	v := reflect.ValueOf(order).Elem()
+	return int64(v.Type().Size()) + indirectSize(v)
+}
+
+// indirectSize returns the bytes v references outside its own memory:
+// string contents, slice backing arrays and pointed-to values.
+func indirectSize(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Slice:
+		if v.IsNil() {
+			return 0
+		}
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			size += indirectSize(v.Index(i))
+		}
+		return size
+	case reflect.Pointer:
+		if v.IsNil() {
+			return 0
+		}
+		return int64(v.Type().Elem().Size()) + indirectSize(v.Elem())
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			// A time's location is shared, not owned by the order.
+			return 0
+		}
+		var size int64
+		for i := 0; i < v.NumField(); i++ {
+			size += indirectSize(v.Field(i))
+		}
+		return size
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the service configuration. Every value can be overridden by an
+// environment variable; the defaults match docker-compose.yml.
+type Config struct {
+	// Database configuration
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+
+	// NATS Streaming configuration
+	NatsURL         string
+	NatsCluster     string
+	NatsClientID    string
+	NatsSubject     string
+	NatsWorkers     int
+	NatsMaxInflight int
+	NatsBatchSize   int
+	NatsBatchLinger time.Duration
+	// NatsStartFrom is durable or stored, see nats.StartPosition
+	NatsStartFrom string
+
+	// HTTP server configuration
+	HTTPPort string
+
+	// gRPC server configuration
+	GRPCPort string
+
+	// Admin listener address, "none" to disable it
+	AdminAddr string
+
+	// Currency configuration
+	ReportingCurrency string
+	RatesFile         string
+
+	// PII masking configuration
+	MaskingDefaultRole string
+	MaskingPolicyFile  string
+
+	// Authentication configuration
+	AuthConfigFile string
+
+	// Rate limiting configuration, in requests per second and burst size
+	RateLimitRPS       float64
+	RateLimitBurst     int
+	RateLimitListRPS   float64
+	RateLimitListBurst int
+	RateLimitIPRPS     float64
+	RateLimitIPBurst   int
+	MaxInFlight        int
+
+	// Live feed configuration
+	EventsHistory int
+	EventsBuffer  int
+
+	// Logging configuration
+	LogLevel  string
+	LogFormat string
+
+	// Tracing configuration
+	TracingExporter string
+	TracingFile     string
+
+	// Outbox relay configuration
+	OutboxSubjects    string
+	OutboxInterval    time.Duration
+	OutboxBatchSize   int
+	OutboxMaxAttempts int
+
+	// Webhook delivery configuration
+	WebhookTimeout     time.Duration
+	WebhookMaxAttempts int
+	WebhookBackoffBase time.Duration
+	WebhookBackoffMax  time.Duration
+
+	// Partitioning and retention configuration
+	PartitionPremakeMonths int
+	RetentionMonths        int
+	RetentionInterval      time.Duration
+	ArchiveDir             string
+
+	// Cache configuration
+	CacheRestoreWindow time.Duration
+
+	// Cache reconciliation configuration
+	ReconcileInterval time.Duration
+	ReconcileRepair   bool
+}
+
+// Load builds a Config from environment variables.
+func Load() *Config {
+	return &Config{
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnv("DB_PORT", "5432"),
+		DBUser:     getEnv("DB_USER", "orderuser"),
+		DBPassword: getEnv("DB_PASSWORD", "orderpass"),
+		DBName:     getEnv("DB_NAME", "ordersdb"),
+
+		NatsURL:         getEnv("NATS_URL", "nats://localhost:4222"),
+		NatsCluster:     getEnv("NATS_CLUSTER", "test-cluster"),
+		NatsClientID:    getEnv("NATS_CLIENT_ID", "order-service"),
+		NatsSubject:     getEnv("NATS_SUBJECT", "orders"),
+		NatsWorkers:     getEnvInt("NATS_WORKERS", 8),
+		NatsMaxInflight: getEnvInt("NATS_MAX_INFLIGHT", 256),
+		NatsBatchSize:   getEnvInt("NATS_BATCH_SIZE", 32),
+		NatsBatchLinger: getEnvDuration("NATS_BATCH_LINGER", 5*time.Millisecond),
+		NatsStartFrom:   getEnv("NATS_START_FROM", "durable"),
+
+		HTTPPort: getEnv("HTTP_PORT", "8080"),
+
+		GRPCPort: getEnv("GRPC_PORT", "9090"),
+
+		AdminAddr: getEnv("ADMIN_ADDR", "127.0.0.1:8081"),
+
+		ReportingCurrency: strings.ToUpper(getEnv("REPORTING_CURRENCY", "USD")),
+		RatesFile:         getEnv("EXCHANGE_RATES_FILE", ""),
+
+		MaskingDefaultRole: getEnv("PII_DEFAULT_ROLE", "public"),
+		MaskingPolicyFile:  getEnv("PII_POLICY_FILE", ""),
+
+		AuthConfigFile: getEnv("AUTH_CONFIG_FILE", ""),
+
+		RateLimitRPS:       getEnvFloat("RATE_LIMIT_RPS", 100),
+		RateLimitBurst:     getEnvInt("RATE_LIMIT_BURST", 200),
+		RateLimitListRPS:   getEnvFloat("RATE_LIMIT_LIST_RPS", 2),
+		RateLimitListBurst: getEnvInt("RATE_LIMIT_LIST_BURST", 5),
+		RateLimitIPRPS:     getEnvFloat("RATE_LIMIT_IP_RPS", 200),
+		RateLimitIPBurst:   getEnvInt("RATE_LIMIT_IP_BURST", 400),
+		MaxInFlight:        getEnvInt("HTTP_MAX_INFLIGHT", 256),
+
+		EventsHistory: getEnvInt("EVENTS_HISTORY", 1000),
+		EventsBuffer:  getEnvInt("EVENTS_BUFFER", 64),
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "text"),
+
+		TracingExporter: getEnv("TRACING_EXPORTER", "none"),
+		TracingFile:     getEnv("TRACING_FILE", "traces.jsonl"),
+
+		OutboxSubjects:    getEnv("OUTBOX_SUBJECTS", "order.persisted=orders.persisted"),
+		OutboxInterval:    getEnvDuration("OUTBOX_INTERVAL", time.Second),
+		OutboxBatchSize:   getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxMaxAttempts: getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+
+		WebhookTimeout:     getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookBackoffBase: getEnvDuration("WEBHOOK_BACKOFF_BASE", 10*time.Second),
+		WebhookBackoffMax:  getEnvDuration("WEBHOOK_BACKOFF_MAX", time.Hour),
+
+		PartitionPremakeMonths: getEnvInt("PARTITION_PREMAKE_MONTHS", 3),
+		RetentionMonths:        getEnvInt("RETENTION_MONTHS", 0),
+		RetentionInterval:      getEnvDuration("RETENTION_INTERVAL", time.Hour),
+		ArchiveDir:             getEnv("ARCHIVE_DIR", "archive"),
+
+		CacheRestoreWindow: getEnvDuration("CACHE_RESTORE_WINDOW", 30*24*time.Hour),
+
+		ReconcileInterval: getEnvDuration("RECONCILE_INTERVAL", time.Hour),
+		ReconcileRepair:   getEnvBool("RECONCILE_REPAIR", false),
+	}
+}
+
+// redacted replaces a secret value.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of c that is safe to show: the database password
+// and any credentials in the NATS URLs are replaced.
+func (c *Config) Redacted() *Config {
+	r := *c
+	if r.DBPassword != "" {
+		r.DBPassword = redacted
+	}
+	// NATS_URL may list several servers separated by commas.
+	servers := strings.Split(r.NatsURL, ",")
+	for i, server := range servers {
+		u, err := url.Parse(strings.TrimSpace(server))
+		if err != nil || u.User == nil {
+			continue
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		} else {
+			// A user without a password is an authentication token.
+			u.User = url.User(redacted)
+		}
+		servers[i] = u.String()
+	}
+	r.NatsURL = strings.Join(servers, ",")
+	return &r
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	value, err := strconv.ParseFloat(getEnv(key, ""), 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
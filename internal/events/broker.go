@@ -0,0 +1,153 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Event types published by the service.
+const (
+	TypeOrderStored = "order.stored"
+)
+
+// Event is a change to an order, numbered in publication order.
+type Event struct {
+	ID    uint64        `json:"id"`
+	Type  string        `json:"type"`
+	Time  time.Time     `json:"time"`
+	Order *models.Order `json:"order"`
+}
+
+// Subscription receives events matching its filter. C is closed when the
+// subscriber falls too far behind or the subscription is cancelled.
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter models.OrderFilter
+	broker *Broker
+	once   sync.Once
+}
+
+// Close cancels the subscription.
+func (s *Subscription) Close() {
+	s.broker.unsubscribe(s)
+}
+
+// Broker fans out order events to live subscribers and keeps the most recent
+// events in a bounded ring buffer so that reconnecting clients can resume.
+type Broker struct {
+	mu     sync.Mutex
+	nextID uint64
+	ring   []Event
+	start  int // index of the oldest event in ring
+	count  int
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+// NewBroker creates a broker that retains the last history events and gives
+// each subscriber a queue of buffer events.
+func NewBroker(history, buffer int) *Broker {
+	return &Broker{
+		nextID: 1,
+		ring:   make([]Event, history),
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+// PublishOrder records that an order was stored and delivers it to matching
+// subscribers. Subscribers that cannot keep up are disconnected rather than
+// slowing down the write path.
+func (b *Broker) PublishOrder(order *models.Order) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ev := Event{ID: b.nextID, Type: TypeOrderStored, Time: time.Now().UTC(), Order: order}
+	b.nextID++
+
+	if len(b.ring) > 0 {
+		idx := (b.start + b.count) % len(b.ring)
+		b.ring[idx] = ev
+		if b.count < len(b.ring) {
+			b.count++
+		} else {
+			b.start = (b.start + 1) % len(b.ring)
+		}
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(order) {
+			continue
+		}
+		select {
+		case sub.c <- ev:
+		default:
+			b.remove(sub)
+		}
+	}
+	return ev
+}
+
+// Subscribe registers a subscriber. Buffered events newer than lastID are
+// replayed first; lastID 0 means live events only. The second result is
+// false when events after lastID have already been evicted from the buffer,
+// so the client may have missed some.
+func (b *Broker) Subscribe(filter models.OrderFilter, lastID uint64) (*Subscription, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Event
+	complete := true
+	if lastID > 0 {
+		for i := 0; i < b.count; i++ {
+			ev := b.ring[(b.start+i)%len(b.ring)]
+			if i == 0 && ev.ID > lastID+1 {
+				complete = false
+			}
+			if ev.ID > lastID && filter.Match(ev.Order) {
+				backlog = append(backlog, ev)
+			}
+		}
+		if b.count == 0 && b.nextID > lastID+1 {
+			complete = false
+		}
+	}
+
+	size := b.buffer
+	if len(backlog) > size {
+		size = len(backlog)
+	}
+	c := make(chan Event, size)
+	for _, ev := range backlog {
+		c <- ev
+	}
+
+	sub := &Subscription{C: c, c: c, filter: filter, broker: b}
+	b.subs[sub] = struct{}{}
+	return sub, complete
+}
+
+// Subscribers returns the number of live subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+// remove drops a subscription. Callers must hold b.mu.
+func (b *Broker) remove(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	sub.once.Do(func() { close(sub.c) })
+}
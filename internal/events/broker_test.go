@@ -0,0 +1,75 @@
+package events
+
+import (
+	"testing"
+
+	"order-service/internal/models"
+)
+
+func TestBrokerDeliversMatchingEvents(t *testing.T) {
+	b := NewBroker(10, 10)
+	sub, _ := b.Subscribe(models.OrderFilter{DeliveryService: "meest"}, 0)
+	defer sub.Close()
+
+	b.PublishOrder(&models.Order{OrderUID: "order1", DeliveryService: "cdek"})
+	b.PublishOrder(&models.Order{OrderUID: "order2", DeliveryService: "meest"})
+
+	ev := <-sub.C
+	if ev.Order.OrderUID != "order2" {
+		t.Errorf("Expected order2, got %s", ev.Order.OrderUID)
+	}
+	if ev.ID != 2 {
+		t.Errorf("Expected event ID 2, got %d", ev.ID)
+	}
+	if len(sub.C) != 0 {
+		t.Error("Expected no further events")
+	}
+}
+
+func TestBrokerResumeFromLastEventID(t *testing.T) {
+	b := NewBroker(3, 10)
+	for i := 0; i < 5; i++ {
+		b.PublishOrder(&models.Order{OrderUID: string(rune('a' + i))})
+	}
+
+	// Events 3..5 are buffered
+	sub, complete := b.Subscribe(models.OrderFilter{}, 3)
+	defer sub.Close()
+	if !complete {
+		t.Error("Expected complete replay from ID 3")
+	}
+	if len(sub.C) != 2 {
+		t.Fatalf("Expected 2 replayed events, got %d", len(sub.C))
+	}
+	if ev := <-sub.C; ev.ID != 4 {
+		t.Errorf("Expected event 4 first, got %d", ev.ID)
+	}
+
+	// Event 2 was evicted
+	gap, complete := b.Subscribe(models.OrderFilter{}, 1)
+	defer gap.Close()
+	if complete {
+		t.Error("Expected incomplete replay when events were evicted")
+	}
+	if len(gap.C) != 3 {
+		t.Errorf("Expected whole buffer to be replayed, got %d", len(gap.C))
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(10, 1)
+	sub, _ := b.Subscribe(models.OrderFilter{}, 0)
+
+	b.PublishOrder(&models.Order{OrderUID: "order1"})
+	b.PublishOrder(&models.Order{OrderUID: "order2"})
+
+	if b.Subscribers() != 0 {
+		t.Error("Expected slow subscriber to be dropped")
+	}
+
+	<-sub.C
+	if _, ok := <-sub.C; ok {
+		t.Error("Expected channel to be closed")
+	}
+	sub.Close() // must not panic
+}
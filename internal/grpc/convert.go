@@ -0,0 +1,22 @@
+package grpc
+
+import (
+	"order-service/internal/grpc/orderpb"
+	"order-service/internal/models"
+)
+
+func filterFromProto(f *orderpb.OrderFilter) models.OrderFilter {
+	out := models.OrderFilter{
+		CustomerID:      f.GetCustomerId(),
+		DeliveryService: f.GetDeliveryService(),
+		Entry:           f.GetEntry(),
+		Currency:        f.GetCurrency(),
+	}
+	if f.GetFrom() != nil {
+		out.From = f.GetFrom().AsTime()
+	}
+	if f.GetTo() != nil {
+		out.To = f.GetTo().AsTime()
+	}
+	return out
+}
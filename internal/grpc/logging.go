@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"order-service/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDKey is the metadata key carrying the request ID, matching the
+// X-Request-ID header of the HTTP API.
+const requestIDKey = "x-request-id"
+
+// withRequestID attaches the caller's request ID, or a new one, to ctx and
+// returns it to the caller in the response header.
+func withRequestID(ctx context.Context, method string) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDKey); len(ids) > 0 && len(ids[0]) <= 128 {
+			id = ids[0]
+		}
+	}
+	if id == "" {
+		id = logging.NewID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return logging.With(ctx, "request_id", id, "rpc", method)
+}
+
+func logCall(ctx context.Context, start time.Time, err error) {
+	slog.InfoContext(ctx, "gRPC call", "code", status.Code(err).String(), "duration", time.Since(start))
+}
+
+func unaryLogging(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx = withRequestID(ctx, info.FullMethod)
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logCall(ctx, start, err)
+	return resp, err
+}
+
+// loggedStream overrides the context of a server stream.
+type loggedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s loggedStream) Context() context.Context {
+	return s.ctx
+}
+
+func streamLogging(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := withRequestID(ss.Context(), info.FullMethod)
+	start := time.Now()
+	err := handler(srv, loggedStream{ServerStream: ss, ctx: ctx})
+	logCall(ctx, start, err)
+	return err
+}
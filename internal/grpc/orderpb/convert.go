@@ -0,0 +1,122 @@
+package orderpb
+
+import (
+	"order-service/internal/models"
+	"order-service/internal/money"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FromModel converts an order to its protobuf form.
+func FromModel(o *models.Order) *Order {
+	out := &Order{
+		OrderUid:          o.OrderUID,
+		TrackNumber:       o.TrackNumber,
+		Entry:             o.Entry,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.Shardkey,
+		SmId:              int64(o.SmID),
+		OofShard:          o.OofShard,
+		Delivery: &Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount.Amount),
+			PaymentDt:    o.Payment.PaymentDt,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost.Amount),
+			GoodsTotal:   int64(o.Payment.GoodsTotal.Amount),
+			CustomFee:    int64(o.Payment.CustomFee.Amount),
+		},
+	}
+	if !o.DateCreated.IsZero() {
+		out.DateCreated = timestamppb.New(o.DateCreated)
+	}
+	for _, item := range o.Items {
+		out.Items = append(out.Items, &Item{
+			ChrtId:      int64(item.ChrtID),
+			TrackNumber: item.TrackNumber,
+			Price:       int64(item.Price.Amount),
+			Rid:         item.Rid,
+			Name:        item.Name,
+			Sale:        int32(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  int64(item.TotalPrice.Amount),
+			NmId:        int64(item.NmID),
+			Brand:       item.Brand,
+			Status:      int32(item.Status),
+		})
+	}
+	return out
+}
+
+// ToModel converts a protobuf order back to models.Order.
+func ToModel(o *Order) *models.Order {
+	d, p := o.GetDelivery(), o.GetPayment()
+	out := &models.Order{
+		OrderUID:          o.GetOrderUid(),
+		TrackNumber:       o.GetTrackNumber(),
+		Entry:             o.GetEntry(),
+		Locale:            o.GetLocale(),
+		InternalSignature: o.GetInternalSignature(),
+		CustomerID:        o.GetCustomerId(),
+		DeliveryService:   o.GetDeliveryService(),
+		Shardkey:          o.GetShardkey(),
+		SmID:              int(o.GetSmId()),
+		OofShard:          o.GetOofShard(),
+		Delivery: models.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		},
+		Payment: models.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       money.Money{Amount: money.Amount(p.GetAmount())},
+			PaymentDt:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: money.Money{Amount: money.Amount(p.GetDeliveryCost())},
+			GoodsTotal:   money.Money{Amount: money.Amount(p.GetGoodsTotal())},
+			CustomFee:    money.Money{Amount: money.Amount(p.GetCustomFee())},
+		},
+	}
+	if o.GetDateCreated() != nil {
+		out.DateCreated = o.GetDateCreated().AsTime()
+	}
+	for _, item := range o.GetItems() {
+		out.Items = append(out.Items, models.Item{
+			ChrtID:      int(item.GetChrtId()),
+			TrackNumber: item.GetTrackNumber(),
+			Price:       money.Money{Amount: money.Amount(item.GetPrice())},
+			Rid:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        int(item.GetSale()),
+			Size:        item.GetSize(),
+			TotalPrice:  money.Money{Amount: money.Amount(item.GetTotalPrice())},
+			NmID:        int(item.GetNmId()),
+			Brand:       item.GetBrand(),
+			Status:      int(item.GetStatus()),
+		})
+	}
+	out.Denominate()
+	return out
+}
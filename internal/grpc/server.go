@@ -0,0 +1,257 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+
+	"order-service/internal/auth"
+	"order-service/internal/events"
+	"order-service/internal/grpc/orderpb"
+	"order-service/internal/masking"
+	"order-service/internal/models"
+	"order-service/internal/nats"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type CacheService interface {
+	Get(orderUID string) (*models.Order, bool)
+	GetAll() []models.Order
+}
+
+// EventSource provides the live order feed.
+type EventSource interface {
+	Subscribe(filter models.OrderFilter, lastID uint64) (*events.Subscription, bool)
+}
+
+// Submitter stores orders the same way as orders received from NATS.
+type Submitter interface {
+	Process(ctx context.Context, order *models.Order) error
+}
+
+// Server implements orderpb.OrderService on top of the order cache.
+type Server struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	cache   CacheService
+	events  EventSource
+	submit  Submitter
+	masking masking.Policy
+	role    masking.Role
+	auth    auth.Authenticator
+
+	grpc   *grpc.Server
+	health *health.Server
+}
+
+// Option configures optional Server dependencies.
+type Option func(*Server)
+
+// WithEvents enables StreamOrders.
+func WithEvents(source EventSource) Option {
+	return func(s *Server) {
+		s.events = source
+	}
+}
+
+// WithSubmitter enables SubmitOrder.
+func WithSubmitter(submit Submitter) Option {
+	return func(s *Server) {
+		s.submit = submit
+	}
+}
+
+// WithMasking sets the PII masking policy and the role applied to orders
+// returned to callers without a role of their own.
+func WithMasking(policy masking.Policy, role masking.Role) Option {
+	return func(s *Server) {
+		s.masking = policy
+		s.role = role
+	}
+}
+
+// WithAuth requires every order RPC to be authenticated by authn and to
+// carry the scope the HTTP API requires for the same operation.
+func WithAuth(authn auth.Authenticator) Option {
+	return func(s *Server) {
+		s.auth = authn
+	}
+}
+
+func NewServer(cache CacheService, opts ...Option) *Server {
+	s := &Server{
+		cache:   cache,
+		masking: masking.DefaultPolicy(),
+		role:    masking.RolePublic,
+		health:  health.NewServer(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryLogging, s.unaryAuth),
+		grpc.ChainStreamInterceptor(streamLogging, s.streamAuth),
+	)
+	orderpb.RegisterOrderServiceServer(s.grpc, s)
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	reflection.Register(s.grpc)
+	s.health.SetServingStatus(orderpb.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	return s
+}
+
+func (s *Server) Start(port string) error {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return fmt.Errorf("failed to listen on port %s: %w", port, err)
+	}
+	slog.Info("gRPC server starting", "port", port)
+	return s.Serve(lis)
+}
+
+// Serve accepts connections on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Stop marks the service as not serving and waits for in-flight calls.
+// Streams are cancelled.
+func (s *Server) Stop() {
+	s.health.Shutdown()
+	s.grpc.GracefulStop()
+}
+
+// render masks order for the caller's role.
+func (s *Server) render(ctx context.Context, order *models.Order) *orderpb.Order {
+	role := s.role
+	if r, ok := masking.RoleFromContext(ctx); ok {
+		role = r
+	}
+	return orderpb.FromModel(s.masking.Apply(order, role))
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+
+	order, exists := s.cache.Get(req.GetOrderUid())
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "order %s not found", req.GetOrderUid())
+	}
+	return s.render(ctx, order), nil
+}
+
+func (s *Server) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	size := int(req.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+
+	// Page tokens are offsets into the sorted listing.
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+	}
+
+	filter := filterFromProto(req.GetFilter())
+	all := s.cache.GetAll()
+	matched := make([]*models.Order, 0, len(all))
+	for i := range all {
+		if filter.Match(&all[i]) {
+			matched = append(matched, &all[i])
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].DateCreated.Equal(matched[j].DateCreated) {
+			return matched[i].DateCreated.After(matched[j].DateCreated)
+		}
+		return matched[i].OrderUID < matched[j].OrderUID
+	})
+
+	resp := &orderpb.ListOrdersResponse{TotalSize: int32(len(matched))}
+	for i := offset; i < len(matched) && i < offset+size; i++ {
+		resp.Orders = append(resp.Orders, s.render(ctx, matched[i]))
+	}
+	if offset+size < len(matched) {
+		resp.NextPageToken = strconv.Itoa(offset + size)
+	}
+	return resp, nil
+}
+
+func (s *Server) StreamOrders(req *orderpb.StreamOrdersRequest, stream orderpb.OrderService_StreamOrdersServer) error {
+	if s.events == nil {
+		return status.Error(codes.Unimplemented, "live feed is not configured")
+	}
+
+	sub, complete := s.events.Subscribe(filterFromProto(req.GetFilter()), req.GetLastEventId())
+	defer sub.Close()
+
+	missed := !complete
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "subscriber fell behind")
+			}
+			err := stream.Send(&orderpb.OrderEvent{
+				Id:           ev.ID,
+				Type:         ev.Type,
+				Time:         timestamppb.New(ev.Time),
+				Order:        s.render(stream.Context(), ev.Order),
+				MissedEvents: missed,
+			})
+			if err != nil {
+				return err
+			}
+			missed = false
+		}
+	}
+}
+
+func (s *Server) SubmitOrder(ctx context.Context, req *orderpb.SubmitOrderRequest) (*orderpb.SubmitOrderResponse, error) {
+	if s.submit == nil {
+		return nil, status.Error(codes.Unimplemented, "order submission is not configured")
+	}
+	if req.GetOrder() == nil {
+		return nil, status.Error(codes.InvalidArgument, "order is required")
+	}
+
+	order := orderpb.ToModel(req.GetOrder())
+	if err := s.submit.Process(ctx, order); err != nil {
+		if errors.Is(err, nats.ErrInvalidOrder) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		slog.ErrorContext(ctx, "Failed to process submitted order", "order_uid", order.OrderUID, "error", err)
+		return nil, status.Error(codes.Internal, "failed to store order")
+	}
+
+	slog.InfoContext(ctx, "Order submitted via gRPC", "order_uid", order.OrderUID)
+	return &orderpb.SubmitOrderResponse{OrderUid: order.OrderUID}, nil
+}
@@ -0,0 +1,419 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/events"
+	"order-service/internal/grpc/orderpb"
+	"order-service/internal/masking"
+	"order-service/internal/models"
+	"order-service/internal/money"
+	"order-service/internal/nats"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type mockCache struct {
+	mu     sync.RWMutex
+	orders map[string]*models.Order
+}
+
+func newMockCache() *mockCache {
+	return &mockCache{orders: make(map[string]*models.Order)}
+}
+
+func (m *mockCache) Get(orderUID string) (*models.Order, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	order, ok := m.orders[orderUID]
+	return order, ok
+}
+
+func (m *mockCache) GetAll() []models.Order {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	orders := make([]models.Order, 0, len(m.orders))
+	for _, order := range m.orders {
+		orders = append(orders, *order)
+	}
+	return orders
+}
+
+func (m *mockCache) Set(orderUID string, order *models.Order) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orders[orderUID] = order
+}
+
+type mockRepo struct {
+	err   error
+	saved []string
+}
+
+func (m *mockRepo) SaveOrder(ctx context.Context, order *models.Order) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.saved = append(m.saved, order.OrderUID)
+	return nil
+}
+
+// dial starts server on an in-process listener and returns a connected
+// client.
+func dial(t *testing.T, server *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func testOrders() *mockCache {
+	cache := newMockCache()
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		uid := fmt.Sprintf("order%d", i)
+		order := &models.Order{
+			OrderUID:        uid,
+			TrackNumber:     "TRACK",
+			Entry:           "WBIL",
+			DeliveryService: []string{"meest", "cdek"}[i%2],
+			DateCreated:     base.Add(time.Duration(i) * time.Hour),
+			Delivery:        models.Delivery{Name: "Test Testov", Phone: "+79990000000"},
+			Payment:         models.Payment{Currency: "USD", Amount: money.New(1817, "USD")},
+			Items:           []models.Item{{ChrtID: 9934930, Price: money.New(453, "USD"), NmID: 2389212}},
+		}
+		order.Denominate()
+		cache.Set(uid, order)
+	}
+	return cache
+}
+
+func TestGetOrder(t *testing.T) {
+	server := NewServer(testOrders(), WithMasking(masking.DefaultPolicy(), masking.RoleSupport))
+	client := orderpb.NewOrderServiceClient(dial(t, server))
+
+	order, err := client.GetOrder(context.Background(), &orderpb.GetOrderRequest{OrderUid: "order1"})
+	if err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if order.GetOrderUid() != "order1" || order.GetPayment().GetAmount() != 1817 {
+		t.Errorf("Unexpected order: %v", order)
+	}
+	if order.GetDelivery().GetName() != "Test Testov" {
+		t.Errorf("Expected support role to see the name, got %q", order.GetDelivery().GetName())
+	}
+	if len(order.GetItems()) != 1 || order.GetItems()[0].GetNmId() != 2389212 {
+		t.Errorf("Unexpected items: %v", order.GetItems())
+	}
+	if !order.GetDateCreated().AsTime().Equal(time.Date(2024, 3, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected date_created: %v", order.GetDateCreated().AsTime())
+	}
+
+	_, err = client.GetOrder(context.Background(), &orderpb.GetOrderRequest{OrderUid: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestGetOrderMasksPII(t *testing.T) {
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(testOrders())))
+
+	order, err := client.GetOrder(context.Background(), &orderpb.GetOrderRequest{OrderUid: "order1"})
+	if err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if order.GetDelivery().GetName() != "" || order.GetDelivery().GetPhone() != "" {
+		t.Errorf("Expected PII to be redacted for the public role, got %v", order.GetDelivery())
+	}
+}
+
+func TestListOrdersPaging(t *testing.T) {
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(testOrders())))
+
+	var uids []string
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("Too many pages")
+		}
+		resp, err := client.ListOrders(context.Background(), &orderpb.ListOrdersRequest{PageSize: 2, PageToken: token})
+		if err != nil {
+			t.Fatalf("ListOrders failed: %v", err)
+		}
+		if resp.GetTotalSize() != 5 {
+			t.Errorf("Expected total_size 5, got %d", resp.GetTotalSize())
+		}
+		for _, o := range resp.GetOrders() {
+			uids = append(uids, o.GetOrderUid())
+		}
+		if token = resp.GetNextPageToken(); token == "" {
+			break
+		}
+	}
+
+	want := []string{"order4", "order3", "order2", "order1", "order0"}
+	if fmt.Sprint(uids) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, uids)
+	}
+}
+
+func TestListOrdersFilter(t *testing.T) {
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(testOrders())))
+
+	resp, err := client.ListOrders(context.Background(), &orderpb.ListOrdersRequest{
+		Filter: &orderpb.OrderFilter{DeliveryService: "cdek"},
+	})
+	if err != nil {
+		t.Fatalf("ListOrders failed: %v", err)
+	}
+	if resp.GetTotalSize() != 2 || len(resp.GetOrders()) != 2 {
+		t.Errorf("Expected 2 cdek orders, got %d", resp.GetTotalSize())
+	}
+
+	_, err = client.ListOrders(context.Background(), &orderpb.ListOrdersRequest{PageToken: "bogus"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a bad page token, got %v", err)
+	}
+}
+
+func TestStreamOrders(t *testing.T) {
+	broker := events.NewBroker(10, 10)
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(newMockCache(), WithEvents(broker))))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.StreamOrders(ctx, &orderpb.StreamOrdersRequest{
+		Filter: &orderpb.OrderFilter{DeliveryService: "cdek"},
+	})
+	if err != nil {
+		t.Fatalf("StreamOrders failed: %v", err)
+	}
+
+	// Wait for the subscription before publishing.
+	for broker.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	broker.PublishOrder(&models.Order{OrderUID: "skipped", DeliveryService: "meest"})
+	broker.PublishOrder(&models.Order{OrderUID: "streamed", DeliveryService: "cdek"})
+
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if ev.GetOrder().GetOrderUid() != "streamed" || ev.GetId() != 2 || ev.GetType() != events.TypeOrderStored {
+		t.Errorf("Unexpected event: %v", ev)
+	}
+}
+
+func TestStreamOrdersResume(t *testing.T) {
+	broker := events.NewBroker(1, 10)
+	for _, uid := range []string{"first", "second", "third"} {
+		broker.PublishOrder(&models.Order{OrderUID: uid})
+	}
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(newMockCache(), WithEvents(broker))))
+
+	// Only event 3 is retained, so resuming after event 1 misses event 2.
+	for lastID, missed := range map[uint64]bool{1: true, 2: false} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		stream, err := client.StreamOrders(ctx, &orderpb.StreamOrdersRequest{LastEventId: lastID})
+		if err != nil {
+			t.Fatalf("StreamOrders failed: %v", err)
+		}
+		ev, err := stream.Recv()
+		cancel()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if ev.GetId() != 3 || ev.GetMissedEvents() != missed {
+			t.Errorf("After %d: expected event 3 with missed_events=%v, got %v", lastID, missed, ev)
+		}
+	}
+}
+
+// testAuth accepts "reader-key" with orders:read and the support role, and
+// "writer-key" with orders:write.
+func testAuth(t *testing.T) Option {
+	t.Helper()
+	keys, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("reader-key"), Scopes: []string{auth.ScopeOrdersRead}, Role: masking.RoleSupport},
+		{Name: "writer", Hash: auth.HashAPIKey("writer-key"), Scopes: []string{auth.ScopeOrdersWrite}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return WithAuth(keys)
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+}
+
+func TestAuthRequired(t *testing.T) {
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(testOrders(), testAuth(t))))
+
+	_, err := client.GetOrder(context.Background(), &orderpb.GetOrderRequest{OrderUid: "order1"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without credentials, got %v", err)
+	}
+	_, err = client.GetOrder(withKey("wrong-key"), &orderpb.GetOrderRequest{OrderUid: "order1"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated for an unknown key, got %v", err)
+	}
+	_, err = client.SubmitOrder(withKey("reader-key"), &orderpb.SubmitOrderRequest{Order: &orderpb.Order{OrderUid: "x"}})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied without orders:write, got %v", err)
+	}
+
+	stream, err := client.StreamOrders(withKey("writer-key"), &orderpb.StreamOrdersRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for a stream without orders:read, got %v", err)
+	}
+}
+
+func TestAuthMasksForCallerRole(t *testing.T) {
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(testOrders(), testAuth(t))))
+
+	order, err := client.GetOrder(withKey("reader-key"), &orderpb.GetOrderRequest{OrderUid: "order1"})
+	if err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if order.GetDelivery().GetName() != "Test Testov" {
+		t.Errorf("Expected the caller's support role to see the name, got %q", order.GetDelivery().GetName())
+	}
+}
+
+func TestSubmitOrderRequiresAuthConfig(t *testing.T) {
+	cache := newMockCache()
+	repo := &mockRepo{}
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(cache, WithSubmitter(nats.NewPipeline(repo, cache)))))
+
+	_, err := client.SubmitOrder(context.Background(), &orderpb.SubmitOrderRequest{Order: &orderpb.Order{
+		OrderUid: "valid", TrackNumber: "TRACK", Entry: "WBIL",
+		Payment: &orderpb.Payment{Currency: "USD"},
+	}})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied without an authenticator, got %v", err)
+	}
+	if len(repo.saved) != 0 {
+		t.Errorf("Expected nothing to be saved, got %v", repo.saved)
+	}
+}
+
+func TestSubmitOrder(t *testing.T) {
+	cache := newMockCache()
+	repo := &mockRepo{}
+	server := NewServer(cache, WithSubmitter(nats.NewPipeline(repo, cache)), testAuth(t))
+	client := orderpb.NewOrderServiceClient(dial(t, server))
+
+	resp, err := client.SubmitOrder(withKey("writer-key"), &orderpb.SubmitOrderRequest{Order: &orderpb.Order{
+		OrderUid:    "submitted",
+		TrackNumber: "TRACK",
+		Entry:       "WBIL",
+		Payment:     &orderpb.Payment{Currency: "USD", Amount: 1817},
+	}})
+	if err != nil {
+		t.Fatalf("SubmitOrder failed: %v", err)
+	}
+	if resp.GetOrderUid() != "submitted" {
+		t.Errorf("Unexpected response: %v", resp)
+	}
+	if len(repo.saved) != 1 {
+		t.Errorf("Expected the order to be saved, got %v", repo.saved)
+	}
+	stored, ok := cache.Get("submitted")
+	if !ok || stored.Payment.Amount != money.New(1817, "USD") || stored.DateCreated.IsZero() {
+		t.Errorf("Expected the order to be cached with a creation date, got %+v", stored)
+	}
+}
+
+func TestSubmitOrderErrors(t *testing.T) {
+	cache := newMockCache()
+	repo := &mockRepo{}
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(cache, WithSubmitter(nats.NewPipeline(repo, cache)), testAuth(t))))
+	ctx := withKey("writer-key")
+
+	_, err := client.SubmitOrder(ctx, &orderpb.SubmitOrderRequest{Order: &orderpb.Order{OrderUid: "incomplete"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an invalid order, got %v", err)
+	}
+	if len(repo.saved) != 0 {
+		t.Error("Expected invalid order not to be saved")
+	}
+
+	repo.err = errors.New("connection refused")
+	_, err = client.SubmitOrder(ctx, &orderpb.SubmitOrderRequest{Order: &orderpb.Order{
+		OrderUid: "valid", TrackNumber: "TRACK", Entry: "WBIL",
+		Payment: &orderpb.Payment{Currency: "USD"},
+	}})
+	if status.Code(err) != codes.Internal {
+		t.Errorf("Expected Internal when saving fails, got %v", err)
+	}
+	if _, ok := cache.Get("valid"); ok {
+		t.Error("Expected unsaved order not to be cached")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	client := healthpb.NewHealthClient(dial(t, NewServer(newMockCache())))
+
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: orderpb.OrderService_ServiceDesc.ServiceName,
+	})
+	if err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected SERVING, got %v", resp.GetStatus())
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	order := testOrders().orders["order2"]
+	order.Payment.PaymentDt = 1637907727
+	order.SmID = 99
+
+	back := orderpb.ToModel(orderpb.FromModel(order))
+	if fmt.Sprintf("%+v", back) != fmt.Sprintf("%+v", order) {
+		t.Errorf("Round trip changed the order:\n got %+v\nwant %+v", back, order)
+	}
+}
+
+func TestRequestIDEchoed(t *testing.T) {
+	client := orderpb.NewOrderServiceClient(dial(t, NewServer(testOrders())))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIDKey, "req-123")
+	var header metadata.MD
+	if _, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderUid: "order1"}, grpc.Header(&header)); err != nil {
+		t.Fatalf("GetOrder failed: %v", err)
+	}
+	if got := header.Get(requestIDKey); len(got) != 1 || got[0] != "req-123" {
+		t.Errorf("Expected request ID to be echoed, got %v", got)
+	}
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	"order-service/internal/auth"
+	"order-service/internal/nats"
+	"order-service/internal/reconcile"
+)
+
+// SubscriptionControl reports the state of the NATS subscription and
+// pauses or resumes it.
+type SubscriptionControl interface {
+	State() nats.SubscriptionState
+	Pause() error
+	Resume() error
+}
+
+// WithSubscription enables the subscription endpoints of the admin API.
+func WithSubscription(control SubscriptionControl) Option {
+	return func(s *Server) {
+		s.subscription = control
+	}
+}
+
+// Reconciler compares the cache with the database.
+type Reconciler interface {
+	Last() *reconcile.Report
+	Reconcile(ctx context.Context, repair bool) (*reconcile.Report, error)
+}
+
+// WithReconciler enables the reconciliation endpoint of the admin API.
+func WithReconciler(r Reconciler) Option {
+	return func(s *Server) {
+		s.reconciler = r
+	}
+}
+
+// WithRuntimeConfig serves cfg at GET /admin/config. It is encoded as is,
+// so secrets must already be redacted.
+func WithRuntimeConfig(cfg interface{}) Option {
+	return func(s *Server) {
+		s.runtimeConfig = cfg
+	}
+}
+
+// StartAdmin serves AdminHandler on addr, which should only be reachable
+// by operators.
+func (s *Server) StartAdmin(addr string) error {
+	slog.Info("Admin HTTP server starting", "addr", addr)
+	return http.ListenAndServe(addr, s.AdminHandler())
+}
+
+// AdminHandler returns the handler of the admin listener: cache control and
+// reconciliation, runtime configuration, subscription control, expvar
+// metrics and profiling. Every route requires the admin scope, and without
+// an authenticator every request is refused.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("/admin/cache/stats", s.protectAdmin(s.handleCacheStats))
+	mux.Handle("/admin/cache/reload", s.protectAdmin(s.handleCacheReload))
+	mux.Handle("/admin/cache/", s.protectAdmin(s.handleCacheEntry))
+	mux.Handle("/admin/reconcile", s.protectAdmin(s.handleReconcile))
+	mux.Handle("/admin/config", s.protectAdmin(s.handleConfig))
+	mux.Handle("/admin/subscription", s.protectAdmin(s.handleSubscription))
+	mux.Handle("/admin/subscription/pause", s.protectAdmin(s.handleSubscriptionPause))
+	mux.Handle("/admin/subscription/resume", s.protectAdmin(s.handleSubscriptionResume))
+
+	mux.Handle("/debug/vars", s.protectAdmin(expvar.Handler().ServeHTTP))
+	// pprof.Index serves the named profiles such as heap and goroutine
+	mux.Handle("/debug/pprof/", s.protectAdmin(pprof.Index))
+	mux.Handle("/debug/pprof/cmdline", s.protectAdmin(pprof.Cmdline))
+	mux.Handle("/debug/pprof/profile", s.protectAdmin(pprof.Profile))
+	mux.Handle("/debug/pprof/symbol", s.protectAdmin(pprof.Symbol))
+	mux.Handle("/debug/pprof/trace", s.protectAdmin(pprof.Trace))
+
+	return s.loggingMiddleware(mux)
+}
+
+// protectAdmin is protect with the admin scope, except that a missing
+// authenticator refuses requests instead of letting them through.
+func (s *Server) protectAdmin(next http.HandlerFunc) http.Handler {
+	if s.auth == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSONError(w, http.StatusUnauthorized, "admin API requires authentication to be configured")
+		})
+	}
+	return s.protect(auth.ScopeAdmin, next)
+}
+
+// handleReconcile serves GET /admin/reconcile with the latest report and
+// POST /admin/reconcile[?repair=true], which runs a reconciliation.
+func (s *Server) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if s.reconciler == nil {
+		http.Error(w, "Reconciliation is not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		report := s.reconciler.Last()
+		if report == nil {
+			http.Error(w, "No reconciliation has run yet", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, report)
+
+	case http.MethodPost:
+		repair := false
+		if value := r.URL.Query().Get("repair"); value != "" {
+			var err error
+			if repair, err = strconv.ParseBool(value); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid repair: "+value)
+				return
+			}
+		}
+		report, err := s.reconciler.Reconcile(r.Context(), repair)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "failed to reconcile: "+err.Error())
+			return
+		}
+		writeJSON(w, report)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleConfig serves GET /admin/config with the service configuration.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if s.runtimeConfig == nil {
+		http.Error(w, "Configuration is not available", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, s.runtimeConfig)
+}
+
+// handleSubscription serves GET /admin/subscription with the state of the
+// NATS subscription.
+func (s *Server) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	if s.subscription == nil {
+		http.Error(w, "Subscription is not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, s.subscription.State())
+}
+
+// handleSubscriptionPause serves POST /admin/subscription/pause.
+func (s *Server) handleSubscriptionPause(w http.ResponseWriter, r *http.Request) {
+	s.controlSubscription(w, r, "pause", func() error { return s.subscription.Pause() })
+}
+
+// handleSubscriptionResume serves POST /admin/subscription/resume.
+func (s *Server) handleSubscriptionResume(w http.ResponseWriter, r *http.Request) {
+	s.controlSubscription(w, r, "resume", func() error { return s.subscription.Resume() })
+}
+
+// controlSubscription runs a pause or resume and answers with the new
+// state. Both are idempotent.
+func (s *Server) controlSubscription(w http.ResponseWriter, r *http.Request, action string, fn func() error) {
+	if s.subscription == nil {
+		http.Error(w, "Subscription is not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := fn(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to "+action+" subscription", "error", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, nats.ErrNotSubscribed) {
+			status = http.StatusConflict
+		}
+		writeJSONError(w, status, "failed to "+action+" subscription: "+err.Error())
+		return
+	}
+	writeJSON(w, s.subscription.State())
+}
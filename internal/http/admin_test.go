@@ -0,0 +1,219 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/auth"
+	"order-service/internal/nats"
+	"order-service/internal/reconcile"
+)
+
+func newAdminServer(t *testing.T, cache CacheService, opts ...Option) *Server {
+	t.Helper()
+	keys, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("reader-key"), Scopes: []string{auth.ScopeOrdersRead}},
+		{Name: "admin", Hash: auth.HashAPIKey("admin-key"), Scopes: []string{auth.ScopeAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(cache, append(opts, WithAuth(keys))...)
+}
+
+// adminRequest returns a request carrying the admin key of newAdminServer.
+func adminRequest(method, path string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", "admin-key")
+	return req
+}
+
+type mockSubscription struct {
+	state nats.SubscriptionState
+	err   error
+}
+
+func (m *mockSubscription) State() nats.SubscriptionState { return m.state }
+
+func (m *mockSubscription) Pause() error {
+	if m.err != nil {
+		return m.err
+	}
+	m.state.Paused = true
+	return nil
+}
+
+func (m *mockSubscription) Resume() error {
+	if m.err != nil {
+		return m.err
+	}
+	m.state.Paused = false
+	return nil
+}
+
+func TestAdminRequiresAuth(t *testing.T) {
+	handler := newAdminServer(t, newMockCache(), WithRuntimeConfig(map[string]string{})).AdminHandler()
+
+	tests := []struct {
+		name string
+		key  string
+		want int
+	}{
+		{"no credentials", "", http.StatusUnauthorized},
+		{"without admin scope", "reader-key", http.StatusForbidden},
+		{"admin", "admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"/admin/config", "/debug/vars", "/debug/pprof/"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("%s %s: expected status %d, got %d", tt.name, path, tt.want, w.Code)
+			}
+		}
+	}
+}
+
+func TestAdminWithoutAuthenticator(t *testing.T) {
+	handler := NewServer(newMockCache(), WithRuntimeConfig(map[string]string{})).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without an authenticator, got %d", w.Code)
+	}
+}
+
+func TestHandleConfig(t *testing.T) {
+	cfg := struct{ DBPassword string }{"REDACTED"}
+	handler := newAdminServer(t, newMockCache(), WithRuntimeConfig(cfg)).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/config"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var body map[string]string
+	json.NewDecoder(w.Body).Decode(&body)
+	if body["DBPassword"] != "REDACTED" {
+		t.Errorf("Unexpected config %v", body)
+	}
+}
+
+func TestHandleSubscription(t *testing.T) {
+	sub := &mockSubscription{state: nats.SubscriptionState{Subject: "orders", Connected: true}}
+	handler := newAdminServer(t, newMockCache(), WithSubscription(sub)).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/subscription/pause"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var state nats.SubscriptionState
+	json.NewDecoder(w.Body).Decode(&state)
+	if !state.Paused || state.Subject != "orders" {
+		t.Errorf("Expected a paused subscription, got %+v", state)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/subscription/resume"))
+	if w.Code != http.StatusOK || sub.state.Paused {
+		t.Errorf("Expected the subscription to resume, got status %d and %+v", w.Code, sub.state)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/subscription/pause"))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", w.Code)
+	}
+
+	sub.err = nats.ErrNotSubscribed
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/subscription/resume"))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 without a subscription, got %d", w.Code)
+	}
+
+	sub.err = errors.New("connection closed")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/subscription/pause"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for a failed pause, got %d", w.Code)
+	}
+}
+
+func TestHandleSubscriptionNotConfigured(t *testing.T) {
+	handler := newAdminServer(t, newMockCache()).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/subscription"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+type mockReconciler struct {
+	last   *reconcile.Report
+	repair bool
+	err    error
+}
+
+func (m *mockReconciler) Last() *reconcile.Report { return m.last }
+
+func (m *mockReconciler) Reconcile(_ context.Context, repair bool) (*reconcile.Report, error) {
+	m.repair = repair
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.last = &reconcile.Report{Repair: repair, Differing: 1, Drifts: []reconcile.Drift{{OrderUID: "a", Problem: reconcile.Differs}}}
+	return m.last, nil
+}
+
+func TestHandleReconcile(t *testing.T) {
+	rec := &mockReconciler{}
+	handler := newAdminServer(t, newMockCache(), WithReconciler(rec)).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/reconcile"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 before the first run, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/reconcile?repair=true"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var report reconcile.Report
+	json.NewDecoder(w.Body).Decode(&report)
+	if !rec.repair || report.Differing != 1 || len(report.Drifts) != 1 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/reconcile"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for the last report, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/reconcile?repair=maybe"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid repair, got %d", w.Code)
+	}
+
+	rec.err = errors.New("connection refused")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/reconcile"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for a failed run, got %d", w.Code)
+	}
+}
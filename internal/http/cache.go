@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"order-service/internal/cache"
+)
+
+// CacheControl reloads the order cache from the database, drops single
+// entries from it and reports its statistics.
+type CacheControl interface {
+	Reload(ctx context.Context) (int, error)
+	Delete(orderUID string) bool
+	Stats() cache.Stats
+}
+
+// WithCacheControl enables the cache endpoints of the admin API.
+func WithCacheControl(control CacheControl) Option {
+	return func(s *Server) {
+		s.cacheControl = control
+	}
+}
+
+// handleCacheStats serves GET /admin/cache/stats with the cache statistics.
+func (s *Server) handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	if s.cacheControl == nil {
+		http.Error(w, "Cache control is not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, s.cacheControl.Stats())
+}
+
+// handleCacheReload serves POST /admin/cache/reload, which replaces the
+// cache contents with the orders in the database.
+func (s *Server) handleCacheReload(w http.ResponseWriter, r *http.Request) {
+	if s.cacheControl == nil {
+		http.Error(w, "Cache control is not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entries, err := s.cacheControl.Reload(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to reload cache", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to reload cache")
+		return
+	}
+	slog.InfoContext(r.Context(), "Reloaded cache", "entries", entries)
+	writeJSON(w, map[string]int{"entries": entries})
+}
+
+// handleCacheEntry serves DELETE /admin/cache/{orderUID}, which drops one
+// order from the cache until it is stored or reloaded again.
+func (s *Server) handleCacheEntry(w http.ResponseWriter, r *http.Request) {
+	if s.cacheControl == nil {
+		http.Error(w, "Cache control is not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	orderUID := strings.TrimPrefix(r.URL.Path, "/admin/cache/")
+	if orderUID == "" {
+		http.Error(w, "Order UID required", http.StatusBadRequest)
+		return
+	}
+	if !s.cacheControl.Delete(orderUID) {
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	}
+	slog.InfoContext(r.Context(), "Removed order from cache", "order_uid", orderUID)
+	w.WriteHeader(http.StatusNoContent)
+}
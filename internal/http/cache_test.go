@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/cache"
+	"order-service/internal/models"
+)
+
+// mockCacheControl reloads a mockCache from a fixed set of orders.
+type mockCacheControl struct {
+	cache  *mockCache
+	stored []*models.Order
+	err    error
+}
+
+func (m *mockCacheControl) Reload(context.Context) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.cache.orders = make(map[string]*models.Order)
+	for _, order := range m.stored {
+		m.cache.Set(order.OrderUID, order)
+	}
+	return len(m.cache.orders), nil
+}
+
+func (m *mockCacheControl) Delete(orderUID string) bool {
+	_, ok := m.cache.orders[orderUID]
+	delete(m.cache.orders, orderUID)
+	return ok
+}
+
+func (m *mockCacheControl) Stats() cache.Stats {
+	version, lastModified := m.cache.Version()
+	return cache.Stats{Entries: m.cache.Size(), Hits: 3, Misses: 1, HitRatio: 0.75, Version: version, LastModified: lastModified}
+}
+
+func TestHandleCacheStats(t *testing.T) {
+	orders := newMockCache()
+	orders.Set("order1", &models.Order{OrderUID: "order1"})
+	handler := newAdminServer(t, orders, WithCacheControl(&mockCacheControl{cache: orders})).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/cache/stats"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var stats cache.Stats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 1 || stats.Version != 1 || stats.HitRatio != 0.75 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestHandleCacheReload(t *testing.T) {
+	orders := newMockCache()
+	orders.Set("stale", &models.Order{OrderUID: "stale"})
+	control := &mockCacheControl{cache: orders, stored: []*models.Order{{OrderUID: "a"}, {OrderUID: "b"}}}
+	handler := newAdminServer(t, orders, WithCacheControl(control)).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/cache/reload"))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var body map[string]int
+	json.NewDecoder(w.Body).Decode(&body)
+	if body["entries"] != 2 {
+		t.Errorf("Expected 2 entries, got %v", body)
+	}
+	if _, ok := orders.Get("stale"); ok {
+		t.Error("Expected the stale entry to be gone after reload")
+	}
+
+	control.err = errors.New("connection refused")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodPost, "/admin/cache/reload"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for a failed reload, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodGet, "/admin/cache/reload"))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", w.Code)
+	}
+}
+
+func TestHandleCacheDelete(t *testing.T) {
+	orders := newMockCache()
+	orders.Set("order1", &models.Order{OrderUID: "order1"})
+	handler := newAdminServer(t, orders, WithCacheControl(&mockCacheControl{cache: orders})).AdminHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodDelete, "/admin/cache/order1"))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	if _, ok := orders.Get("order1"); ok {
+		t.Error("Expected order1 to be removed from the cache")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest(http.MethodDelete, "/admin/cache/order1"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an uncached order, got %d", w.Code)
+	}
+}
+
+func TestHandleCacheControlNotConfigured(t *testing.T) {
+	handler := newAdminServer(t, newMockCache()).AdminHandler()
+
+	for _, req := range []*http.Request{
+		adminRequest(http.MethodGet, "/admin/cache/stats"),
+		adminRequest(http.MethodPost, "/admin/cache/reload"),
+		adminRequest(http.MethodDelete, "/admin/cache/order1"),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected status 404, got %d", req.Method, req.URL.Path, w.Code)
+		}
+	}
+}
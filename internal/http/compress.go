@@ -0,0 +1,155 @@
+package http
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minCompressSize is the smallest response worth compressing.
+const minCompressSize = 1024
+
+// negotiateEncoding picks gzip or deflate from Accept-Encoding, preferring
+// the higher q-value and gzip on ties.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if name != "gzip" && name != "deflate" || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && name == "gzip") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter compresses the response body once it is known to be large
+// enough. Small bodies, 304s and event streams are written unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	w        io.WriteCloser
+	decided  bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	cw.status = status
+	h := cw.Header()
+	if status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" ||
+		strings.HasPrefix(h.Get("Content-Type"), "text/event-stream") {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.w != nil {
+			return cw.w.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= minCompressSize {
+		cw.decide(true)
+	}
+	return len(p), nil
+}
+
+// decide commits to compressing or not and flushes anything buffered.
+func (cw *compressWriter) decide(compress bool) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+
+	h := cw.Header()
+	if compress {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); strings.HasSuffix(etag, `"`) {
+			h.Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+cw.encoding+`"`)
+		}
+		if cw.encoding == "gzip" {
+			cw.w = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.w, _ = flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+		}
+	}
+
+	status := cw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(status)
+
+	if len(cw.buf) > 0 {
+		if cw.w != nil {
+			cw.w.Write(cw.buf)
+		} else {
+			cw.ResponseWriter.Write(cw.buf)
+		}
+		cw.buf = nil
+	}
+}
+
+func (cw *compressWriter) Flush() {
+	cw.decide(len(cw.buf) >= minCompressSize)
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) close() {
+	if cw.status == 0 && len(cw.buf) == 0 {
+		return
+	}
+	cw.decide(false)
+	if cw.w != nil {
+		cw.w.Close()
+	}
+}
+
+// compressionMiddleware negotiates gzip or deflate via Accept-Encoding.
+func (s *Server) compressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
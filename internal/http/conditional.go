@@ -0,0 +1,116 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// contentETag returns a strong ETag derived from the body's hash.
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// normalizeETag strips the weak prefix and the suffix added by the
+// compression middleware so that any representation of a resource matches.
+func normalizeETag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	for _, enc := range []string{"-gzip", "-deflate"} {
+		if strings.HasSuffix(tag, enc+`"`) {
+			return strings.TrimSuffix(tag, enc+`"`) + `"`
+		}
+	}
+	return tag
+}
+
+// notModified evaluates If-None-Match and, failing that, If-Modified-Since.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || normalizeETag(tag) == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err == nil && !lastModified.Truncate(time.Second).After(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCacheable writes a JSON body with validators, answering 304 when the
+// client already holds the current representation.
+func writeCacheable(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time, body []byte) {
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	// Responses depend on the caller's role, so shared caches must not
+	// store them and clients must revalidate.
+	h.Set("Cache-Control", "private, no-cache")
+	h.Add("Vary", "Authorization, X-API-Key")
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// encodeJSON encodes v the same way json.Encoder does for the other handlers.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bodyCache keeps the most recently encoded listing bodies keyed by ETag, so
+// an unchanged cache is not re-encoded on every request.
+type bodyCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	order   []string
+	size    int
+}
+
+func newBodyCache(size int) *bodyCache {
+	return &bodyCache{entries: make(map[string][]byte), size: size}
+}
+
+func (c *bodyCache) get(etag string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	body, ok := c.entries[etag]
+	return body, ok
+}
+
+func (c *bodyCache) put(etag string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[etag]; ok {
+		return
+	}
+	if len(c.order) >= c.size {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[etag] = body
+	c.order = append(c.order, etag)
+}
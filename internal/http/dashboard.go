@@ -0,0 +1,311 @@
+package http
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+)
+
+const (
+	defaultPerPage = 25
+	maxPerPage     = 200
+)
+
+// orderSummary is a row of the dashboard order table.
+type orderSummary struct {
+	OrderUID        string       `json:"order_uid"`
+	DateCreated     time.Time    `json:"date_created"`
+	CustomerID      string       `json:"customer_id"`
+	DeliveryService string       `json:"delivery_service"`
+	Entry           string       `json:"entry"`
+	Currency        string       `json:"currency"`
+	Amount          money.Amount `json:"amount"`
+	AmountFormatted string       `json:"amount_formatted"`
+	Items           int          `json:"items"`
+	Name            string       `json:"name"`
+	City            string       `json:"city"`
+}
+
+type orderPage struct {
+	Items      []orderSummary `json:"items"`
+	Page       int            `json:"page"`
+	PerPage    int            `json:"per_page"`
+	Total      int            `json:"total"`
+	TotalPages int            `json:"total_pages"`
+	Sort       string         `json:"sort"`
+	Order      string         `json:"order"`
+}
+
+// orderSorters compare two orders by a sortable column in ascending order.
+var orderSorters = map[string]func(a, b *models.Order) bool{
+	"date_created": func(a, b *models.Order) bool { return a.DateCreated.Before(b.DateCreated) },
+	"order_uid":    func(a, b *models.Order) bool { return a.OrderUID < b.OrderUID },
+	"customer_id":  func(a, b *models.Order) bool { return a.CustomerID < b.CustomerID },
+	"delivery_service": func(a, b *models.Order) bool {
+		return a.DeliveryService < b.DeliveryService
+	},
+	"amount": func(a, b *models.Order) bool { return a.Payment.Amount.Amount < b.Payment.Amount.Amount },
+	"items":  func(a, b *models.Order) bool { return len(a.Items) < len(b.Items) },
+}
+
+func queryInt(r *http.Request, name string, fallback int) int {
+	v, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || v < 1 {
+		return fallback
+	}
+	return v
+}
+
+// handleOrderPage serves a page of the order table:
+//
+//	GET /api/dashboard/orders?page=1&per_page=25&sort=amount&order=asc
+//
+// together with the listing filters.
+func (s *Server) handleOrderPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	if sortBy == "" {
+		sortBy = "date_created"
+	}
+	less, ok := orderSorters[sortBy]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "unsupported sort column: "+sortBy)
+		return
+	}
+	direction := strings.ToLower(r.URL.Query().Get("order"))
+	if direction == "" {
+		direction = "desc"
+	}
+	if direction != "asc" && direction != "desc" {
+		writeJSONError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	perPage := queryInt(r, "per_page", defaultPerPage)
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	page := queryInt(r, "page", 1)
+
+	all := s.cache.GetAll()
+	matched := make([]*models.Order, 0, len(all))
+	for i := range all {
+		if filter.Match(&all[i]) {
+			matched = append(matched, &all[i])
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if direction == "desc" {
+			return less(matched[j], matched[i])
+		}
+		return less(matched[i], matched[j])
+	})
+
+	resp := orderPage{
+		Items:      []orderSummary{},
+		Page:       page,
+		PerPage:    perPage,
+		Total:      len(matched),
+		TotalPages: (len(matched) + perPage - 1) / perPage,
+		Sort:       sortBy,
+		Order:      direction,
+	}
+
+	start := (page - 1) * perPage
+	for i := start; i < len(matched) && i < start+perPage; i++ {
+		o := s.masking.Apply(matched[i], s.role(r))
+		resp.Items = append(resp.Items, orderSummary{
+			OrderUID:        o.OrderUID,
+			DateCreated:     o.DateCreated,
+			CustomerID:      o.CustomerID,
+			DeliveryService: o.DeliveryService,
+			Entry:           o.Entry,
+			Currency:        o.Payment.Currency,
+			Amount:          o.Payment.Amount.Amount,
+			AmountFormatted: o.Payment.Amount.String(),
+			Items:           len(o.Items),
+			Name:            o.Delivery.Name,
+			City:            o.Delivery.City,
+		})
+	}
+
+	writeJSON(w, resp)
+}
+
+// facets lists the values available for the dashboard filter controls.
+type facets struct {
+	DeliveryServices []string `json:"delivery_services"`
+	Currencies       []string `json:"currencies"`
+	Entries          []string `json:"entries"`
+}
+
+func (s *Server) handleFacets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	services := map[string]bool{}
+	currencies := map[string]bool{}
+	entries := map[string]bool{}
+	for _, o := range s.cache.GetAll() {
+		services[o.DeliveryService] = true
+		currencies[strings.ToUpper(o.Payment.Currency)] = true
+		entries[o.Entry] = true
+	}
+
+	writeJSON(w, facets{
+		DeliveryServices: sortedKeys(services),
+		Currencies:       sortedKeys(currencies),
+		Entries:          sortedKeys(entries),
+	})
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// timeBucket aggregates orders created within [Start, Start+interval).
+type timeBucket struct {
+	Start      time.Time               `json:"start"`
+	Orders     int                     `json:"orders"`
+	Revenue    map[string]money.Amount `json:"revenue"`
+	Reporting  *money.Amount           `json:"reporting_revenue,omitempty"`
+	Incomplete bool                    `json:"reporting_incomplete,omitempty"`
+}
+
+type timeSeries struct {
+	Interval          string       `json:"interval"`
+	ReportingCurrency string       `json:"reporting_currency,omitempty"`
+	Buckets           []timeBucket `json:"buckets"`
+}
+
+func truncateTime(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case "week":
+		offset := (int(day.Weekday()) + 6) % 7 // weeks start on Monday
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// handleTimeSeries serves order volume and revenue over time:
+//
+//	GET /api/dashboard/timeseries?interval=day|week|month
+//
+// Revenue is reported per payment currency and, when a reporting currency is
+// configured, converted as of each order's payment_dt.
+func (s *Server) handleTimeSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	if interval != "day" && interval != "week" && interval != "month" {
+		writeJSONError(w, http.StatusBadRequest, "interval must be day, week or month")
+		return
+	}
+
+	buckets := map[time.Time]*timeBucket{}
+	var first, last time.Time
+	for _, o := range s.cache.GetAll() {
+		if !filter.Match(&o) {
+			continue
+		}
+
+		start := truncateTime(o.DateCreated, interval)
+		b, ok := buckets[start]
+		if !ok {
+			b = &timeBucket{Start: start, Revenue: map[string]money.Amount{}}
+			buckets[start] = b
+		}
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+
+		b.Orders++
+		b.Revenue[strings.ToUpper(o.Payment.Currency)] += o.Payment.Amount.Amount
+
+		if s.rates != nil {
+			converted, err := s.rates.Convert(o.Payment.Amount,
+				s.reportingCurrency, o.Payment.PaidAt(o.DateCreated))
+			if err != nil {
+				b.Incomplete = true
+				continue
+			}
+			if b.Reporting == nil {
+				b.Reporting = new(money.Amount)
+			}
+			*b.Reporting += converted.Amount
+		}
+	}
+
+	resp := timeSeries{Interval: interval, Buckets: []timeBucket{}}
+	if s.rates != nil {
+		resp.ReportingCurrency = s.reportingCurrency
+	}
+
+	// Emit contiguous buckets so that gaps show up as zero on charts
+	if !first.IsZero() {
+		for t := first; !t.After(last); t = nextBucket(t, interval) {
+			if b, ok := buckets[t]; ok {
+				resp.Buckets = append(resp.Buckets, *b)
+			} else {
+				resp.Buckets = append(resp.Buckets, timeBucket{Start: t, Revenue: map[string]money.Amount{}})
+			}
+		}
+	}
+
+	writeJSON(w, resp)
+}
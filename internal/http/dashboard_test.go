@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+)
+
+func dashboardCache() *mockCache {
+	cache := newMockCache()
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		uid := fmt.Sprintf("order%d", i)
+		cache.Set(uid, &models.Order{
+			OrderUID:        uid,
+			CustomerID:      fmt.Sprintf("customer%d", i),
+			DeliveryService: []string{"meest", "cdek"}[i%2],
+			DateCreated:     day.AddDate(0, 0, 2*(i/2)), // days 1, 1, 3, 3, 5
+			Delivery:        models.Delivery{Name: "Secret Name"},
+			Payment:         models.Payment{Currency: "EUR", Amount: money.New(money.Amount(100*(i+1)), "EUR")},
+			Items:           make([]models.Item, i+1),
+		})
+	}
+	return cache
+}
+
+func getDashboard(t *testing.T, server *Server, path string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", path, err)
+		}
+	}
+	return w.Code
+}
+
+func TestOrderPageSortsAndPages(t *testing.T) {
+	server := NewServer(dashboardCache())
+
+	var page orderPage
+	if code := getDashboard(t, server, "/api/dashboard/orders?sort=amount&order=asc&per_page=2&page=2", &page); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+
+	if page.Total != 5 || page.TotalPages != 3 {
+		t.Errorf("Expected 5 orders on 3 pages, got %d on %d", page.Total, page.TotalPages)
+	}
+	if len(page.Items) != 2 || page.Items[0].OrderUID != "order2" || page.Items[1].OrderUID != "order3" {
+		t.Fatalf("Unexpected page contents: %+v", page.Items)
+	}
+	if page.Items[0].Items != 3 || page.Items[0].AmountFormatted != "3.00 EUR" {
+		t.Errorf("Unexpected summary: %+v", page.Items[0])
+	}
+	if page.Items[0].Name != "" {
+		t.Error("Expected PII to be masked for the default role")
+	}
+}
+
+func TestOrderPageFilters(t *testing.T) {
+	server := NewServer(dashboardCache())
+
+	var page orderPage
+	getDashboard(t, server, "/api/dashboard/orders?delivery_service=cdek&sort=order_uid&order=desc", &page)
+
+	if page.Total != 2 || page.Items[0].OrderUID != "order3" || page.Items[1].OrderUID != "order1" {
+		t.Errorf("Unexpected filtered page: %+v", page)
+	}
+}
+
+func TestOrderPageRejectsBadParameters(t *testing.T) {
+	server := NewServer(dashboardCache())
+
+	for _, query := range []string{"sort=phone", "order=sideways", "from=yesterday"} {
+		var page orderPage
+		if code := getDashboard(t, server, "/api/dashboard/orders?"+query, &page); code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, code)
+		}
+	}
+}
+
+func TestFacets(t *testing.T) {
+	server := NewServer(dashboardCache())
+
+	var f facets
+	getDashboard(t, server, "/api/dashboard/facets", &f)
+
+	if len(f.DeliveryServices) != 2 || f.DeliveryServices[0] != "cdek" {
+		t.Errorf("Unexpected delivery services: %v", f.DeliveryServices)
+	}
+	if len(f.Currencies) != 1 || f.Currencies[0] != "EUR" {
+		t.Errorf("Unexpected currencies: %v", f.Currencies)
+	}
+}
+
+func TestTimeSeriesFillsGaps(t *testing.T) {
+	server := NewServer(dashboardCache())
+
+	var series timeSeries
+	getDashboard(t, server, "/api/dashboard/timeseries?interval=day", &series)
+
+	orders := []int{2, 0, 2, 0, 1}
+	if len(series.Buckets) != len(orders) {
+		t.Fatalf("Expected %d daily buckets, got %d", len(orders), len(series.Buckets))
+	}
+	for i, want := range orders {
+		if got := series.Buckets[i].Orders; got != want {
+			t.Errorf("Bucket %d: expected %d orders, got %d", i, want, got)
+		}
+	}
+	if got := series.Buckets[0].Revenue["EUR"]; got != 300 {
+		t.Errorf("Expected EUR revenue 300 on day 1, got %d", got)
+	}
+	if series.Buckets[0].Reporting != nil {
+		t.Error("Expected no reporting revenue without a reporting currency")
+	}
+}
+
+func TestTimeSeriesReportingRevenue(t *testing.T) {
+	rates := money.NewRateTable()
+	rates.Add(money.Rate{From: "EUR", To: "USD", Rate: "2",
+		EffectiveFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+	server := NewServer(dashboardCache(), WithReporting(rates, "USD"))
+
+	var series timeSeries
+	getDashboard(t, server, "/api/dashboard/timeseries?interval=month", &series)
+
+	if series.ReportingCurrency != "USD" || len(series.Buckets) != 1 {
+		t.Fatalf("Unexpected series: %+v", series)
+	}
+	if b := series.Buckets[0]; b.Reporting == nil || *b.Reporting != 3000 || b.Orders != 5 {
+		t.Errorf("Expected 5 orders with 3000 USD revenue, got %+v", b)
+	}
+}
+
+func TestTruncateTimeWeek(t *testing.T) {
+	sunday := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	if got := truncateTime(sunday, "week"); !got.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected week to start on Monday 2024-03-04, got %s", got)
+	}
+}
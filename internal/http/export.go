@@ -0,0 +1,211 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// OrderStreamer reads complete orders from durable storage.
+type OrderStreamer interface {
+	StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(*models.Order) error) error
+}
+
+// WithExport enables GET /api/orders/export backed by store.
+func WithExport(store OrderStreamer) Option {
+	return func(s *Server) {
+		s.store = store
+	}
+}
+
+// exportFlushEvery is the number of records written between flushes, so
+// clients receive the export progressively.
+const exportFlushEvery = 100
+
+var csvOrderHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature",
+	"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city",
+	"delivery_address", "delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+}
+
+var csvItemHeader = []string{
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name",
+	"item_sale", "item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+func csvOrderRecord(o *models.Order) []string {
+	return []string{
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.Shardkey, strconv.Itoa(o.SmID),
+		o.DateCreated.UTC().Format(time.RFC3339), o.OofShard,
+		o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
+		o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
+		o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
+		strconv.FormatInt(int64(o.Payment.Amount.Amount), 10), strconv.FormatInt(o.Payment.PaymentDt, 10),
+		o.Payment.Bank, strconv.FormatInt(int64(o.Payment.DeliveryCost.Amount), 10),
+		strconv.FormatInt(int64(o.Payment.GoodsTotal.Amount), 10), strconv.FormatInt(int64(o.Payment.CustomFee.Amount), 10),
+	}
+}
+
+func csvItemRecord(i *models.Item) []string {
+	return []string{
+		strconv.Itoa(i.ChrtID), i.TrackNumber, strconv.FormatInt(int64(i.Price.Amount), 10), i.Rid, i.Name,
+		strconv.Itoa(i.Sale), i.Size, strconv.FormatInt(int64(i.TotalPrice.Amount), 10),
+		strconv.Itoa(i.NmID), i.Brand, strconv.Itoa(i.Status),
+	}
+}
+
+// exportWriter records whether the response has started, after which an
+// error can no longer change its status.
+type exportWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (ew *exportWriter) WriteHeader(code int) {
+	ew.started = true
+	ew.ResponseWriter.WriteHeader(code)
+}
+
+func (ew *exportWriter) Write(p []byte) (int, error) {
+	ew.started = true
+	return ew.ResponseWriter.Write(p)
+}
+
+func (ew *exportWriter) Flush() {
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		ew.started = true
+		f.Flush()
+	}
+}
+
+func (ew *exportWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// handleExport streams orders from the database as NDJSON or CSV:
+//
+//	GET /api/orders/export?format=ndjson
+//	GET /api/orders/export?format=csv&variant=order|items
+//
+// It accepts the same filters as the listing. An error before anything is
+// sent is answered with a JSON error; once the export has started, the
+// connection is aborted so the client sees a truncated response rather
+// than a complete one.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.store == nil {
+		writeJSONError(w, http.StatusNotImplemented, "export is not configured")
+		return
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	variant := r.URL.Query().Get("variant")
+	if variant == "" {
+		variant = "order"
+	}
+
+	out := &exportWriter{ResponseWriter: w}
+	var write func(*models.Order) error
+	flush := func() {}
+
+	switch format {
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(out)
+		write = func(o *models.Order) error {
+			return enc.Encode(o)
+		}
+
+	case "csv":
+		if variant != "order" && variant != "items" {
+			writeJSONError(w, http.StatusBadRequest, "variant must be order or items")
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+
+		cw := csv.NewWriter(out)
+		header := csvOrderHeader
+		if variant == "items" {
+			header = append(append([]string{}, csvOrderHeader...), csvItemHeader...)
+		}
+		if err := cw.Write(header); err != nil {
+			return
+		}
+
+		write = func(o *models.Order) error {
+			record := csvOrderRecord(o)
+			if variant == "order" {
+				return cw.Write(record)
+			}
+			if len(o.Items) == 0 {
+				return cw.Write(append(record, make([]string, len(csvItemHeader))...))
+			}
+			for i := range o.Items {
+				if err := cw.Write(append(append([]string{}, record...), csvItemRecord(&o.Items[i])...)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		flush = cw.Flush
+
+	default:
+		writeJSONError(w, http.StatusBadRequest, "format must be ndjson or csv")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="orders.`+format+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	count := 0
+	err = s.store.StreamOrders(r.Context(), filter, func(o *models.Order) error {
+		if err := write(s.masking.Apply(o, s.role(r))); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			flush()
+			out.Flush()
+		}
+		return nil
+	})
+
+	if err != nil {
+		if r.Context().Err() != nil {
+			slog.InfoContext(r.Context(), "Export cancelled by client", "orders", count)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Export failed", "orders", count, "error", err)
+		// A CSV header still in the csv.Writer buffer is dropped
+		if !out.started {
+			writeJSONError(w, http.StatusInternalServerError, "export failed")
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+	flush()
+	slog.InfoContext(r.Context(), "Export finished", "orders", count, "format", format)
+}
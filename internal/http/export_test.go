@@ -0,0 +1,237 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+)
+
+type mockStreamer struct {
+	orders []models.Order
+	filter models.OrderFilter
+	// err is returned after the orders are streamed
+	err error
+}
+
+func (m *mockStreamer) StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(*models.Order) error) error {
+	m.filter = filter
+	for i := range m.orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !filter.Match(&m.orders[i]) {
+			continue
+		}
+		if err := fn(&m.orders[i]); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func exportOrders() []models.Order {
+	return []models.Order{
+		{
+			OrderUID:        "order1",
+			DeliveryService: "meest",
+			DateCreated:     time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+			Delivery:        models.Delivery{Name: "Test Testov"},
+			Payment:         models.Payment{Currency: "USD", Amount: money.New(1817, "USD")},
+			Items: []models.Item{
+				{ChrtID: 1, Name: "Mascaras", Price: money.New(453, "USD")},
+				{ChrtID: 2, Name: "Lipstick", Price: money.New(890, "USD")},
+			},
+		},
+		{
+			OrderUID:        "order2",
+			DeliveryService: "cdek",
+			DateCreated:     time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC),
+			Payment:         models.Payment{Currency: "EUR", Amount: money.New(500, "EUR")},
+		},
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	store := &mockStreamer{orders: exportOrders()}
+	handler := NewServer(newMockCache(), WithExport(store)).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/export?format=ndjson", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected NDJSON content type, got %s", ct)
+	}
+
+	var uids []string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var order models.Order
+		if err := json.Unmarshal(scanner.Bytes(), &order); err != nil {
+			t.Fatalf("Failed to decode line: %v", err)
+		}
+		uids = append(uids, order.OrderUID)
+		if order.Delivery.Name != "" {
+			t.Error("Expected PII to be masked for the default role")
+		}
+	}
+	if len(uids) != 2 || uids[0] != "order1" || uids[1] != "order2" {
+		t.Errorf("Unexpected orders: %v", uids)
+	}
+}
+
+func TestExportCSVVariants(t *testing.T) {
+	store := &mockStreamer{orders: exportOrders()}
+	handler := NewServer(newMockCache(), WithExport(store)).Handler()
+
+	cases := []struct {
+		variant string
+		rows    int
+		columns int
+	}{
+		{"order", 2, len(csvOrderHeader)},
+		{"items", 3, len(csvOrderHeader) + len(csvItemHeader)},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/export?format=csv&variant="+c.variant, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: failed to parse CSV: %v", c.variant, err)
+		}
+		if len(records) != c.rows+1 {
+			t.Errorf("%s: expected %d rows plus header, got %d", c.variant, c.rows, len(records)-1)
+		}
+		for _, record := range records {
+			if len(record) != c.columns {
+				t.Errorf("%s: expected %d columns, got %d", c.variant, c.columns, len(record))
+			}
+		}
+	}
+}
+
+func TestExportFilters(t *testing.T) {
+	store := &mockStreamer{orders: exportOrders()}
+	handler := NewServer(newMockCache(), WithExport(store)).Handler()
+
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/orders/export?format=csv&delivery_service=cdek&from=2024-02-01&to=2024-02-10", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if store.filter.DeliveryService != "cdek" {
+		t.Errorf("Expected delivery_service filter, got %+v", store.filter)
+	}
+	if !store.filter.To.Equal(time.Date(2024, 2, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected inclusive end date, got %v", store.filter.To)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(records) != 2 || records[1][0] != "order2" {
+		t.Errorf("Expected only order2, got %v", records)
+	}
+}
+
+func TestExportRejectsBadParams(t *testing.T) {
+	handler := NewServer(newMockCache(), WithExport(&mockStreamer{})).Handler()
+
+	for _, query := range []string{"format=xml", "format=csv&variant=rows", "from=yesterday"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/export?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestExportStopsOnClientDisconnect(t *testing.T) {
+	store := &mockStreamer{orders: exportOrders()}
+	server := NewServer(newMockCache(), WithExport(store))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/export", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	server.handleExport(w, req)
+
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected no records after disconnect, got %q", w.Body.String())
+	}
+}
+
+func TestExportErrorBeforeFirstOrder(t *testing.T) {
+	store := &mockStreamer{err: errors.New("connection reset")}
+	server := NewServer(newMockCache(), WithExport(store))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/export?format=csv", nil)
+	w := httptest.NewRecorder()
+	server.handleExport(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body["error"] == "" {
+		t.Errorf("Expected only a JSON error, got %q", w.Body.String())
+	}
+}
+
+func TestExportErrorAfterStartAborts(t *testing.T) {
+	store := &mockStreamer{orders: exportOrders(), err: errors.New("connection reset")}
+	server := NewServer(newMockCache(), WithExport(store))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/export", nil)
+	w := httptest.NewRecorder()
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("Expected the handler to abort, got %v", p)
+		}
+		if strings.Contains(w.Body.String(), "error") {
+			t.Errorf("Expected no JSON error after the records, got %q", w.Body.String())
+		}
+	}()
+	server.handleExport(w, req)
+}
+
+func TestHandleGetAllOrdersFilters(t *testing.T) {
+	cache := newMockCache()
+	for _, order := range exportOrders() {
+		order := order
+		cache.Set(order.OrderUID, &order)
+	}
+	server := NewServer(cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders?currency=eur", nil)
+	w := httptest.NewRecorder()
+	server.handleGetAllOrders(w, req)
+
+	var orders []models.Order
+	if err := json.NewDecoder(w.Body).Decode(&orders); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(orders) != 1 || orders[0].OrderUID != "order2" {
+		t.Errorf("Expected only order2, got %+v", orders)
+	}
+}
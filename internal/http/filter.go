@@ -0,0 +1,48 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// parseFilter reads order filters from the query string. Dates are accepted
+// as RFC 3339 timestamps or YYYY-MM-DD; a bare "to" date includes that day.
+func parseFilter(r *http.Request) (models.OrderFilter, error) {
+	q := r.URL.Query()
+	f := models.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		DeliveryService: q.Get("delivery_service"),
+		Entry:           q.Get("entry"),
+		Currency:        q.Get("currency"),
+	}
+
+	var err error
+	if f.From, _, err = parseDate(q.Get("from")); err != nil {
+		return f, fmt.Errorf("invalid from: %w", err)
+	}
+	var dateOnly bool
+	if f.To, dateOnly, err = parseDate(q.Get("to")); err != nil {
+		return f, fmt.Errorf("invalid to: %w", err)
+	}
+	if dateOnly {
+		f.To = f.To.AddDate(0, 0, 1)
+	}
+	return f, nil
+}
+
+func parseDate(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected RFC 3339 or YYYY-MM-DD, got %q", value)
+	}
+	return t, true, nil
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"order-service/internal/logging"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "debug", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	logs := captureLogs(t)
+	handler := NewServer(newMockCache()).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/missing", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	id := w.Header().Get(RequestIDHeader)
+	if len(id) != 16 {
+		t.Fatalf("Expected a generated request ID, got %q", id)
+	}
+	line := logs.String()
+	if !strings.Contains(line, "request_id="+id) || !strings.Contains(line, "status=404") {
+		t.Errorf("Expected access log with request ID and status, got %q", line)
+	}
+}
+
+func TestRequestIDPropagated(t *testing.T) {
+	logs := captureLogs(t)
+	handler := newAuthServer(t).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	req.Header.Set(RequestIDHeader, "upstream-42")
+	req.Header.Set("X-API-Key", "wrong-key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got != "upstream-42" {
+		t.Errorf("Expected client request ID to be reused, got %q", got)
+	}
+	// Both the handler's log line and the access log carry the ID.
+	if n := strings.Count(logs.String(), "request_id=upstream-42"); n != 2 {
+		t.Errorf("Expected 2 log lines with the request ID, got %d in %q", n, logs.String())
+	}
+}
+
+func TestInvalidRequestIDReplaced(t *testing.T) {
+	captureLogs(t)
+	handler := NewServer(newMockCache()).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	req.Header.Set(RequestIDHeader, "bad id\nforged=1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if got := w.Header().Get(RequestIDHeader); got == "" || strings.ContainsAny(got, " \n") {
+		t.Errorf("Expected invalid request ID to be replaced, got %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// openAPIDocument describes every /api route. It is kept in sync with the
+// handlers and the model struct tags by TestOpenAPIMatchesRoutes and
+// TestOpenAPIMatchesModels.
+var openAPIDocument = mustReadWeb("web/openapi.json")
+
+var docsPage = mustReadWeb("web/docs.html")
+
+func mustReadWeb(name string) []byte {
+	data, err := webFS.ReadFile(name)
+	if err != nil {
+		panic(fmt.Sprintf("failed to read embedded %s: %v", name, err))
+	}
+	return data
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeCacheable(w, r, contentETag(openAPIDocument), time.Time{}, openAPIDocument)
+}
+
+// handleDocs serves an interactive viewer for the OpenAPI document. It is
+// bundled with the service and loads nothing from other origins.
+func (s *Server) handleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	setSecurityHeaders(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docsPage)
+}
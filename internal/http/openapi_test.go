@@ -0,0 +1,320 @@
+package http
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+	"order-service/internal/outbox"
+)
+
+type openAPISpec struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Scope     string                     `json:"x-required-scope"`
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+type openAPISchema struct {
+	Ref        string                   `json:"$ref"`
+	AllOf      []openAPISchema          `json:"allOf"`
+	Properties map[string]openAPISchema `json:"properties"`
+}
+
+func loadOpenAPI(t *testing.T) openAPISpec {
+	t.Helper()
+	var spec openAPISpec
+	if err := json.Unmarshal(openAPIDocument, &spec); err != nil {
+		t.Fatalf("Failed to parse openapi.json: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("Expected an OpenAPI 3 document, got %q", spec.OpenAPI)
+	}
+	return spec
+}
+
+// registeredAPIRoutes returns the /api patterns registered in Handler.
+func registeredAPIRoutes(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "server.go", nil, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse server.go: %v", err)
+	}
+
+	var routes []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok || lit.Kind != token.STRING {
+			return true
+		}
+		if pattern, _ := strconv.Unquote(lit.Value); strings.HasPrefix(pattern, "/api/") {
+			routes = append(routes, pattern)
+		}
+		return true
+	})
+	sort.Strings(routes)
+	return routes
+}
+
+var pathParam = regexp.MustCompile(`\{[^}]+\}`)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	spec := loadOpenAPI(t)
+
+	documented := map[string]bool{}
+	for path := range spec.Paths {
+		// ServeMux serves /api/orders/{orderUID} through the "/api/orders/"
+		// prefix, and /api/webhooks/{id}/deliveries through "/api/webhooks/".
+		if i := strings.Index(path, "{"); i >= 0 {
+			path = path[:i]
+		}
+		documented[path] = true
+	}
+
+	registered := map[string]bool{}
+	for _, route := range registeredAPIRoutes(t) {
+		registered[route] = true
+		if !documented[route] {
+			t.Errorf("Route %s is not described in openapi.json", route)
+		}
+	}
+	for path := range documented {
+		if !registered[path] {
+			t.Errorf("openapi.json describes %s, which is not registered", path)
+		}
+	}
+}
+
+func TestOpenAPIMethodsAreServed(t *testing.T) {
+	spec := loadOpenAPI(t)
+	cache := newMockCache()
+	cache.Set("test123", &models.Order{OrderUID: "test123"})
+	handler := NewServer(cache).Handler()
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			req := httptest.NewRequest(strings.ToUpper(method), pathParam.ReplaceAllString(path, "test123"), strings.NewReader("[]"))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code == http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: documented method is not allowed", method, path)
+			}
+			if _, ok := operations[method].Responses[strconv.Itoa(w.Code)]; !ok {
+				t.Errorf("%s %s: status %d is not documented", method, path, w.Code)
+			}
+		}
+	}
+}
+
+func TestOpenAPIScopesMatchHandlers(t *testing.T) {
+	spec := loadOpenAPI(t)
+	handler := newAuthServer(t).Handler()
+
+	// reader-key holds orders:read only.
+	for path, operations := range spec.Paths {
+		for method, op := range operations {
+			target := pathParam.ReplaceAllString(path, "test123")
+
+			req := httptest.NewRequest(strings.ToUpper(method), target, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if op.Scope == "" {
+				if w.Code == http.StatusUnauthorized {
+					t.Errorf("%s %s: documented as public but requires credentials", method, path)
+				}
+				continue
+			}
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s: expected 401 without credentials, got %d", method, path, w.Code)
+			}
+
+			req = httptest.NewRequest(strings.ToUpper(method), target, nil)
+			req.Header.Set("X-API-Key", "reader-key")
+			w = httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			forbidden := w.Code == http.StatusForbidden
+			if forbidden != (op.Scope != "orders:read") {
+				t.Errorf("%s %s: documented scope %s, but orders:read got status %d", method, path, op.Scope, w.Code)
+			}
+		}
+	}
+}
+
+// jsonFields returns the JSON property names of a struct type, including
+// those promoted from embedded structs.
+func jsonFields(typ reflect.Type) map[string]bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	fields := map[string]bool{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			for name := range jsonFields(f.Type) {
+				fields[name] = true
+			}
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+func schemaProperties(spec openAPISpec, schema openAPISchema) map[string]bool {
+	props := map[string]bool{}
+	if schema.Ref != "" {
+		name := schema.Ref[strings.LastIndex(schema.Ref, "/")+1:]
+		return schemaProperties(spec, spec.Components.Schemas[name])
+	}
+	for _, part := range schema.AllOf {
+		for name := range schemaProperties(spec, part) {
+			props[name] = true
+		}
+	}
+	for name := range schema.Properties {
+		props[name] = true
+	}
+	return props
+}
+
+func TestOpenAPIMatchesModels(t *testing.T) {
+	spec := loadOpenAPI(t)
+
+	types := map[string]reflect.Type{
+		"Order":               reflect.TypeOf(models.Order{}),
+		"Delivery":            reflect.TypeOf(models.Delivery{}),
+		"Payment":             reflect.TypeOf(models.Payment{}),
+		"Item":                reflect.TypeOf(models.Item{}),
+		"OrderView":           reflect.TypeOf(orderView{}),
+		"ReportingAmounts":    reflect.TypeOf(reportingAmounts{}),
+		"Rate":                reflect.TypeOf(money.Rate{}),
+		"StreamMessage":       reflect.TypeOf(streamMessage{}),
+		"ResetMessage":        reflect.TypeOf(resetMessage{}),
+		"OrderSummary":        reflect.TypeOf(orderSummary{}),
+		"OrderPage":           reflect.TypeOf(orderPage{}),
+		"Facets":              reflect.TypeOf(facets{}),
+		"TimeBucket":          reflect.TypeOf(timeBucket{}),
+		"TimeSeries":          reflect.TypeOf(timeSeries{}),
+		"SchemaIndex":         reflect.TypeOf(schemaIndex{}),
+		"SchemaRef":           reflect.TypeOf(schemaRef{}),
+		"OutboxStats":         reflect.TypeOf(outbox.Stats{}),
+		"WebhookSubscription": reflect.TypeOf(models.WebhookSubscription{}),
+		"WebhookDelivery":     reflect.TypeOf(models.WebhookDelivery{}),
+	}
+	// Responses built from maps rather than structs.
+	untyped := map[string]bool{"Error": true, "Stats": true}
+
+	for name, schema := range spec.Components.Schemas {
+		typ, ok := types[name]
+		if !ok {
+			if !untyped[name] {
+				t.Errorf("Schema %s has no Go type to check against", name)
+			}
+			continue
+		}
+
+		documented := schemaProperties(spec, schema)
+		for field := range jsonFields(typ) {
+			if !documented[field] {
+				t.Errorf("%s: field %s of %s is not documented", name, field, typ)
+			}
+		}
+		for field := range documented {
+			if !jsonFields(typ)[field] {
+				t.Errorf("%s: documented property %s does not exist on %s", name, field, typ)
+			}
+		}
+	}
+	for name := range types {
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("Schema %s is missing from openapi.json", name)
+		}
+	}
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				var target interface{} = doc
+				for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]interface{})
+					target = m[key]
+				}
+				if target == nil {
+					t.Errorf("Unresolved reference %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	handler := newAuthServer(t).Handler()
+
+	for path, contentType := range map[string]string{
+		"/api/openapi.json": "application/json",
+		"/api/docs":         "text/html",
+		"/static/docs.js":   "javascript",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, contentType) {
+			t.Errorf("%s: expected content type %s, got %s", path, contentType, ct)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/ratelimit"
+)
+
+// RateLimits configures per-client request limits. Routes without an entry
+// use Default; a zero Limit disables limiting for that route.
+type RateLimits struct {
+	Default ratelimit.Limit
+	Routes  map[string]ratelimit.Limit
+	// PerIP limits the API requests of each client IP across all routes.
+	// It is checked before authentication, so floods of unauthenticated
+	// requests are limited too. A zero Limit disables it.
+	PerIP       ratelimit.Limit
+	MaxInFlight int
+}
+
+// Route names used for per-route limits.
+const (
+	RouteOrdersList   = "orders.list"
+	RouteOrdersGet    = "orders.get"
+	RouteOrdersExport = "orders.export"
+	RouteOrdersStream = "orders.stream"
+	RouteStats        = "stats"
+	RouteRates        = "rates"
+	RouteDashboard    = "dashboard"
+	RouteWebhooks     = "webhooks"
+)
+
+// WithRateLimits enables token-bucket rate limiting keyed by API client and a
+// global cap on requests in flight.
+func WithRateLimits(cfg RateLimits) Option {
+	return func(s *Server) {
+		s.rateLimits = cfg
+		s.limiters = make(map[string]*ratelimit.Limiter)
+		if cfg.PerIP.Enabled() {
+			s.ipLimiter = ratelimit.NewLimiter(cfg.PerIP)
+		}
+		if cfg.MaxInFlight > 0 {
+			s.inFlight = make(chan struct{}, cfg.MaxInFlight)
+		}
+	}
+}
+
+func (s *Server) limiter(route string) *ratelimit.Limiter {
+	if s.limiters == nil {
+		return nil
+	}
+
+	s.limitersMu.Lock()
+	defer s.limitersMu.Unlock()
+
+	if l, ok := s.limiters[route]; ok {
+		return l
+	}
+
+	limit, ok := s.rateLimits.Routes[route]
+	if !ok {
+		limit = s.rateLimits.Default
+	}
+	var l *ratelimit.Limiter
+	if limit.Enabled() {
+		l = ratelimit.NewLimiter(limit)
+	}
+	s.limiters[route] = l
+	return l
+}
+
+// clientKey identifies the caller for rate limiting: the authenticated
+// principal when there is one, the client IP otherwise.
+func clientKey(r *http.Request) string {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok && p.Subject != "" {
+		return p.Subject
+	}
+	return clientIP(r)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// limit applies the route's rate limit to next. It must run after
+// authentication so that limits are keyed by API client.
+func (s *Server) limit(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := s.limiter(route)
+		if l == nil {
+			next(w, r)
+			return
+		}
+
+		if allow(w, l, route+"|"+clientKey(r)) {
+			next(w, r)
+		}
+	}
+}
+
+// ipLimitMiddleware applies the PerIP limit to API requests. It runs before
+// authentication, unlike the per-route limits.
+func (s *Server) ipLimitMiddleware(next http.Handler) http.Handler {
+	if s.ipLimiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") || allow(w, s.ipLimiter, clientIP(r)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// allow takes a token for key from l and sets the rate limit headers. It
+// writes a 429 response and returns false when the limit is exceeded.
+func allow(w http.ResponseWriter, l *ratelimit.Limiter, key string) bool {
+	res := l.Allow(key)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", seconds(res.Reset))
+
+	if !res.Allowed {
+		w.Header().Set("Retry-After", seconds(res.RetryAfter))
+		writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// longLived lists routes whose connections stay open for the lifetime of a
+// client; they are not counted against MaxInFlight.
+var longLived = map[string]bool{
+	"/api/orders/stream": true,
+	"/api/orders/ws":     true,
+}
+
+// inFlightMiddleware rejects requests once MaxInFlight requests are already
+// being served.
+func (s *Server) inFlightMiddleware(next http.Handler) http.Handler {
+	if s.inFlight == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if longLived[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		select {
+		case s.inFlight <- struct{}{}:
+			defer func() { <-s.inFlight }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			writeJSONError(w, http.StatusTooManyRequests, "server is busy")
+		}
+	})
+}
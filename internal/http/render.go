@@ -0,0 +1,27 @@
+package http
+
+import (
+	"net/http"
+
+	"order-service/internal/masking"
+	"order-service/internal/models"
+)
+
+// role returns the masking role of the caller.
+func (s *Server) role(r *http.Request) masking.Role {
+	if role, ok := masking.RoleFromContext(r.Context()); ok {
+		return role
+	}
+	return s.defaultRole
+}
+
+// renderOrder prepares an order for JSON encoding: PII is masked according to
+// the caller's role and converted amounts are added on request. Every handler
+// that returns orders must encode them through renderOrder.
+func (s *Server) renderOrder(r *http.Request, order *models.Order) orderView {
+	view := orderView{Order: s.masking.Apply(order, s.role(r))}
+	if wantsReporting(r) {
+		view = s.withReporting(view)
+	}
+	return view
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+)
+
+// reportingAmounts holds the payment totals of an order converted into the
+// reporting currency as of its payment_dt.
+type reportingAmounts struct {
+	Currency     string       `json:"currency"`
+	Amount       money.Amount `json:"amount"`
+	DeliveryCost money.Amount `json:"delivery_cost"`
+	GoodsTotal   money.Amount `json:"goods_total"`
+	CustomFee    money.Amount `json:"custom_fee"`
+}
+
+// orderView is an order as returned by the API, optionally decorated with
+// converted amounts.
+type orderView struct {
+	*models.Order
+	Reporting      *reportingAmounts `json:"reporting,omitempty"`
+	ReportingError string            `json:"reporting_error,omitempty"`
+}
+
+// wantsReporting reports whether the client asked for converted amounts with
+// ?reporting=true.
+func wantsReporting(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("reporting"))
+	return v
+}
+
+func (s *Server) withReporting(view orderView) orderView {
+	order := view.Order
+	if s.rates == nil {
+		view.ReportingError = "reporting currency is not configured"
+		return view
+	}
+
+	amounts, err := s.convertPayment(order)
+	if err != nil {
+		view.ReportingError = err.Error()
+		return view
+	}
+	view.Reporting = amounts
+	return view
+}
+
+func (s *Server) convertPayment(order *models.Order) (*reportingAmounts, error) {
+	p := order.Payment
+	at := p.PaidAt(order.DateCreated)
+
+	convert := func(amount money.Money) (money.Amount, error) {
+		m, err := s.rates.Convert(amount, s.reportingCurrency, at)
+		return m.Amount, err
+	}
+
+	var (
+		out = &reportingAmounts{Currency: s.reportingCurrency}
+		err error
+	)
+	if out.Amount, err = convert(p.Amount); err != nil {
+		return nil, err
+	}
+	if out.DeliveryCost, err = convert(p.DeliveryCost); err != nil {
+		return nil, err
+	}
+	if out.GoodsTotal, err = convert(p.GoodsTotal); err != nil {
+		return nil, err
+	}
+	if out.CustomFee, err = convert(p.CustomFee); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// revenue sums payment amounts of all cached orders in the reporting
+// currency. Orders that cannot be converted are counted separately.
+func (s *Server) revenue() map[string]interface{} {
+	var (
+		total       money.Amount
+		unconverted int
+	)
+	for _, order := range s.cache.GetAll() {
+		m, err := s.rates.Convert(order.Payment.Amount,
+			s.reportingCurrency, order.Payment.PaidAt(order.DateCreated))
+		if err != nil {
+			unconverted++
+			continue
+		}
+		total += m.Amount
+	}
+
+	return map[string]interface{}{
+		"currency":    s.reportingCurrency,
+		"amount":      total,
+		"formatted":   money.New(total, s.reportingCurrency).String(),
+		"unconverted": unconverted,
+	}
+}
+
+func (s *Server) handleRates(w http.ResponseWriter, r *http.Request) {
+	if s.rates == nil {
+		http.Error(w, "Exchange rates are not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.rates.All())
+
+	case http.MethodPost:
+		var rates []money.Rate
+		if err := json.NewDecoder(r.Body).Decode(&rates); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := s.rates.Add(rates...); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slog.InfoContext(r.Context(), "Loaded exchange rates via API", "count", len(rates))
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/schema"
+)
+
+// schemaIndex lists the JSON Schemas of order messages accepted on NATS.
+type schemaIndex struct {
+	Current  int         `json:"current"`
+	Versions []schemaRef `json:"versions"`
+}
+
+type schemaRef struct {
+	Version int    `json:"version"`
+	URL     string `json:"url"`
+}
+
+func (s *Server) handleSchemas(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	index := schemaIndex{Current: schema.CurrentOrderVersion}
+	for _, v := range schema.OrderVersions() {
+		index.Versions = append(index.Versions, schemaRef{Version: v, URL: schema.OrderSchemaPath(v)})
+	}
+	s.writeSchema(w, r, index)
+}
+
+// handleOrderSchema serves /api/schemas/order/v{version}.
+func (s *Server) handleOrderSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/api/schemas/order/")
+	version, err := strconv.Atoi(strings.TrimPrefix(name, "v"))
+	if err != nil || !strings.HasPrefix(name, "v") {
+		writeJSONError(w, http.StatusNotFound, "schema not found")
+		return
+	}
+	doc, ok := schema.OrderSchema(version)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "schema not found")
+		return
+	}
+	s.writeSchema(w, r, doc)
+}
+
+func (s *Server) writeSchema(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := encodeJSON(v)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to encode schema")
+		return
+	}
+	writeCacheable(w, r, contentETag(body), time.Time{}, body)
+}
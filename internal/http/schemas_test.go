@@ -0,0 +1,60 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/schema"
+)
+
+func TestSchemaIndex(t *testing.T) {
+	handler := NewServer(newMockCache()).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/schemas", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var index schemaIndex
+	if err := json.Unmarshal(w.Body.Bytes(), &index); err != nil {
+		t.Fatalf("Failed to decode index: %v", err)
+	}
+	if index.Current != schema.CurrentOrderVersion || len(index.Versions) != len(schema.OrderVersions()) {
+		t.Errorf("Unexpected index %+v", index)
+	}
+}
+
+func TestOrderSchema(t *testing.T) {
+	handler := NewServer(newMockCache()).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, schema.OrderSchemaPath(schema.CurrentOrderVersion), nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var doc schema.Schema
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to decode schema: %v", err)
+	}
+	if doc.ID != schema.OrderSchemaPath(schema.CurrentOrderVersion) || doc.Properties["order_uid"] == nil {
+		t.Errorf("Unexpected schema %+v", doc)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("Expected schema responses to carry an ETag")
+	}
+
+	for _, path := range []string{"/api/schemas/order/v99", "/api/schemas/order/2", "/api/schemas/order/vx"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+	}
+}
@@ -1,17 +1,25 @@
 package http
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"order-service/internal/auth"
+	"order-service/internal/masking"
 	"order-service/internal/models"
+	"order-service/internal/money"
+	"order-service/internal/ratelimit"
 )
 
 type mockCache struct {
-	orders map[string]*models.Order
+	orders  map[string]*models.Order
+	version uint64
 }
 
 func newMockCache() *mockCache {
@@ -39,6 +47,11 @@ func (m *mockCache) Size() int {
 
 func (m *mockCache) Set(orderUID string, order *models.Order) {
 	m.orders[orderUID] = order
+	m.version++
+}
+
+func (m *mockCache) Version() (uint64, time.Time) {
+	return m.version, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 }
 
 func TestHandleGetOrder(t *testing.T) {
@@ -173,3 +186,441 @@ func TestMethodNotAllowed(t *testing.T) {
 		t.Errorf("Expected status 405, got %d", w.Code)
 	}
 }
+
+func TestHandleGetOrderWithReporting(t *testing.T) {
+	cache := newMockCache()
+	testOrder := &models.Order{
+		OrderUID:    "test123",
+		DateCreated: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Payment: models.Payment{
+			Currency:     "EUR",
+			Amount:       money.New(1000, "EUR"),
+			DeliveryCost: money.New(200, "EUR"),
+			GoodsTotal:   money.New(800, "EUR"),
+			PaymentDt:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Unix(),
+		},
+	}
+	testOrder.Denominate()
+	cache.Set(testOrder.OrderUID, testOrder)
+
+	rates := money.NewRateTable()
+	rates.Add(money.Rate{From: "EUR", To: "USD", Rate: "1.5",
+		EffectiveFrom: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+	server := NewServer(cache, WithReporting(rates, "USD"))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/test123?reporting=true", nil)
+	w := httptest.NewRecorder()
+
+	server.handleGetOrder(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var resp struct {
+		OrderUID  string `json:"order_uid"`
+		Reporting struct {
+			Currency     string `json:"currency"`
+			Amount       int64  `json:"amount"`
+			DeliveryCost int64  `json:"delivery_cost"`
+		} `json:"reporting"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if resp.OrderUID != "test123" {
+		t.Errorf("Expected OrderUID test123, got %s", resp.OrderUID)
+	}
+	if resp.Reporting.Currency != "USD" || resp.Reporting.Amount != 1500 || resp.Reporting.DeliveryCost != 300 {
+		t.Errorf("Unexpected reporting amounts: %+v", resp.Reporting)
+	}
+}
+
+func TestHandleGetOrderMasksPII(t *testing.T) {
+	cache := newMockCache()
+	cache.Set("test123", &models.Order{
+		OrderUID: "test123",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000012",
+			Address: "Ploshad Mira 15",
+			Email:   "test@gmail.com",
+		},
+	})
+
+	server := NewServer(cache)
+
+	cases := []struct {
+		role  masking.Role
+		phone string
+		email string
+	}{
+		{masking.RoleSupport, "+9720000012", "test@gmail.com"},
+		{masking.RoleAnalyst, "+********12", "t***@gmail.com"},
+		{masking.RolePublic, "", ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/test123", nil)
+		req = req.WithContext(masking.WithRole(req.Context(), c.role))
+		w := httptest.NewRecorder()
+
+		server.handleGetOrder(w, req)
+
+		var order models.Order
+		if err := json.NewDecoder(w.Body).Decode(&order); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if order.Delivery.Phone != c.phone || order.Delivery.Email != c.email {
+			t.Errorf("Role %s: expected phone %q email %q, got %q %q",
+				c.role, c.phone, c.email, order.Delivery.Phone, order.Delivery.Email)
+		}
+	}
+}
+
+func TestHandleGetAllOrdersMasksPII(t *testing.T) {
+	cache := newMockCache()
+	cache.Set("order1", &models.Order{OrderUID: "order1", Delivery: models.Delivery{Name: "Test Testov"}})
+
+	server := NewServer(cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	w := httptest.NewRecorder()
+
+	server.handleGetAllOrders(w, req)
+
+	var orders []models.Order
+	if err := json.NewDecoder(w.Body).Decode(&orders); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(orders) != 1 || orders[0].Delivery.Name != "" {
+		t.Errorf("Expected name redacted for default role, got %+v", orders)
+	}
+}
+
+func newAuthServer(t *testing.T) *Server {
+	t.Helper()
+	keys, err := auth.NewAPIKeyAuthenticator([]auth.APIKey{
+		{Name: "reader", Hash: auth.HashAPIKey("reader-key"), Scopes: []string{auth.ScopeOrdersRead}, Role: masking.RoleSupport},
+		{Name: "admin", Hash: auth.HashAPIKey("admin-key"), Scopes: []string{auth.ScopeAdmin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := newMockCache()
+	cache.Set("test123", &models.Order{OrderUID: "test123", Delivery: models.Delivery{Name: "Test Testov"}})
+	return NewServer(cache, WithAuth(keys))
+}
+
+func TestAuthRequired(t *testing.T) {
+	handler := newAuthServer(t).Handler()
+
+	for _, path := range []string{"/api/orders", "/api/orders/test123", "/api/stats"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401, got %d", path, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected JSON error, got %s", path, ct)
+		}
+	}
+}
+
+func TestAuthScopes(t *testing.T) {
+	handler := newAuthServer(t).Handler()
+
+	cases := []struct {
+		key    string
+		path   string
+		status int
+	}{
+		{"reader-key", "/api/orders/test123", http.StatusOK},
+		{"reader-key", "/api/stats", http.StatusForbidden},
+		{"admin-key", "/api/stats", http.StatusOK},
+		{"wrong-key", "/api/orders", http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("X-API-Key", c.key)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d", c.key, c.path, c.status, w.Code)
+		}
+	}
+}
+
+func TestAuthRoleDrivesMasking(t *testing.T) {
+	handler := newAuthServer(t).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/test123", nil)
+	req.Header.Set("X-API-Key", "reader-key")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var order models.Order
+	if err := json.NewDecoder(w.Body).Decode(&order); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if order.Delivery.Name != "Test Testov" {
+		t.Errorf("Expected support role to see name, got %q", order.Delivery.Name)
+	}
+}
+
+func TestRateLimitPerRoute(t *testing.T) {
+	cache := newMockCache()
+	cache.Set("test123", &models.Order{OrderUID: "test123"})
+
+	server := NewServer(cache, WithRateLimits(RateLimits{
+		Default: ratelimit.Limit{Rate: 100, Burst: 100},
+		Routes: map[string]ratelimit.Limit{
+			RouteOrdersList: {Rate: 0.5, Burst: 1},
+		},
+	}))
+	handler := server.Handler()
+
+	do := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("/api/orders", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Fatalf("Expected first listing to succeed, got %d", w.Code)
+	}
+
+	w := do("/api/orders", "10.0.0.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", w.Header().Get("Retry-After"))
+	}
+	if w.Header().Get("X-RateLimit-Limit") != "1" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Unexpected rate limit headers: %v", w.Header())
+	}
+
+	// Other routes and other clients are unaffected
+	if w := do("/api/orders/test123", "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected single order lookup to succeed, got %d", w.Code)
+	}
+	if w := do("/api/orders", "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("Expected listing from another client to succeed, got %d", w.Code)
+	}
+}
+
+func TestRateLimitPerIPBeforeAuth(t *testing.T) {
+	server := newAuthServer(t)
+	WithRateLimits(RateLimits{PerIP: ratelimit.Limit{Rate: 0.5, Burst: 2}})(server)
+	handler := server.Handler()
+
+	do := func(remoteAddr, key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+		req.RemoteAddr = remoteAddr
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := do("10.0.0.1:1234", "wrong-key"); code != http.StatusUnauthorized {
+			t.Fatalf("Expected status 401, got %d", code)
+		}
+	}
+	// The flood is limited even though none of it authenticated
+	if code := do("10.0.0.1:1234", "wrong-key"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", code)
+	}
+	if code := do("10.0.0.1:5678", "reader-key"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected valid keys from the same IP to be limited too, got %d", code)
+	}
+	if code := do("10.0.0.2:1234", "reader-key"); code != http.StatusOK {
+		t.Errorf("Expected another IP to be unaffected, got %d", code)
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	server := NewServer(newMockCache(), WithRateLimits(RateLimits{MaxInFlight: 1}))
+
+	// Occupy the only slot
+	server.inFlight <- struct{}{}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+}
+
+func TestHandleGetOrderConditional(t *testing.T) {
+	cache := newMockCache()
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache.Set("test123", &models.Order{OrderUID: "test123", DateCreated: created})
+	server := NewServer(cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/test123", nil)
+	w := httptest.NewRecorder()
+	server.handleGetOrder(w, req)
+
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+	if lm := w.Header().Get("Last-Modified"); lm != created.Format(http.TimeFormat) {
+		t.Errorf("Expected Last-Modified %s, got %s", created.Format(http.TimeFormat), lm)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/orders/test123", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	server.handleGetOrder(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Error("Expected empty body for 304")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/orders/test123", nil)
+	req.Header.Set("If-Modified-Since", created.Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	server.handleGetOrder(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for If-Modified-Since, got %d", w.Code)
+	}
+}
+
+func TestHandleGetAllOrdersETagDiffersAfterRestart(t *testing.T) {
+	// A restarted process reaches the same cache version with other orders
+	etags := make(map[string]bool)
+	for _, uid := range []string{"order1", "order2"} {
+		cache := newMockCache()
+		cache.Set(uid, &models.Order{OrderUID: uid})
+		w := httptest.NewRecorder()
+		NewServer(cache).handleGetAllOrders(w, httptest.NewRequest(http.MethodGet, "/api/orders", nil))
+		etags[w.Header().Get("ETag")] = true
+	}
+	if len(etags) != 2 {
+		t.Error("Expected listings of different processes to have different ETags")
+	}
+}
+
+func TestHandleGetAllOrdersETagChangesWithCache(t *testing.T) {
+	cache := newMockCache()
+	cache.Set("order1", &models.Order{OrderUID: "order1"})
+	server := NewServer(cache)
+
+	get := func(inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		w := httptest.NewRecorder()
+		server.handleGetAllOrders(w, req)
+		return w
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+
+	if w := get(etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for unchanged cache, got %d", w.Code)
+	}
+
+	cache.Set("order2", &models.Order{OrderUID: "order2"})
+
+	w := get(etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 after cache change, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("Expected ETag to change after cache change")
+	}
+
+	var orders []models.Order
+	if err := json.NewDecoder(w.Body).Decode(&orders); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(orders) != 2 {
+		t.Errorf("Expected 2 orders, got %d", len(orders))
+	}
+}
+
+func TestCompression(t *testing.T) {
+	cache := newMockCache()
+	for i := 0; i < 50; i++ {
+		uid := fmt.Sprintf("order%d", i)
+		cache.Set(uid, &models.Order{OrderUID: uid, TrackNumber: "WBILMTESTTRACK"})
+	}
+	handler := NewServer(cache).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	req.Header.Set("Accept-Encoding", "deflate;q=0.5, gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.HasSuffix(etag, `-gzip"`) {
+		t.Errorf("Expected gzip ETag suffix, got %s", etag)
+	}
+
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	var orders []models.Order
+	if err := json.NewDecoder(zr).Decode(&orders); err != nil {
+		t.Fatalf("Failed to decode gzip body: %v", err)
+	}
+	if len(orders) != 50 {
+		t.Errorf("Expected 50 orders, got %d", len(orders))
+	}
+
+	// The compressed ETag validates the uncompressed representation too
+	req = httptest.NewRequest(http.MethodGet, "/api/orders", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                      "",
+		"gzip":                  "gzip",
+		"deflate":               "deflate",
+		"gzip;q=0.2, deflate":   "deflate",
+		"gzip;q=0, br":          "",
+		"identity, gzip, *;q=0": "gzip",
+	}
+	for header, want := range cases {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", header, want, got)
+		}
+	}
+}
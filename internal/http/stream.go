@@ -0,0 +1,213 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-service/internal/events"
+	"order-service/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// EventSource provides the live order feed.
+type EventSource interface {
+	Subscribe(filter models.OrderFilter, lastID uint64) (*events.Subscription, bool)
+}
+
+// WithEvents enables the live order feed at /api/orders/stream (SSE) and
+// /api/orders/ws (WebSocket).
+func WithEvents(source EventSource) Option {
+	return func(s *Server) {
+		s.events = source
+	}
+}
+
+const (
+	streamHeartbeat = 15 * time.Second
+	wsWriteTimeout  = 10 * time.Second
+)
+
+// streamMessage is an event as sent to feed clients.
+type streamMessage struct {
+	ID    uint64    `json:"id"`
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time,omitempty"`
+	Order orderView `json:"order"`
+}
+
+// resetMessage tells a resuming client that events were missed and it should
+// reload its state.
+type resetMessage struct {
+	Type string `json:"type"`
+}
+
+const typeReset = "reset"
+
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) (*events.Subscription, bool, bool) {
+	if s.events == nil {
+		writeJSONError(w, http.StatusNotImplemented, "live feed is not configured")
+		return nil, false, false
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return nil, false, false
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var since uint64
+	if lastID != "" {
+		if since, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return nil, false, false
+		}
+	}
+
+	sub, complete := s.events.Subscribe(filter, since)
+	return sub, complete, true
+}
+
+func (s *Server) eventMessage(r *http.Request, ev events.Event) streamMessage {
+	return streamMessage{ID: ev.ID, Type: ev.Type, Time: ev.Time, Order: s.renderOrder(r, ev.Order)}
+}
+
+// handleStream serves the live order feed as Server-Sent Events.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	sub, complete, ok := s.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q}\n\n", typeReset, typeReset)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+
+		case ev, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects
+				// with Last-Event-ID.
+				return
+			}
+			data, err := json.Marshal(s.eventMessage(r, ev))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to encode event", "event_id", ev.ID, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// handleWebSocket serves the live order feed over a WebSocket. Each event is
+// sent as one JSON text message.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	sub, complete, ok := s.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.WarnContext(r.Context(), "WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	// Drain incoming frames so that pings and close frames are handled.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(v interface{}) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(v)
+	}
+
+	if !complete {
+		if err := write(resetMessage{Type: typeReset}); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+
+		case <-heartbeat.C:
+			deadline := time.Now().Add(wsWriteTimeout)
+			if err := conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				return
+			}
+
+		case ev, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "subscriber too slow"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			if err := write(s.eventMessage(r, ev)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/internal/events"
+	"order-service/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// readSSEEvent reads lines up to the next blank line and returns the id and
+// data fields.
+func readSSEEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var id, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if id != "" || data != "" {
+				return id, data
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "id: "); ok {
+			id = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+		}
+	}
+}
+
+// waitForSubscribers waits until n clients are subscribed to the broker.
+func waitForSubscribers(t *testing.T, b *events.Broker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Subscribers() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d subscribers", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStreamSSE(t *testing.T) {
+	broker := events.NewBroker(10, 10)
+	ts := httptest.NewServer(NewServer(newMockCache(), WithEvents(broker)).Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/orders/stream?delivery_service=meest", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %s", ct)
+	}
+
+	waitForSubscribers(t, broker, 1)
+	broker.PublishOrder(&models.Order{OrderUID: "skipped", DeliveryService: "cdek"})
+	broker.PublishOrder(&models.Order{OrderUID: "order1", DeliveryService: "meest",
+		Delivery: models.Delivery{Name: "Test Testov"}})
+
+	id, data := readSSEEvent(t, bufio.NewReader(resp.Body))
+	if id != "2" {
+		t.Errorf("Expected event ID 2, got %s", id)
+	}
+
+	var msg struct {
+		Type  string       `json:"type"`
+		Order models.Order `json:"order"`
+	}
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		t.Fatalf("Failed to decode event: %v", err)
+	}
+	if msg.Type != events.TypeOrderStored || msg.Order.OrderUID != "order1" {
+		t.Errorf("Unexpected event: %+v", msg)
+	}
+	if msg.Order.Delivery.Name != "" {
+		t.Error("Expected PII to be masked in events")
+	}
+}
+
+func TestStreamSSEResume(t *testing.T) {
+	broker := events.NewBroker(10, 10)
+	broker.PublishOrder(&models.Order{OrderUID: "order1"})
+	broker.PublishOrder(&models.Order{OrderUID: "order2"})
+
+	ts := httptest.NewServer(NewServer(newMockCache(), WithEvents(broker)).Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/api/orders/stream", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	id, data := readSSEEvent(t, bufio.NewReader(resp.Body))
+	if id != "2" || !strings.Contains(data, `"order2"`) {
+		t.Errorf("Expected replay of event 2, got id=%s data=%s", id, data)
+	}
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	broker := events.NewBroker(10, 10)
+	ts := httptest.NewServer(NewServer(newMockCache(), WithEvents(broker)).Handler())
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/orders/ws?customer_id=customer1"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	waitForSubscribers(t, broker, 1)
+	broker.PublishOrder(&models.Order{OrderUID: "other", CustomerID: "customer2"})
+	broker.PublishOrder(&models.Order{OrderUID: "order1", CustomerID: "customer1"})
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg struct {
+		ID    uint64       `json:"id"`
+		Order models.Order `json:"order"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	if msg.ID != 2 || msg.Order.OrderUID != "order1" {
+		t.Errorf("Unexpected message: %+v", msg)
+	}
+}
+
+func TestStreamNotConfigured(t *testing.T) {
+	server := NewServer(newMockCache())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/stream", nil)
+	w := httptest.NewRecorder()
+	server.handleStream(w, req)
+
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status 501, got %d", w.Code)
+	}
+}
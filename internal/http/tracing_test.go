@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+func TestTracingContinuesClientTrace(t *testing.T) {
+	rec := recordSpans(t)
+	handler := NewServer(newMockCache()).Handler()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/missing", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var server sdktrace.ReadOnlySpan
+	for _, span := range rec.Ended() {
+		if span.Name() == "GET /api/orders/" {
+			server = span
+		}
+	}
+	if server == nil {
+		t.Fatalf("Expected a server span named after the route, got %d spans", len(rec.Ended()))
+	}
+	if got := server.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("Expected trace ID %s, got %s", traceID, got)
+	}
+
+	var cache bool
+	for _, span := range rec.Ended() {
+		if span.Name() == "cache get" && span.Parent().SpanID() == server.SpanContext().SpanID() {
+			cache = true
+		}
+	}
+	if !cache {
+		t.Error("Expected a cache span as a child of the server span")
+	}
+}
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"embed"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"order-service/internal/models"
+)
+
+//go:embed web
+var webFS embed.FS
+
+var indexTemplate = template.Must(template.ParseFS(webFS, "web/index.html"))
+
+// contentSecurityPolicy only allows scripts and styles served from /static/,
+// so markup injected through order data cannot execute.
+const contentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; " +
+	"img-src 'self' data:; connect-src 'self'; base-uri 'none'; form-action 'none'; " +
+	"frame-ancestors 'none'"
+
+// recentOrdersOnIndex is the number of orders rendered into the page itself.
+const recentOrdersOnIndex = 10
+
+func setSecurityHeaders(w http.ResponseWriter) {
+	h := w.Header()
+	h.Set("Content-Security-Policy", contentSecurityPolicy)
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Referrer-Policy", "no-referrer")
+}
+
+type indexData struct {
+	TotalOrders interface{}
+	Recent      []models.Order
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The page itself is public. Order data is only rendered into it when
+	// the API is unauthenticated anyway; otherwise the scripts load it
+	// with the user's credentials.
+	data := indexData{TotalOrders: "…"}
+	if s.auth == nil {
+		data.TotalOrders = s.cache.Size()
+		data.Recent = s.recentOrders(recentOrdersOnIndex)
+	}
+
+	setSecurityHeaders(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, data); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render index", "error", err)
+	}
+}
+
+func (s *Server) recentOrders(n int) []models.Order {
+	orders := s.cache.GetAll()
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].DateCreated.After(orders[j].DateCreated)
+	})
+	if len(orders) > n {
+		orders = orders[:n]
+	}
+	return orders
+}
+
+func (s *Server) staticHandler() http.Handler {
+	static, err := fs.Sub(webFS, "web/static")
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix("/static/", http.FileServer(http.FS(static)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setSecurityHeaders(w)
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,486 @@
+'use strict';
+
+// All order data is rendered with textContent through el(); markup is never
+// built from strings, so field values cannot inject HTML or script.
+function el(tag, attrs, ...children) {
+    const node = document.createElement(tag);
+    for (const [name, value] of Object.entries(attrs || {})) {
+        if (name === 'onclick') {
+            node.addEventListener('click', value);
+        } else if (name === 'className') {
+            node.className = value;
+        } else {
+            node.setAttribute(name, value);
+        }
+    }
+    for (const child of children) {
+        if (child === null || child === undefined) continue;
+        node.appendChild(child instanceof Node ? child : document.createTextNode(String(child)));
+    }
+    return node;
+}
+
+// svg is el() for chart elements, which live in the SVG namespace.
+function svg(tag, attrs, ...children) {
+    const node = document.createElementNS('http://www.w3.org/2000/svg', tag);
+    for (const [name, value] of Object.entries(attrs || {})) {
+        node.setAttribute(name, String(value));
+    }
+    for (const child of children) {
+        node.appendChild(child instanceof Node ? child : document.createTextNode(String(child)));
+    }
+    return node;
+}
+
+// UI strings live in /static/i18n/<lang>.json. Elements carrying
+// data-i18n (text) or data-i18n-placeholder are translated in place; the
+// English text in the page is the fallback.
+const languages = ['en', 'ru'];
+let strings = {};
+let language = 'en';
+
+function t(key, ...args) {
+    const text = strings[key] || key;
+    return text.replace(/\{(\d+)\}/g, (match, i) => (i < args.length ? String(args[i]) : match));
+}
+
+async function setLanguage(lang) {
+    if (!languages.includes(lang)) lang = 'en';
+    const response = await fetch('/static/i18n/' + lang + '.json');
+    strings = await response.json();
+    language = lang;
+    localStorage.setItem('lang', lang);
+    document.documentElement.lang = lang;
+    document.getElementById('language').value = lang;
+
+    document.querySelectorAll('[data-i18n]').forEach(node => {
+        node.textContent = t(node.dataset.i18n);
+    });
+    document.querySelectorAll('[data-i18n-placeholder]').forEach(node => {
+        node.placeholder = t(node.dataset.i18nPlaceholder);
+    });
+}
+
+function initialLanguage() {
+    const saved = localStorage.getItem('lang');
+    if (saved) return saved;
+    return (navigator.language || 'en').slice(0, 2);
+}
+
+function formatDate(value) {
+    if (!value) return '';
+    return new Date(value).toLocaleString(language);
+}
+
+// Amounts travel in minor units; the number of fraction digits is taken
+// from the browser's knowledge of the currency.
+function formatMoney(amount, currency) {
+    if (amount === undefined || amount === null) return '';
+    try {
+        const format = new Intl.NumberFormat(language, { style: 'currency', currency });
+        const digits = format.resolvedOptions().maximumFractionDigits;
+        return format.format(amount / Math.pow(10, digits));
+    } catch (error) {
+        return amount + ' ' + currency;
+    }
+}
+
+function show(...nodes) {
+    document.getElementById('result').replaceChildren(...nodes);
+}
+
+function showError(message) {
+    show(el('div', { className: 'error' }, message));
+}
+
+// API requests carry the key stored in this browser session. When the
+// server asks for authentication the user is prompted for a key.
+async function apiFetch(url, options) {
+    const headers = Object.assign({}, (options || {}).headers);
+    const key = sessionStorage.getItem('apiKey');
+    if (key) {
+        headers['X-API-Key'] = key;
+    }
+
+    const response = await fetch(url, Object.assign({}, options, { headers }));
+    if (response.status === 401) {
+        const entered = prompt(t('auth.prompt'));
+        if (entered) {
+            sessionStorage.setItem('apiKey', entered);
+            return apiFetch(url, options);
+        }
+    }
+    return response;
+}
+
+async function loadStats() {
+    const info = document.getElementById('stats-info');
+    try {
+        const response = await apiFetch('/api/stats');
+        const data = await response.json();
+        info.replaceChildren(el('strong', {}, t('stats.total', data.total_orders)));
+    } catch (error) {
+        info.replaceChildren(el('span', { className: 'error' }, t('stats.failed')));
+    }
+}
+
+// Filters and table state shared by the order table and the charts.
+const table = { page: 1, perPage: 25, sort: 'date_created', order: 'desc' };
+
+function filterParams() {
+    const params = new URLSearchParams();
+    new FormData(document.getElementById('filters')).forEach((value, name) => {
+        value = String(value).trim();
+        if (value) params.set(name, value);
+    });
+    return params;
+}
+
+async function loadFacets() {
+    try {
+        const response = await apiFetch('/api/dashboard/facets');
+        const data = await response.json();
+        const form = document.getElementById('filters');
+        fillSelect(form.elements.delivery_service, data.delivery_services);
+        fillSelect(form.elements.currency, data.currencies);
+    } catch (error) {
+        // Filters stay usable with the "any" option only.
+    }
+}
+
+function fillSelect(select, values) {
+    const current = select.value;
+    select.replaceChildren(select.options[0], ...(values || []).map(v => el('option', { value: v }, v)));
+    select.value = current;
+}
+
+function searchOrder() {
+    const orderUID = document.getElementById('orderUID').value.trim();
+    if (!orderUID) {
+        alert(t('search.required'));
+        return;
+    }
+    loadOrderByUID(orderUID);
+}
+
+const columns = ['order_uid', 'date_created', 'customer_id', 'delivery_service', 'amount', 'items'];
+
+function sortBy(column) {
+    if (table.sort === column) {
+        table.order = table.order === 'asc' ? 'desc' : 'asc';
+    } else {
+        table.sort = column;
+        table.order = column === 'date_created' || column === 'amount' ? 'desc' : 'asc';
+    }
+    table.page = 1;
+    loadAllOrders();
+}
+
+function goToPage(page) {
+    table.page = page;
+    loadAllOrders();
+}
+
+async function loadAllOrders() {
+    try {
+        const params = filterParams();
+        params.set('page', table.page);
+        params.set('per_page', table.perPage);
+        params.set('sort', table.sort);
+        params.set('order', table.order);
+
+        const response = await apiFetch('/api/dashboard/orders?' + params);
+        const data = await response.json();
+        if (!response.ok) throw new Error(data.error || response.status);
+
+        if (data.total === 0) {
+            show(el('div', { className: 'result' }, el('p', {}, t('orders.empty'))));
+            return;
+        }
+
+        const header = el('tr', {}, ...columns.map(column => {
+            const arrow = table.sort === column ? (table.order === 'asc' ? ' ▲' : ' ▼') : '';
+            return el('th', { className: 'sortable', onclick: () => sortBy(column) },
+                t('column.' + column) + arrow);
+        }));
+
+        const rows = data.items.map(order => el('tr', {
+            className: 'clickable',
+            onclick: () => loadOrderByUID(order.order_uid),
+        },
+            el('td', {}, el('code', {}, order.order_uid)),
+            el('td', {}, formatDate(order.date_created)),
+            el('td', {}, order.customer_id),
+            el('td', {}, order.delivery_service),
+            el('td', { className: 'number' }, formatMoney(order.amount, order.currency)),
+            el('td', { className: 'number' }, order.items)));
+
+        const pager = el('div', { className: 'pager' },
+            el('button', { type: 'button', onclick: () => goToPage(data.page - 1) }, t('orders.prev')),
+            el('span', {}, t('orders.page', data.page, Math.max(data.total_pages, 1))),
+            el('button', { type: 'button', onclick: () => goToPage(data.page + 1) }, t('orders.next')));
+        pager.firstChild.disabled = data.page <= 1;
+        pager.lastChild.disabled = data.page >= data.total_pages;
+
+        show(el('div', { className: 'result' },
+            el('h2', {}, t('orders.title', data.total)),
+            el('p', { className: 'hint' }, t('orders.hint')),
+            el('table', { className: 'orders-table' }, el('thead', {}, header), el('tbody', {}, ...rows)),
+            pager));
+    } catch (error) {
+        showError(t('error', error.message));
+    }
+}
+
+async function loadOrderByUID(uid) {
+    try {
+        const response = await apiFetch('/api/orders/' + encodeURIComponent(uid) + '?reporting=true');
+        if (response.status === 404) {
+            showError(t('detail.not_found'));
+            return;
+        }
+
+        const order = await response.json();
+        displayOrder(order);
+        document.getElementById('orderUID').value = uid;
+    } catch (error) {
+        showError(t('error', error.message));
+    }
+}
+
+function section(title, ...children) {
+    return el('div', { className: 'order-section' }, title ? el('h3', {}, title) : null, ...children);
+}
+
+const moneyFields = ['amount', 'delivery_cost', 'goods_total', 'custom_fee', 'price', 'total_price'];
+
+function fieldValue(name, value, currency) {
+    if (moneyFields.includes(name) && currency) {
+        return formatMoney(value, currency) + ' (' + value + ')';
+    }
+    if (name === 'payment_dt' && value) {
+        return formatDate(value * 1000) + ' (' + value + ')';
+    }
+    if (name === 'date_created') {
+        return formatDate(value);
+    }
+    return value;
+}
+
+// fields lists every scalar property of obj, so new model fields show up
+// without changes here.
+function fields(obj, currency) {
+    return el('dl', { className: 'fields' }, ...Object.entries(obj)
+        .filter(([, value]) => value === null || typeof value !== 'object')
+        .flatMap(([name, value]) => [
+            el('dt', {}, t('field.' + name)),
+            el('dd', {}, fieldValue(name, value, currency)),
+        ]));
+}
+
+function formatOrderCard(order) {
+    const payment = order.payment || {};
+    const card = el('div', { className: 'order-card' },
+        el('div', { className: 'order-header' }, t('field.order_uid') + ': ' + order.order_uid),
+        section(t('detail.order'), fields(order)));
+
+    if (order.delivery) {
+        card.appendChild(section(t('detail.delivery'), fields(order.delivery)));
+    }
+    if (order.payment) {
+        card.appendChild(section(t('detail.payment'), fields(payment, payment.currency)));
+    }
+    if (order.reporting) {
+        card.appendChild(section(t('detail.reporting'),
+            fields(order.reporting, order.reporting.currency)));
+    }
+    if (order.items && order.items.length > 0) {
+        card.appendChild(section(t('detail.items', order.items.length),
+            ...order.items.map(item => el('div', { className: 'item-card' },
+                el('div', {}, el('strong', {}, item.name), ' - ' + item.brand),
+                fields(item, payment.currency)))));
+    }
+
+    return card;
+}
+
+function displayOrder(order) {
+    const details = formatOrderCard(order);
+    const raw = el('pre', { className: 'hidden' }, JSON.stringify(order, null, 2));
+
+    const tabs = el('div', { className: 'tabs' });
+    const tab = (label, panel) => el('button', {
+        type: 'button',
+        onclick: event => {
+            details.classList.toggle('hidden', panel !== details);
+            raw.classList.toggle('hidden', panel !== raw);
+            tabs.querySelectorAll('button').forEach(b => b.classList.toggle('active', b === event.target));
+        },
+    }, label);
+    tabs.append(tab(t('detail.tab_fields'), details), tab(t('detail.tab_raw'), raw));
+    tabs.firstChild.classList.add('active');
+
+    show(el('div', { className: 'result' },
+        el('button', { className: 'back-button', type: 'button', onclick: loadAllOrders }, t('detail.back')),
+        el('h2', {}, t('detail.title')),
+        tabs, details, raw));
+}
+
+// Charts are plain SVG bar charts built from /api/dashboard/timeseries.
+function barChart(target, buckets, value, format) {
+    const container = document.getElementById(target);
+    if (buckets.length === 0) {
+        container.replaceChildren(el('p', { className: 'hint' }, t('chart.empty')));
+        return;
+    }
+
+    const width = 560, height = 200, pad = 24;
+    const max = Math.max(1, ...buckets.map(value));
+    const barWidth = (width - pad) / buckets.length;
+    const chart = svg('svg', { viewBox: '0 0 ' + width + ' ' + (height + pad), class: 'bar-chart' });
+
+    buckets.forEach((bucket, i) => {
+        const v = value(bucket);
+        const h = (v / max) * (height - pad);
+        const label = new Date(bucket.start).toLocaleDateString(language) + ': ' + format(v);
+        chart.appendChild(svg('rect', {
+            x: pad + i * barWidth + 1,
+            y: height - h,
+            width: Math.max(barWidth - 2, 1),
+            height: h,
+            class: 'bar',
+        }, svg('title', {}, label)));
+    });
+    chart.appendChild(svg('text', { x: pad, y: 12, class: 'axis' }, format(max)));
+    chart.appendChild(svg('text', { x: pad, y: height + pad - 4, class: 'axis' },
+        new Date(buckets[0].start).toLocaleDateString(language)));
+    chart.appendChild(svg('text', { x: width, y: height + pad - 4, class: 'axis end' },
+        new Date(buckets[buckets.length - 1].start).toLocaleDateString(language)));
+    container.replaceChildren(chart);
+}
+
+async function loadCharts() {
+    try {
+        const params = filterParams();
+        params.set('interval', 'day');
+        const response = await apiFetch('/api/dashboard/timeseries?' + params);
+        if (!response.ok) return;
+        const data = await response.json();
+
+        barChart('chart-volume', data.buckets, b => b.orders, v => String(v));
+
+        // Revenue is charted in the reporting currency when the server has
+        // one, otherwise in the filtered (or first) payment currency.
+        let currency = data.reporting_currency || params.get('currency');
+        if (!currency) {
+            const seen = new Set();
+            data.buckets.forEach(b => Object.keys(b.revenue).forEach(c => seen.add(c)));
+            currency = [...seen].sort()[0] || '';
+        }
+        const revenue = data.reporting_currency
+            ? b => b.reporting_revenue || 0
+            : b => b.revenue[currency] || 0;
+        document.querySelector('[data-i18n="chart.revenue"]').textContent =
+            currency ? t('chart.revenue_in', currency) : t('chart.revenue');
+        barChart('chart-revenue', data.buckets, revenue, v => formatMoney(v, currency));
+    } catch (error) {
+        // Charts are optional; a missing analytics scope leaves them empty.
+    }
+}
+
+function refresh() {
+    table.page = 1;
+    loadAllOrders();
+    loadCharts();
+}
+
+// Live feed over Server-Sent Events. fetch is used instead of EventSource so
+// that the API key header can be sent.
+let lastEventID = '';
+
+function showLiveOrder(order) {
+    const payment = order.payment || {};
+    const item = el('div', {
+        className: 'order-list-item',
+        onclick: () => loadOrderByUID(order.order_uid),
+    },
+        el('div', { className: 'uid' }, order.order_uid),
+        el('div', { className: 'info' },
+            (order.delivery_service || '') + ' · ' + formatMoney(payment.amount, payment.currency)));
+
+    const list = document.getElementById('live-orders');
+    list.insertBefore(item, list.firstChild);
+    while (list.children.length > 10) {
+        list.removeChild(list.lastChild);
+    }
+}
+
+function handleStreamEvent(block) {
+    let id = '', data = '';
+    block.split('\n').forEach(line => {
+        if (line.startsWith('id: ')) id = line.slice(4);
+        if (line.startsWith('data: ')) data += line.slice(6);
+    });
+    if (id) lastEventID = id;
+    if (!data) return;
+
+    const msg = JSON.parse(data);
+    if (msg.type === 'reset') {
+        refresh();
+        return;
+    }
+    showLiveOrder(msg.order);
+    loadStats();
+}
+
+async function streamOrders() {
+    const status = document.getElementById('live-status');
+    try {
+        const headers = { 'Accept': 'text/event-stream' };
+        if (lastEventID) headers['Last-Event-ID'] = lastEventID;
+
+        const response = await apiFetch('/api/orders/stream', { headers });
+        if (!response.ok) throw new Error('HTTP ' + response.status);
+        status.textContent = t('live.connected');
+
+        const reader = response.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = '';
+        for (;;) {
+            const { value, done } = await reader.read();
+            if (done) break;
+            buffer += decoder.decode(value, { stream: true });
+            let end;
+            while ((end = buffer.indexOf('\n\n')) >= 0) {
+                handleStreamEvent(buffer.slice(0, end));
+                buffer = buffer.slice(end + 2);
+            }
+        }
+    } catch (error) {
+        status.textContent = t('live.disconnected', error.message);
+    }
+    setTimeout(streamOrders, 3000);
+}
+
+document.getElementById('search-button').addEventListener('click', searchOrder);
+document.getElementById('orderUID').addEventListener('keypress', event => {
+    if (event.key === 'Enter') searchOrder();
+});
+document.getElementById('filters').addEventListener('submit', event => {
+    event.preventDefault();
+    refresh();
+});
+document.getElementById('filters').addEventListener('reset', () => setTimeout(refresh));
+document.getElementById('language').addEventListener('change', async event => {
+    await setLanguage(event.target.value);
+    loadStats();
+    refresh();
+});
+
+setLanguage(initialLanguage()).finally(() => {
+    loadStats();
+    setInterval(loadStats, 5000);
+    loadFacets();
+    refresh();
+    streamOrders();
+});
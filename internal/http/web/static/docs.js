@@ -0,0 +1,163 @@
+'use strict';
+
+// Renders /api/openapi.json with DOM APIs only; descriptions and examples
+// are inserted as text, like the dashboard does with order data.
+function el(tag, attrs, ...children) {
+    const node = document.createElement(tag);
+    for (const [name, value] of Object.entries(attrs || {})) {
+        if (name === 'onclick') {
+            node.addEventListener('click', value);
+        } else if (name === 'className') {
+            node.className = value;
+        } else {
+            node.setAttribute(name, value);
+        }
+    }
+    for (const child of children) {
+        if (child === null || child === undefined) continue;
+        node.appendChild(child instanceof Node ? child : document.createTextNode(String(child)));
+    }
+    return node;
+}
+
+let spec = {};
+
+function resolve(obj) {
+    if (!obj || !obj.$ref) return obj;
+    return obj.$ref.split('/').slice(1).reduce((node, key) => node[key], spec);
+}
+
+function typeName(schema) {
+    if (!schema) return '';
+    if (schema.$ref) return schema.$ref.split('/').pop();
+    if (schema.type === 'array') return typeName(schema.items) + '[]';
+    if (schema.oneOf) return schema.oneOf.map(typeName).join(' | ');
+    if (schema.allOf) return schema.allOf.map(typeName).join(' & ');
+    let name = schema.type || 'object';
+    if (schema.format) name += ' (' + schema.format + ')';
+    if (schema.enum) name += ': ' + schema.enum.join(', ');
+    return name;
+}
+
+async function send(method, path, params, inputs, output) {
+    let url = path;
+    const query = new URLSearchParams();
+    const headers = {};
+    params.forEach((param, i) => {
+        const value = inputs[i].value.trim();
+        if (!value) return;
+        if (param.in === 'path') url = url.replace('{' + param.name + '}', encodeURIComponent(value));
+        if (param.in === 'query') query.set(param.name, value);
+        if (param.in === 'header') headers[param.name] = value;
+    });
+    const key = sessionStorage.getItem('apiKey');
+    if (key) headers['X-API-Key'] = key;
+    if (query.toString()) url += '?' + query;
+
+    output.textContent = method.toUpperCase() + ' ' + url + ' …';
+    try {
+        const response = await fetch(url, { method: method.toUpperCase(), headers });
+        const text = await response.text();
+        output.textContent = response.status + ' ' + response.statusText + '\n\n' + text.slice(0, 20000);
+    } catch (error) {
+        output.textContent = error.message;
+    }
+}
+
+function operation(path, method, op) {
+    const params = (op.parameters || []).map(resolve);
+    const inputs = params.map(() => el('input', { type: 'text' }));
+    const output = el('pre', {});
+
+    const details = el('details', { className: 'operation' },
+        el('summary', {},
+            el('span', { className: 'method ' + method }, method.toUpperCase()),
+            el('code', {}, path), ' ', op.summary,
+            op['x-required-scope'] ? el('span', { className: 'scope' }, op['x-required-scope']) : null));
+
+    if (op.description) details.appendChild(el('p', {}, op.description));
+
+    if (params.length > 0) {
+        details.appendChild(el('h4', {}, 'Parameters'));
+        details.appendChild(el('table', { className: 'params' },
+            el('thead', {}, el('tr', {}, el('th', {}, 'Name'), el('th', {}, 'In'),
+                el('th', {}, 'Type'), el('th', {}, 'Description'), el('th', {}, 'Value'))),
+            el('tbody', {}, ...params.map((p, i) => el('tr', {},
+                el('td', {}, el('code', {}, p.name), p.required ? ' *' : ''),
+                el('td', {}, p.in),
+                el('td', {}, typeName(p.schema)),
+                el('td', {}, p.description || ''),
+                el('td', {}, inputs[i]))))));
+    }
+
+    details.appendChild(el('h4', {}, 'Responses'));
+    details.appendChild(el('table', { className: 'params' },
+        el('tbody', {}, ...Object.entries(op.responses).map(([code, response]) => {
+            response = resolve(response);
+            const types = Object.entries(response.content || {})
+                .map(([mime, media]) => mime + ' ' + typeName(media.schema)).join('; ');
+            return el('tr', {}, el('td', {}, code), el('td', {}, response.description), el('td', {}, types));
+        }))));
+
+    if (method === 'get' && !path.endsWith('/ws')) {
+        details.appendChild(el('button', {
+            className: 'try', type: 'button',
+            onclick: () => send(method, path, params, inputs, output),
+        }, 'Send request'));
+        details.appendChild(output);
+    }
+    return details;
+}
+
+function schemaTable(name, schema) {
+    const parts = schema.allOf ? schema.allOf : [schema];
+    const rows = [];
+    parts.forEach(part => {
+        if (part.$ref) {
+            rows.push(el('tr', {}, el('td', { colspan: '3' }, 'All properties of ' + typeName(part))));
+            return;
+        }
+        Object.entries(part.properties || {}).forEach(([prop, s]) => {
+            const required = (part.required || []).includes(prop);
+            rows.push(el('tr', {},
+                el('td', {}, el('code', {}, prop), required ? ' *' : ''),
+                el('td', {}, typeName(s)),
+                el('td', {}, s.description || '')));
+        });
+    });
+    return el('div', { className: 'schema', id: 'schema-' + name },
+        el('h3', {}, name),
+        schema.description ? el('p', { className: 'hint' }, schema.description) : null,
+        el('table', { className: 'params' }, el('tbody', {}, ...rows)));
+}
+
+async function load() {
+    const response = await fetch('/api/openapi.json');
+    spec = await response.json();
+
+    document.title = spec.info.title;
+    document.getElementById('api-description').textContent = spec.info.description;
+
+    const operations = document.getElementById('operations');
+    Object.entries(spec.paths).forEach(([path, item]) => {
+        Object.entries(item).forEach(([method, op]) => operations.appendChild(operation(path, method, op)));
+    });
+
+    const schemas = document.getElementById('schemas');
+    schemas.appendChild(el('h2', {}, 'Schemas'));
+    Object.entries(spec.components.schemas).forEach(([name, schema]) => {
+        schemas.appendChild(schemaTable(name, schema));
+    });
+}
+
+document.getElementById('api-key').value = sessionStorage.getItem('apiKey') || '';
+document.getElementById('save-key').addEventListener('click', () => {
+    const key = document.getElementById('api-key').value.trim();
+    if (key) {
+        sessionStorage.setItem('apiKey', key);
+    } else {
+        sessionStorage.removeItem('apiKey');
+    }
+});
+
+load();
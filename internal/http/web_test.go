@@ -0,0 +1,242 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"order-service/internal/masking"
+	"order-service/internal/models"
+	"order-service/internal/nats"
+)
+
+var hostileValues = []string{
+	`<script>alert("xss")</script>`,
+	`"><img src=x onerror=alert(1)>`,
+	`javascript:alert(1)`,
+	`</textarea><svg onload=alert(1)>`,
+}
+
+// hostileMessage is an order message as a malicious producer would publish
+// it, with markup in its text fields.
+func hostileMessage(t *testing.T, uid string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"order_uid":        uid,
+		"track_number":     hostileValues[1],
+		"entry":            "WBIL",
+		"customer_id":      hostileValues[0],
+		"delivery_service": hostileValues[1],
+		"delivery": map[string]any{
+			"name":    hostileValues[0],
+			"address": hostileValues[3],
+			"email":   hostileValues[2],
+		},
+		"payment": map[string]any{"currency": "USD", "amount": 0},
+		"items":   []any{map[string]any{"name": hostileValues[0], "brand": hostileValues[1], "price": 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+type discardRepo struct{}
+
+func (discardRepo) SaveOrder(context.Context, *models.Order) error { return nil }
+
+// ingestHostileOrder decodes a hostile message and stores it in cache
+// through the same pipeline as the NATS subscriber.
+func ingestHostileOrder(t *testing.T, cache *mockCache, uid string) {
+	t.Helper()
+	order, err := nats.DecodeMessage(hostileMessage(t, uid))
+	if err != nil {
+		t.Fatalf("DecodeMessage: %v", err)
+	}
+	if err := nats.NewPipeline(discardRepo{}, cache).Process(context.Background(), order); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if _, ok := cache.Get(uid); !ok {
+		t.Fatal("Expected the hostile order to be cached")
+	}
+}
+
+func TestIndexEscapesHostileOrderFields(t *testing.T) {
+	cache := newMockCache()
+	ingestHostileOrder(t, cache, "<b>uid</b>")
+	handler := NewServer(cache).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, raw := range []string{"<script>alert", "<img src=x", "<svg onload", "<b>uid</b>"} {
+		if strings.Contains(body, raw) {
+			t.Errorf("Expected %q to be escaped in index page", raw)
+		}
+	}
+	if !strings.Contains(body, "&lt;script&gt;") {
+		t.Error("Expected escaped customer ID to be rendered")
+	}
+}
+
+func TestIndexSecurityHeaders(t *testing.T) {
+	handler := NewServer(newMockCache()).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	csp := w.Header().Get("Content-Security-Policy")
+	for _, directive := range []string{"default-src 'none'", "script-src 'self'", "style-src 'self'"} {
+		if !strings.Contains(csp, directive) {
+			t.Errorf("Expected CSP to contain %q, got %q", directive, csp)
+		}
+	}
+	if strings.Contains(csp, "unsafe-inline") || strings.Contains(csp, "unsafe-eval") {
+		t.Errorf("CSP must not allow inline code: %q", csp)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("Expected X-Content-Type-Options: nosniff")
+	}
+
+	// The strict CSP only works if the page has no inline code at all
+	body := w.Body.String()
+	if regexp.MustCompile(`<script(?:\s[^>]*)?>[^<]+</script>`).MatchString(body) {
+		t.Error("Index page contains an inline script")
+	}
+	if regexp.MustCompile(`(?i)\son[a-z]+\s*=`).MatchString(body) {
+		t.Error("Index page contains an inline event handler")
+	}
+	if strings.Contains(body, "<style") || strings.Contains(body, "style=") {
+		t.Error("Index page contains inline styles")
+	}
+}
+
+func TestIndexOmitsOrdersWhenAuthenticated(t *testing.T) {
+	server := newAuthServer(t)
+	server.cache.(*mockCache).Set("secret", &models.Order{OrderUID: "secret-order-uid"})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "secret-order-uid") {
+		t.Error("Expected order data not to be rendered for unauthenticated visitors")
+	}
+}
+
+func TestAPIEscapesHostileValues(t *testing.T) {
+	cache := newMockCache()
+	ingestHostileOrder(t, cache, "test123")
+	server := NewServer(cache, WithMasking(masking.DefaultPolicy(), masking.RoleSupport))
+
+	for _, path := range []string{"/api/orders/test123", "/api/orders"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		server.Handler().ServeHTTP(w, req)
+
+		body := w.Body.String()
+		if strings.ContainsAny(body, "<>") {
+			t.Errorf("%s: expected < and > to be escaped in JSON, got %s", path, body)
+		}
+		if !strings.Contains(body, `\u003cscript\u003e`) {
+			t.Errorf("%s: expected escaped hostile value in JSON", path)
+		}
+	}
+}
+
+func TestDashboardScriptAvoidsHTMLSinks(t *testing.T) {
+	for _, name := range []string{"app.js", "docs.js"} {
+		script, err := webFS.ReadFile("web/static/" + name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+
+		for _, sink := range []string{"innerHTML", "outerHTML", "insertAdjacentHTML", "document.write", "eval(", "new Function"} {
+			if strings.Contains(string(script), sink) {
+				t.Errorf("%s must not use %s", name, sink)
+			}
+		}
+	}
+}
+
+func TestStaticAssetsServed(t *testing.T) {
+	handler := NewServer(newMockCache()).Handler()
+
+	cases := map[string]string{
+		"/static/app.js":       "javascript",
+		"/static/app.css":      "text/css",
+		"/static/i18n/en.json": "application/json",
+		"/static/i18n/ru.json": "application/json",
+	}
+	for path, contentType := range cases {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.Contains(ct, contentType) {
+			t.Errorf("%s: expected content type %s, got %s", path, contentType, ct)
+		}
+		if w.Header().Get("Content-Security-Policy") == "" {
+			t.Errorf("%s: expected CSP header", path)
+		}
+	}
+}
+
+func TestLocalesDefineSameKeys(t *testing.T) {
+	load := func(lang string) map[string]string {
+		data, err := webFS.ReadFile("web/static/i18n/" + lang + ".json")
+		if err != nil {
+			t.Fatalf("Failed to read %s strings: %v", lang, err)
+		}
+		var strings map[string]string
+		if err := json.Unmarshal(data, &strings); err != nil {
+			t.Fatalf("Failed to parse %s strings: %v", lang, err)
+		}
+		return strings
+	}
+
+	en, ru := load("en"), load("ru")
+	for key := range en {
+		if _, ok := ru[key]; !ok {
+			t.Errorf("ru is missing %q", key)
+		}
+	}
+	for key := range ru {
+		if _, ok := en[key]; !ok {
+			t.Errorf("en is missing %q", key)
+		}
+	}
+
+	// Every key the page uses is defined, and headings carry a key
+	page, err := webFS.ReadFile("web/index.html")
+	if err != nil {
+		t.Fatalf("Failed to read index.html: %v", err)
+	}
+	keys := regexp.MustCompile(`data-i18n(?:-placeholder)?="([^"]+)"`).FindAllSubmatch(page, -1)
+	if len(keys) == 0 {
+		t.Fatal("Expected index.html to use data-i18n keys")
+	}
+	for _, m := range keys {
+		for lang, strings := range map[string]map[string]string{"en": en, "ru": ru} {
+			if _, ok := strings[string(m[1])]; !ok {
+				t.Errorf("%s is missing %q used by index.html", lang, m[1])
+			}
+		}
+	}
+	for _, m := range regexp.MustCompile(`<(h[1-6]|th|button|label)>\s*[^<\s{][^<]*`).FindAll(page, -1) {
+		t.Errorf("index.html has untranslated text %q", m)
+	}
+}
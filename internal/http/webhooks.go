@@ -0,0 +1,195 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"order-service/internal/models"
+)
+
+// WebhookStore manages webhook subscriptions and their delivery history.
+type WebhookStore interface {
+	CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) (bool, error)
+	ListDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]models.WebhookDelivery, error)
+}
+
+const (
+	minWebhookSecret      = 16
+	defaultDeliveryLimit  = 50
+	maxDeliveryLimit      = 500
+	generatedSecretLength = 32
+)
+
+// handleWebhooks serves /api/webhooks: GET lists subscriptions and POST
+// creates one.
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		http.Error(w, "Webhooks are not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		subs, err := s.webhooks.ListSubscriptions(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list webhook subscriptions", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to list subscriptions")
+			return
+		}
+		writeJSON(w, subs)
+
+	case http.MethodPost:
+		var sub models.WebhookSubscription
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		if err := prepareSubscription(&sub); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.webhooks.CreateSubscription(r.Context(), &sub); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create webhook subscription", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to create subscription")
+			return
+		}
+		slog.InfoContext(r.Context(), "Created webhook subscription", "subscription_id", sub.ID, "url", sub.URL)
+		// The secret is returned this once so the receiver can verify
+		// signatures.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sub)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleWebhook serves /api/webhooks/{id} and /api/webhooks/{id}/deliveries.
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		http.Error(w, "Webhooks are not configured", http.StatusNotFound)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/api/webhooks/")
+	idPart, sub, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "Subscription not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		subscription, err := s.webhooks.GetSubscription(r.Context(), id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get webhook subscription", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to get subscription")
+			return
+		}
+		if subscription == nil {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, subscription)
+
+	case sub == "" && r.Method == http.MethodDelete:
+		deleted, err := s.webhooks.DeleteSubscription(r.Context(), id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to delete webhook subscription", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to delete subscription")
+			return
+		}
+		if !deleted {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		slog.InfoContext(r.Context(), "Deleted webhook subscription", "subscription_id", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	case sub == "deliveries" && r.Method == http.MethodGet:
+		limit, err := parseDeliveryLimit(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		subscription, err := s.webhooks.GetSubscription(r.Context(), id)
+		if err == nil && subscription == nil {
+			http.Error(w, "Subscription not found", http.StatusNotFound)
+			return
+		}
+		var deliveries []models.WebhookDelivery
+		if err == nil {
+			deliveries, err = s.webhooks.ListDeliveries(r.Context(), id, limit)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to list webhook deliveries", "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "failed to list deliveries")
+			return
+		}
+		writeJSON(w, deliveries)
+
+	case sub == "" || sub == "deliveries":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// prepareSubscription validates a subscription submitted through the API
+// and generates its secret if none was given.
+func prepareSubscription(sub *models.WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	if len(sub.EventTypes) == 0 {
+		return fmt.Errorf("event_types must not be empty")
+	}
+	for _, eventType := range sub.EventTypes {
+		if !slices.Contains(models.WebhookEvents, eventType) {
+			return fmt.Errorf("unknown event type %q, expected one of %s",
+				eventType, strings.Join(models.WebhookEvents, ", "))
+		}
+	}
+	slices.Sort(sub.EventTypes)
+	sub.EventTypes = slices.Compact(sub.EventTypes)
+
+	switch {
+	case sub.Secret == "":
+		buf := make([]byte, generatedSecretLength)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		sub.Secret = hex.EncodeToString(buf)
+	case len(sub.Secret) < minWebhookSecret:
+		return fmt.Errorf("secret must be at least %d characters", minWebhookSecret)
+	}
+	return nil
+}
+
+func parseDeliveryLimit(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return defaultDeliveryLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxDeliveryLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxDeliveryLimit)
+	}
+	return limit, nil
+}
@@ -0,0 +1,146 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+type mockWebhookStore struct {
+	subs       map[int64]models.WebhookSubscription
+	deliveries map[int64][]models.WebhookDelivery
+	lastLimit  int
+}
+
+func newMockWebhookStore() *mockWebhookStore {
+	return &mockWebhookStore{
+		subs:       map[int64]models.WebhookSubscription{},
+		deliveries: map[int64][]models.WebhookDelivery{},
+	}
+}
+
+func (m *mockWebhookStore) CreateSubscription(_ context.Context, sub *models.WebhookSubscription) error {
+	sub.ID = int64(len(m.subs) + 1)
+	sub.CreatedAt = time.Now()
+	m.subs[sub.ID] = *sub
+	return nil
+}
+
+func (m *mockWebhookStore) ListSubscriptions(context.Context) ([]models.WebhookSubscription, error) {
+	subs := []models.WebhookSubscription{}
+	for _, sub := range m.subs {
+		sub.Secret = ""
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (m *mockWebhookStore) GetSubscription(_ context.Context, id int64) (*models.WebhookSubscription, error) {
+	sub, ok := m.subs[id]
+	if !ok {
+		return nil, nil
+	}
+	sub.Secret = ""
+	return &sub, nil
+}
+
+func (m *mockWebhookStore) DeleteSubscription(_ context.Context, id int64) (bool, error) {
+	_, ok := m.subs[id]
+	delete(m.subs, id)
+	return ok, nil
+}
+
+func (m *mockWebhookStore) ListDeliveries(_ context.Context, id int64, limit int) ([]models.WebhookDelivery, error) {
+	m.lastLimit = limit
+	return m.deliveries[id], nil
+}
+
+func TestWebhookSubscriptionLifecycle(t *testing.T) {
+	store := newMockWebhookStore()
+	handler := NewServer(newMockCache(), WithWebhooks(store)).Handler()
+
+	body := `{"url":"https://example.com/hook","event_types":["order.created","order.created"],"delivery_service":"meest"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", w.Code, w.Body)
+	}
+	var created models.WebhookSubscription
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.ID != 1 || len(created.Secret) < minWebhookSecret || len(created.EventTypes) != 1 {
+		t.Errorf("unexpected subscription %+v", created)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/webhooks/1", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("expected the subscription without its secret, got %d: %s", w.Code, w.Body)
+	}
+
+	store.deliveries[1] = []models.WebhookDelivery{{ID: 3, SubscriptionID: 1, Status: models.DeliveryDead, Secret: "hidden"}}
+	req = httptest.NewRequest(http.MethodGet, "/api/webhooks/1/deliveries?limit=10", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"dead"`) || strings.Contains(w.Body.String(), "hidden") {
+		t.Errorf("unexpected deliveries response %d: %s", w.Code, w.Body)
+	}
+	if store.lastLimit != 10 {
+		t.Errorf("expected limit 10, got %d", store.lastLimit)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/webhooks/1", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/api/webhooks/1", "/api/webhooks/1/deliveries", "/api/webhooks/abc", "/api/webhooks/1/other"} {
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestWebhookSubscriptionValidation(t *testing.T) {
+	handler := NewServer(newMockCache(), WithWebhooks(newMockWebhookStore())).Handler()
+
+	for _, body := range []string{
+		`{"url":"ftp://example.com","event_types":["order.created"]}`,
+		`{"url":"/relative","event_types":["order.created"]}`,
+		`{"url":"https://example.com","event_types":[]}`,
+		`{"url":"https://example.com","event_types":["order.shipped"]}`,
+		`{"url":"https://example.com","event_types":["order.created"],"secret":"short"}`,
+		`not json`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestWebhooksNotConfigured(t *testing.T) {
+	handler := NewServer(newMockCache()).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/webhooks", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := With(context.Background(), "subject", "orders", "sequence", uint64(42))
+	ctx = With(ctx, slog.String("order_uid", "b563feb7b2b84b6test"))
+	logger.InfoContext(ctx, "Order processed")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to parse %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]interface{}{
+		"msg": "Order processed", "subject": "orders", "sequence": float64(42), "order_uid": "b563feb7b2b84b6test",
+	} {
+		if record[key] != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, record[key])
+		}
+	}
+}
+
+func TestWithDoesNotModifyParent(t *testing.T) {
+	parent := With(context.Background(), "a", 1)
+	With(parent, "b", 2)
+	if len(Attrs(parent)) != 1 {
+		t.Errorf("Expected parent context to keep 1 attribute, got %d", len(Attrs(parent)))
+	}
+}
+
+func TestSensitiveAttributesRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, "debug", "text")
+
+	logger.Info("Delivery", "phone", "+79720000000", "Email", "test@gmail.com",
+		slog.Group("delivery", slog.String("address", "Ploshad Mira 15")), "order_uid", "visible")
+
+	out := buf.String()
+	for _, secret := range []string{"+79720000000", "test@gmail.com", "Ploshad Mira"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be redacted in %q", secret, out)
+		}
+	}
+	if !strings.Contains(out, "order_uid=visible") {
+		t.Errorf("Expected order_uid to be logged, got %q", out)
+	}
+}
+
+func TestLevelAndFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("Expected only warnings, got %q", buf.String())
+	}
+
+	if _, err := New(&buf, "loud", "text"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+	if _, err := New(&buf, "info", "xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,203 @@
+package masking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"order-service/internal/models"
+)
+
+// Role identifies the kind of caller an API response is rendered for.
+type Role string
+
+const (
+	RoleSupport Role = "support"
+	RoleAnalyst Role = "analyst"
+	RolePublic  Role = "public"
+)
+
+// Rule is the treatment applied to a single field.
+type Rule string
+
+const (
+	RuleFull     Rule = "full"     // value is returned unchanged
+	RuleMask     Rule = "mask"     // all but the last characters are replaced with '*'
+	RuleTruncate Rule = "truncate" // only the leading part of the value is kept
+	RuleRedact   Rule = "redact"   // value is removed
+)
+
+// fields maps the configurable field names to accessors on an order.
+var fields = map[string]func(o *models.Order) *string{
+	"delivery.name":       func(o *models.Order) *string { return &o.Delivery.Name },
+	"delivery.phone":      func(o *models.Order) *string { return &o.Delivery.Phone },
+	"delivery.zip":        func(o *models.Order) *string { return &o.Delivery.Zip },
+	"delivery.city":       func(o *models.Order) *string { return &o.Delivery.City },
+	"delivery.address":    func(o *models.Order) *string { return &o.Delivery.Address },
+	"delivery.region":     func(o *models.Order) *string { return &o.Delivery.Region },
+	"delivery.email":      func(o *models.Order) *string { return &o.Delivery.Email },
+	"payment.transaction": func(o *models.Order) *string { return &o.Payment.Transaction },
+	"payment.request_id":  func(o *models.Order) *string { return &o.Payment.RequestID },
+	"payment.provider":    func(o *models.Order) *string { return &o.Payment.Provider },
+	"payment.bank":        func(o *models.Order) *string { return &o.Payment.Bank },
+}
+
+// Policy holds the per-role rules. Fields without a rule are returned in
+// full; roles without an entry are treated as RolePublic.
+type Policy map[Role]map[string]Rule
+
+// DefaultPolicy returns the built-in rules: support sees everything, analysts
+// see masked contacts and a truncated address, public callers see no PII.
+func DefaultPolicy() Policy {
+	public := make(map[string]Rule, len(fields))
+	for name := range fields {
+		public[name] = RuleRedact
+	}
+	public["payment.provider"] = RuleFull
+	public["payment.bank"] = RuleFull
+
+	return Policy{
+		RoleSupport: {},
+		RoleAnalyst: {
+			"delivery.phone":   RuleMask,
+			"delivery.email":   RuleMask,
+			"delivery.address": RuleTruncate,
+		},
+		RolePublic: public,
+	}
+}
+
+// LoadPolicyFile reads a JSON policy of the form
+// {"analyst": {"delivery.phone": "mask"}} and merges it over DefaultPolicy.
+func LoadPolicyFile(path string) (Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read masking policy: %w", err)
+	}
+
+	var overrides Policy
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse masking policy: %w", err)
+	}
+
+	policy := DefaultPolicy()
+	for role, rules := range overrides {
+		if policy[role] == nil {
+			policy[role] = make(map[string]Rule)
+		}
+		for field, rule := range rules {
+			if _, ok := fields[field]; !ok {
+				return nil, fmt.Errorf("unknown field %q in masking policy", field)
+			}
+			switch rule {
+			case RuleFull, RuleMask, RuleTruncate, RuleRedact:
+			default:
+				return nil, fmt.Errorf("unknown rule %q for field %s", rule, field)
+			}
+			policy[role][field] = rule
+		}
+	}
+	return policy, nil
+}
+
+// Apply returns a copy of order with the role's rules applied. The original
+// order is never modified.
+func (p Policy) Apply(order *models.Order, role Role) *models.Order {
+	rules, ok := p[role]
+	if !ok {
+		rules = p[RolePublic]
+	}
+
+	masked := *order
+	for name, rule := range rules {
+		field := fields[name](&masked)
+		*field = applyRule(rule, name, *field)
+	}
+	return &masked
+}
+
+func applyRule(rule Rule, name, value string) string {
+	if value == "" {
+		return value
+	}
+
+	switch rule {
+	case RuleMask:
+		if name == "delivery.email" {
+			return maskEmail(value)
+		}
+		return mask(value, 2)
+	case RuleTruncate:
+		return truncate(value)
+	case RuleRedact:
+		return ""
+	default:
+		return value
+	}
+}
+
+// mask replaces every letter and digit except the last keep with '*'.
+func mask(value string, keep int) string {
+	runes := []rune(value)
+	for i := 0; i < len(runes)-keep; i++ {
+		if unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) {
+			runes[i] = '*'
+		}
+	}
+	return string(runes)
+}
+
+// maskEmail keeps the first character of the local part and the domain.
+func maskEmail(value string) string {
+	at := strings.LastIndex(value, "@")
+	if at <= 0 {
+		return mask(value, 0)
+	}
+	local := []rune(value[:at])
+	return string(local[0]) + "***" + value[at:]
+}
+
+// truncate keeps the street of an address: the words up to the first one
+// with a digit after it, skipping house numbers written before it, so "15
+// Main St" becomes "Main St …". A value without numbers keeps the first half
+// of its words, or of its characters if it is a single word.
+func truncate(value string) string {
+	words := strings.Fields(value)
+	hasDigit := func(word string) bool { return strings.IndexFunc(word, unicode.IsDigit) >= 0 }
+
+	start := 0
+	for start < len(words) && hasDigit(words[start]) {
+		start++
+	}
+	end := start
+	for end < len(words) && !hasDigit(words[end]) {
+		end++
+	}
+
+	switch {
+	case start == len(words):
+		return "…"
+	case start == 0 && end == len(words) && len(words) == 1:
+		runes := []rune(words[0])
+		return string(runes[:(len(runes)+1)/2]) + "…"
+	case start == 0 && end == len(words):
+		end = (len(words) + 1) / 2
+	}
+	return strings.TrimRight(strings.Join(words[start:end], " "), ",;") + " …"
+}
+
+type roleKey struct{}
+
+// WithRole returns a context carrying the caller's role.
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromContext returns the caller's role, if one was set.
+func RoleFromContext(ctx context.Context) (Role, bool) {
+	role, ok := ctx.Value(roleKey{}).(Role)
+	return role, ok
+}
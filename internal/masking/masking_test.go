@@ -0,0 +1,148 @@
+package masking
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"order-service/internal/models"
+)
+
+func testOrder() *models.Order {
+	return &models.Order{
+		OrderUID: "test123",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000012",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test",
+			Provider:    "wbpay",
+			Bank:        "alpha",
+		},
+	}
+}
+
+func TestApplySupportSeesEverything(t *testing.T) {
+	order := testOrder()
+	masked := DefaultPolicy().Apply(order, RoleSupport)
+
+	if masked.Delivery != order.Delivery {
+		t.Errorf("Expected delivery unchanged, got %+v", masked.Delivery)
+	}
+	if masked.Payment.Transaction != order.Payment.Transaction {
+		t.Errorf("Expected transaction unchanged, got %s", masked.Payment.Transaction)
+	}
+}
+
+func TestApplyAnalyst(t *testing.T) {
+	masked := DefaultPolicy().Apply(testOrder(), RoleAnalyst)
+
+	if masked.Delivery.Phone != "+********12" {
+		t.Errorf("Expected masked phone, got %s", masked.Delivery.Phone)
+	}
+	if masked.Delivery.Email != "t***@gmail.com" {
+		t.Errorf("Expected masked email, got %s", masked.Delivery.Email)
+	}
+	if masked.Delivery.Address != "Ploshad Mira …" {
+		t.Errorf("Expected truncated address, got %s", masked.Delivery.Address)
+	}
+	if masked.Delivery.Name != "Test Testov" {
+		t.Errorf("Expected name unchanged, got %s", masked.Delivery.Name)
+	}
+}
+
+func TestTruncateAddress(t *testing.T) {
+	cases := map[string]string{
+		"Ploshad Mira 15":         "Ploshad Mira …",
+		"15 Main St":              "Main St …",
+		"15 Main St, Apt 4":       "Main St, Apt …",
+		"ул. Ленина, д. 5, кв. 3": "ул. Ленина, д. …",
+		"Baker Street":            "Baker …",
+		"Nevsky":                  "Nev…",
+		"221B":                    "…",
+	}
+	for address, want := range cases {
+		if got := truncate(address); got != want {
+			t.Errorf("truncate(%q) = %q, expected %q", address, got, want)
+		}
+	}
+}
+
+func TestApplyPublicHasNoPII(t *testing.T) {
+	masked := DefaultPolicy().Apply(testOrder(), RolePublic)
+
+	if masked.Delivery != (models.Delivery{}) {
+		t.Errorf("Expected empty delivery, got %+v", masked.Delivery)
+	}
+	if masked.Payment.Transaction != "" {
+		t.Errorf("Expected transaction redacted, got %s", masked.Payment.Transaction)
+	}
+	if masked.OrderUID != "test123" {
+		t.Errorf("Expected OrderUID preserved, got %s", masked.OrderUID)
+	}
+}
+
+func TestApplyUnknownRoleFallsBackToPublic(t *testing.T) {
+	masked := DefaultPolicy().Apply(testOrder(), Role("intern"))
+	if masked.Delivery.Name != "" {
+		t.Errorf("Expected name redacted for unknown role, got %s", masked.Delivery.Name)
+	}
+}
+
+func TestApplyDoesNotModifyOriginal(t *testing.T) {
+	order := testOrder()
+	DefaultPolicy().Apply(order, RolePublic)
+	if order.Delivery.Name != "Test Testov" {
+		t.Error("Apply modified the original order")
+	}
+}
+
+func TestLoadPolicyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	data := `{"analyst": {"delivery.name": "redact"}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := LoadPolicyFile(path)
+	if err != nil {
+		t.Fatalf("LoadPolicyFile failed: %v", err)
+	}
+
+	masked := policy.Apply(testOrder(), RoleAnalyst)
+	if masked.Delivery.Name != "" {
+		t.Errorf("Expected name redacted, got %s", masked.Delivery.Name)
+	}
+	if masked.Delivery.Email != "t***@gmail.com" {
+		t.Errorf("Expected default analyst rules kept, got %s", masked.Delivery.Email)
+	}
+}
+
+func TestLoadPolicyFileRejectsUnknownField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"analyst": {"delivery.ssn": "mask"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadPolicyFile(path); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+}
+
+func TestRoleContext(t *testing.T) {
+	if _, ok := RoleFromContext(context.Background()); ok {
+		t.Error("Expected no role in empty context")
+	}
+
+	ctx := WithRole(context.Background(), RoleAnalyst)
+	if role, ok := RoleFromContext(ctx); !ok || role != RoleAnalyst {
+		t.Errorf("Expected analyst role, got %s", role)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DeadLetter is a message that failed decoding or validation, kept with
+// the reason so it can be inspected and replayed.
+type DeadLetter struct {
+	ID         int64      `json:"id" db:"id"`
+	Subject    string     `json:"subject" db:"subject"`
+	Sequence   uint64     `json:"sequence" db:"sequence"`
+	Data       []byte     `json:"data" db:"data"`
+	Error      string     `json:"error" db:"error"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ReplayedAt *time.Time `json:"replayed_at,omitempty" db:"replayed_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// OrderFilter selects orders for listings and exports. Zero fields match
+// everything; From is inclusive and To exclusive on date_created.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Entry           string
+	Currency        string
+	From            time.Time
+	To              time.Time
+}
+
+// Match reports whether order satisfies the filter.
+func (f OrderFilter) Match(order *Order) bool {
+	if f.CustomerID != "" && order.CustomerID != f.CustomerID {
+		return false
+	}
+	if f.DeliveryService != "" && order.DeliveryService != f.DeliveryService {
+		return false
+	}
+	if f.Entry != "" && order.Entry != f.Entry {
+		return false
+	}
+	if f.Currency != "" && !strings.EqualFold(order.Payment.Currency, f.Currency) {
+		return false
+	}
+	if !f.From.IsZero() && order.DateCreated.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !order.DateCreated.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+// IsZero reports whether the filter matches every order.
+func (f OrderFilter) IsZero() bool {
+	return f == OrderFilter{}
+}
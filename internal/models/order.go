@@ -1,13 +1,24 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"order-service/internal/money"
+)
+
+// ErrAlreadyStored is returned by the repository for an order whose UID is
+// already stored. The stored order is left as it was, so the rejected copy
+// must not be cached or published either.
+var ErrAlreadyStored = errors.New("order already stored")
 
 type Order struct {
-	OrderUID          string    `json:"order_uid" db:"order_uid"`
-	TrackNumber       string    `json:"track_number" db:"track_number"`
-	Entry             string    `json:"entry" db:"entry"`
+	OrderUID          string    `json:"order_uid" db:"order_uid" schema:"required"`
+	TrackNumber       string    `json:"track_number" db:"track_number" schema:"required"`
+	Entry             string    `json:"entry" db:"entry" schema:"required"`
 	Delivery          Delivery  `json:"delivery"`
-	Payment           Payment   `json:"payment"`
+	Payment           Payment   `json:"payment" schema:"required"`
 	Items             []Item    `json:"items"`
 	Locale            string    `json:"locale" db:"locale"`
 	InternalSignature string    `json:"internal_signature" db:"internal_signature"`
@@ -31,33 +42,69 @@ type Delivery struct {
 	Email   string `json:"email" db:"email"`
 }
 
+// Payment amounts (and item prices) are expressed in the minor units of
+// Payment.Currency, as defined by ISO 4217. They are encoded as bare
+// integers; Denominate gives them the payment currency after decoding.
 type Payment struct {
-	ID           int64  `json:"-" db:"id"`
-	OrderID      string `json:"-" db:"order_uid"`
-	Transaction  string `json:"transaction" db:"transaction"`
-	RequestID    string `json:"request_id" db:"request_id"`
-	Currency     string `json:"currency" db:"currency"`
-	Provider     string `json:"provider" db:"provider"`
-	Amount       int    `json:"amount" db:"amount"`
-	PaymentDt    int64  `json:"payment_dt" db:"payment_dt"`
-	Bank         string `json:"bank" db:"bank"`
-	DeliveryCost int    `json:"delivery_cost" db:"delivery_cost"`
-	GoodsTotal   int    `json:"goods_total" db:"goods_total"`
-	CustomFee    int    `json:"custom_fee" db:"custom_fee"`
+	ID           int64       `json:"-" db:"id"`
+	OrderID      string      `json:"-" db:"order_uid"`
+	Transaction  string      `json:"transaction" db:"transaction"`
+	RequestID    string      `json:"request_id" db:"request_id"`
+	Currency     string      `json:"currency" db:"currency" schema:"required"`
+	Provider     string      `json:"provider" db:"provider"`
+	Amount       money.Money `json:"amount" db:"amount" schema:"min=0"`
+	PaymentDt    int64       `json:"payment_dt" db:"payment_dt"`
+	Bank         string      `json:"bank" db:"bank"`
+	DeliveryCost money.Money `json:"delivery_cost" db:"delivery_cost" schema:"min=0"`
+	GoodsTotal   money.Money `json:"goods_total" db:"goods_total" schema:"min=0"`
+	CustomFee    money.Money `json:"custom_fee" db:"custom_fee" schema:"min=0"`
 }
 
 type Item struct {
-	ID          int64  `json:"-" db:"id"`
-	OrderID     string `json:"-" db:"order_uid"`
-	ChrtID      int    `json:"chrt_id" db:"chrt_id"`
-	TrackNumber string `json:"track_number" db:"track_number"`
-	Price       int    `json:"price" db:"price"`
-	Rid         string `json:"rid" db:"rid"`
-	Name        string `json:"name" db:"name"`
-	Sale        int    `json:"sale" db:"sale"`
-	Size        string `json:"size" db:"size"`
-	TotalPrice  int    `json:"total_price" db:"total_price"`
-	NmID        int    `json:"nm_id" db:"nm_id"`
-	Brand       string `json:"brand" db:"brand"`
-	Status      int    `json:"status" db:"status"`
+	ID          int64       `json:"-" db:"id"`
+	OrderID     string      `json:"-" db:"order_uid"`
+	ChrtID      int         `json:"chrt_id" db:"chrt_id"`
+	TrackNumber string      `json:"track_number" db:"track_number"`
+	Price       money.Money `json:"price" db:"price" schema:"min=0"`
+	Rid         string      `json:"rid" db:"rid"`
+	Name        string      `json:"name" db:"name"`
+	Sale        int         `json:"sale" db:"sale"`
+	Size        string      `json:"size" db:"size"`
+	TotalPrice  money.Money `json:"total_price" db:"total_price" schema:"min=0"`
+	NmID        int         `json:"nm_id" db:"nm_id"`
+	Brand       string      `json:"brand" db:"brand"`
+	Status      int         `json:"status" db:"status"`
+}
+
+// Denominate sets the currency of every amount of the order to the payment
+// currency. It is called wherever an order is decoded, since amounts are
+// stored and sent without their currency.
+func (o *Order) Denominate() {
+	p := &o.Payment
+	for _, m := range []*money.Money{&p.Amount, &p.DeliveryCost, &p.GoodsTotal, &p.CustomFee} {
+		*m = money.New(m.Amount, p.Currency)
+	}
+	for i := range o.Items {
+		item := &o.Items[i]
+		item.Price = money.New(item.Price.Amount, p.Currency)
+		item.TotalPrice = money.New(item.TotalPrice.Amount, p.Currency)
+	}
+}
+
+// UnmarshalJSON decodes an order and denominates its amounts.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plain Order
+	if err := json.Unmarshal(data, (*plain)(o)); err != nil {
+		return err
+	}
+	o.Denominate()
+	return nil
+}
+
+// PaidAt returns payment_dt as a time, falling back to fallback when unset.
+func (p Payment) PaidAt(fallback time.Time) time.Time {
+	if p.PaymentDt == 0 {
+		return fallback
+	}
+	return time.Unix(p.PaymentDt, 0).UTC()
 }
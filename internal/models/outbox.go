@@ -0,0 +1,18 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is a change to an order recorded in the same transaction as
+// the change itself and published downstream by the outbox relay.
+type OutboxEvent struct {
+	ID        int64           `db:"id"`
+	OrderUID  string          `db:"order_uid"`
+	Type      string          `db:"event_type"`
+	Headers   json.RawMessage `db:"headers"`
+	Payload   json.RawMessage `db:"payload"`
+	CreatedAt time.Time       `db:"created_at"`
+	Attempts  int             `db:"attempts"`
+}
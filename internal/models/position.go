@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// ConsumerPosition is how far the subscriber has processed a channel: every
+// message up to and including Sequence has been handled.
+type ConsumerPosition struct {
+	Subject   string    `json:"subject" db:"subject"`
+	Sequence  uint64    `json:"sequence" db:"sequence"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PositionFunc returns the position to store with a transaction that
+// writes orders, which is empty for a transaction without orders.
+type PositionFunc func(orders []*Order) ConsumerPosition
+
+type positionKey struct{}
+
+// WithPosition returns a context whose writes also store the position
+// returned by fn.
+func WithPosition(ctx context.Context, fn PositionFunc) context.Context {
+	return context.WithValue(ctx, positionKey{}, fn)
+}
+
+// PositionFromContext returns the function stored by WithPosition.
+func PositionFromContext(ctx context.Context) (PositionFunc, bool) {
+	fn, ok := ctx.Value(positionKey{}).(PositionFunc)
+	return fn, ok
+}
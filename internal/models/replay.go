@@ -0,0 +1,18 @@
+package models
+
+import "context"
+
+type replayKey struct{}
+
+// WithReplay returns a context whose writes restore orders that were
+// announced before, as a rebuild from the NATS channel does. Such orders
+// are stored without outbox events or webhook deliveries.
+func WithReplay(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replayKey{}, true)
+}
+
+// IsReplay reports whether ctx was returned by WithReplay.
+func IsReplay(ctx context.Context) bool {
+	replay, _ := ctx.Value(replayKey{}).(bool)
+	return replay
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"order-service/internal/money"
+)
+
+// Validate checks that an incoming order is complete enough to be stored.
+func (o *Order) Validate() error {
+	if o.OrderUID == "" {
+		return errors.New("order_uid is required")
+	}
+	if o.TrackNumber == "" {
+		return errors.New("track_number is required")
+	}
+	if o.Entry == "" {
+		return errors.New("entry is required")
+	}
+
+	p := o.Payment
+	if err := validateMoney(p.Amount, p.Currency); err != nil {
+		return fmt.Errorf("payment.amount: %w", err)
+	}
+	if err := validateMoney(p.DeliveryCost, p.Currency); err != nil {
+		return fmt.Errorf("payment.delivery_cost: %w", err)
+	}
+	if err := validateMoney(p.GoodsTotal, p.Currency); err != nil {
+		return fmt.Errorf("payment.goods_total: %w", err)
+	}
+	if err := validateMoney(p.CustomFee, p.Currency); err != nil {
+		return fmt.Errorf("payment.custom_fee: %w", err)
+	}
+
+	for i, item := range o.Items {
+		if err := validateMoney(item.Price, p.Currency); err != nil {
+			return fmt.Errorf("items[%d].price: %w", i, err)
+		}
+		if err := validateMoney(item.TotalPrice, p.Currency); err != nil {
+			return fmt.Errorf("items[%d].total_price: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// validateMoney checks m and that it is denominated in the payment currency.
+func validateMoney(m money.Money, currency string) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if m.Currency != strings.ToUpper(currency) {
+		return fmt.Errorf("currency %q differs from payment currency %q", m.Currency, currency)
+	}
+	return nil
+}
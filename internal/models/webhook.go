@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Order events delivered to webhook subscribers.
+const (
+	EventOrderCreated   = "order.created"
+	EventOrderUpdated   = "order.updated"
+	EventOrderCancelled = "order.cancelled"
+)
+
+// WebhookEvents lists the event types a subscription can filter on.
+var WebhookEvents = []string{EventOrderCreated, EventOrderUpdated, EventOrderCancelled}
+
+// Webhook delivery states. Pending deliveries are retried until they
+// succeed or run out of attempts and become dead.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription registers a URL for order events. Empty
+// DeliveryService and Entry match every order. Secret signs the payloads
+// and is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID              int64     `json:"id" db:"id"`
+	URL             string    `json:"url" db:"url"`
+	Secret          string    `json:"secret,omitempty" db:"secret"`
+	EventTypes      []string  `json:"event_types" db:"-"`
+	DeliveryService string    `json:"delivery_service,omitempty" db:"delivery_service"`
+	Entry           string    `json:"entry,omitempty" db:"entry"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// WebhookDelivery is one event to be sent to one subscription, along with
+// the outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             int64           `json:"id" db:"id"`
+	SubscriptionID int64           `json:"subscription_id" db:"subscription_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	OrderUID       string          `json:"order_uid" db:"order_uid"`
+	Payload        json.RawMessage `json:"-" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+
+	// Target of the delivery, read from the subscription.
+	URL    string `json:"-" db:"url"`
+	Secret string `json:"-" db:"secret"`
+}
@@ -0,0 +1,45 @@
+package money
+
+import "strings"
+
+// exponents maps ISO 4217 alphabetic codes to the number of digits after the
+// decimal separator (the "minor unit" exponent). migrations/002_money_bigint.sql
+// carries a copy for converting stored amounts.
+var exponents = map[string]int{
+	// Zero-decimal currencies
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0,
+	"XOF": 0, "XPF": 0,
+
+	// Three-decimal currencies
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+
+	// Two-decimal currencies
+	"AED": 2, "AMD": 2, "ARS": 2, "AUD": 2, "AZN": 2, "BGN": 2, "BRL": 2,
+	"BYN": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2, "EGP": 2,
+	"EUR": 2, "GBP": 2, "GEL": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2,
+	"INR": 2, "KGS": 2, "KZT": 2, "MDL": 2, "MXN": 2, "MYR": 2, "NOK": 2,
+	"NZD": 2, "PHP": 2, "PLN": 2, "RON": 2, "RSD": 2, "RUB": 2, "SAR": 2,
+	"SEK": 2, "SGD": 2, "THB": 2, "TJS": 2, "TMT": 2, "TRY": 2, "TWD": 2,
+	"UAH": 2, "USD": 2, "UZS": 2, "ZAR": 2,
+}
+
+// Exponent returns the minor unit exponent for an ISO 4217 currency code.
+func Exponent(code string) (int, bool) {
+	exp, ok := exponents[strings.ToUpper(code)]
+	return exp, ok
+}
+
+// IsKnown reports whether code is a supported ISO 4217 currency.
+func IsKnown(code string) bool {
+	_, ok := Exponent(code)
+	return ok
+}
+
+func pow10(exp int) int64 {
+	p := int64(1)
+	for i := 0; i < exp; i++ {
+		p *= 10
+	}
+	return p
+}
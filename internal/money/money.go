@@ -0,0 +1,116 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Amount is a monetary quantity expressed in the minor units of its currency
+// (cents for USD, kopecks for RUB, yen for JPY). On the wire and in the
+// database it is a plain integer.
+type Amount int64
+
+// Money pairs an Amount with the ISO 4217 currency it is denominated in.
+//
+// Inside an order the currency is recorded once, in payment.currency, so
+// Money is encoded in JSON and stored in the database as its bare amount.
+// Whoever decodes it sets Currency from the enclosing record.
+type Money struct {
+	Amount   Amount `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// New returns Money for an amount already expressed in minor units.
+func New(amount Amount, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// FromMajor converts a whole number of major units (dollars, roubles) into
+// Money of the given currency.
+func FromMajor(major int64, currency string) (Money, error) {
+	exp, ok := Exponent(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("unknown currency %q", currency)
+	}
+	return New(Amount(major*pow10(exp)), currency), nil
+}
+
+// Validate checks that the currency is known and the amount is not negative.
+func (m Money) Validate() error {
+	if !IsKnown(m.Currency) {
+		return fmt.Errorf("unknown currency %q", m.Currency)
+	}
+	if m.Amount < 0 {
+		return fmt.Errorf("negative amount %d", m.Amount)
+	}
+	return nil
+}
+
+// MarshalJSON encodes m as its amount in minor units.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Amount)
+}
+
+// UnmarshalJSON decodes an amount in minor units, leaving Currency as it was.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &m.Amount)
+}
+
+// Value stores m as its amount in minor units.
+func (m Money) Value() (driver.Value, error) {
+	return int64(m.Amount), nil
+}
+
+// Scan reads an amount in minor units, leaving Currency as it was.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		m.Amount = Amount(v)
+	case nil:
+		m.Amount = 0
+	default:
+		return fmt.Errorf("cannot scan %T into money", src)
+	}
+	return nil
+}
+
+// Rat returns the amount in major units as an exact rational number.
+func (m Money) Rat() *big.Rat {
+	exp, _ := Exponent(m.Currency)
+	return new(big.Rat).SetFrac64(int64(m.Amount), pow10(exp))
+}
+
+// String formats the amount in major units, e.g. "18.17 USD".
+func (m Money) String() string {
+	exp, ok := Exponent(m.Currency)
+	if !ok {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	return m.Rat().FloatString(exp) + " " + m.Currency
+}
+
+// fromRat rounds a major-unit rational to the nearest minor unit of currency,
+// with halves rounded away from zero.
+func fromRat(r *big.Rat, currency string) Money {
+	exp, _ := Exponent(currency)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt64(pow10(exp)))
+
+	num := new(big.Int).Set(scaled.Num())
+	den := scaled.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	// Round half away from zero
+	rem.Abs(rem).Mul(rem, big.NewInt(2))
+	if rem.Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	return New(Amount(quo.Int64()), currency)
+}
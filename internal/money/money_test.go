@@ -0,0 +1,213 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExponent(t *testing.T) {
+	cases := map[string]int{"USD": 2, "rub": 2, "JPY": 0, "KWD": 3}
+	for code, want := range cases {
+		got, ok := Exponent(code)
+		if !ok {
+			t.Errorf("Expected %s to be known", code)
+		}
+		if got != want {
+			t.Errorf("Expected exponent %d for %s, got %d", want, code, got)
+		}
+	}
+
+	if IsKnown("XYZ") {
+		t.Error("Expected XYZ to be unknown")
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	cases := []struct {
+		m    Money
+		want string
+	}{
+		{New(1817, "USD"), "18.17 USD"},
+		{New(5, "eur"), "0.05 EUR"},
+		{New(1500, "JPY"), "1500 JPY"},
+		{New(1234, "KWD"), "1.234 KWD"},
+	}
+	for _, c := range cases {
+		if got := c.m.String(); got != c.want {
+			t.Errorf("Expected %q, got %q", c.want, got)
+		}
+	}
+}
+
+func TestFromMajor(t *testing.T) {
+	m, err := FromMajor(453, "RUB")
+	if err != nil {
+		t.Fatalf("FromMajor failed: %v", err)
+	}
+	if m.Amount != 45300 {
+		t.Errorf("Expected 45300, got %d", m.Amount)
+	}
+
+	if _, err := FromMajor(1, "XYZ"); err == nil {
+		t.Error("Expected error for unknown currency")
+	}
+}
+
+func TestMoneyValidate(t *testing.T) {
+	if err := New(100, "USD").Validate(); err != nil {
+		t.Errorf("Expected valid money, got %v", err)
+	}
+	if err := New(100, "").Validate(); err == nil {
+		t.Error("Expected error for empty currency")
+	}
+	if err := New(-1, "USD").Validate(); err == nil {
+		t.Error("Expected error for negative amount")
+	}
+}
+
+func TestConvertEffectiveDates(t *testing.T) {
+	table := NewRateTable()
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	err := table.Add(
+		Rate{From: "EUR", To: "USD", Rate: "1.10", EffectiveFrom: jan},
+		Rate{From: "EUR", To: "USD", Rate: "1.20", EffectiveFrom: feb},
+	)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	got, err := table.Convert(New(1000, "EUR"), "USD", jan.AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if got.Amount != 1100 || got.Currency != "USD" {
+		t.Errorf("Expected 1100 USD, got %v", got)
+	}
+
+	got, err = table.Convert(New(1000, "EUR"), "USD", feb.AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if got.Amount != 1200 {
+		t.Errorf("Expected 1200, got %d", got.Amount)
+	}
+
+	if _, err := table.Convert(New(1000, "EUR"), "USD", jan.AddDate(0, 0, -1)); !errors.Is(err, ErrNoRate) {
+		t.Errorf("Expected ErrNoRate before first effective date, got %v", err)
+	}
+}
+
+func TestConvertInverseAndExponents(t *testing.T) {
+	table := NewRateTable()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := table.Add(Rate{From: "USD", To: "JPY", Rate: "150", EffectiveFrom: at}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	// 12.34 USD -> 1851 JPY (1851.0)
+	got, err := table.Convert(New(1234, "USD"), "JPY", at)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if got.Amount != 1851 {
+		t.Errorf("Expected 1851 JPY, got %d", got.Amount)
+	}
+
+	// 1000 JPY -> 6.666... USD, rounded to 667 cents
+	got, err = table.Convert(New(1000, "JPY"), "USD", at)
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if got.Amount != 667 {
+		t.Errorf("Expected 667 cents, got %d", got.Amount)
+	}
+}
+
+func TestAddRejectsInvalidRates(t *testing.T) {
+	table := NewRateTable()
+	err := table.Add(
+		Rate{From: "EUR", To: "USD", Rate: "1.1"},
+		Rate{From: "EUR", To: "USD", Rate: "abc"},
+	)
+	if err == nil {
+		t.Fatal("Expected error for invalid rate")
+	}
+	if len(table.All()) != 0 {
+		t.Error("Expected no rates to be added when one is invalid")
+	}
+}
+
+func TestLoadRatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	data := `[{"from":"eur","to":"usd","rate":"1.08","effective_from":"2021-01-01T00:00:00Z"}]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := LoadRatesFile(path)
+	if err != nil {
+		t.Fatalf("LoadRatesFile failed: %v", err)
+	}
+
+	all := table.All()
+	if len(all) != 1 || all[0].From != "EUR" || all[0].To != "USD" {
+		t.Errorf("Unexpected rates: %+v", all)
+	}
+}
+
+func TestMoneyEncodesBareAmount(t *testing.T) {
+	data, err := json.Marshal(New(1817, "USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "1817" {
+		t.Errorf("Expected the bare amount, got %s", data)
+	}
+
+	m := New(0, "EUR")
+	if err := json.Unmarshal([]byte("450"), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m != New(450, "EUR") {
+		t.Errorf("Expected the currency to be kept, got %+v", m)
+	}
+
+	if err := m.Scan(int64(99)); err != nil || m != New(99, "EUR") {
+		t.Errorf("Expected to scan 99 EUR, got %+v (%v)", m, err)
+	}
+	if v, err := m.Value(); err != nil || v != int64(99) {
+		t.Errorf("Expected to store 99, got %v (%v)", v, err)
+	}
+}
+
+// The data migration that moved stored amounts to minor units carries its
+// own copy of the exponent table.
+func TestMigrationFactorsMatchExponents(t *testing.T) {
+	data, err := os.ReadFile("../../migrations/002_money_bigint.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	factors := regexp.MustCompile(`\('([A-Z]{3})', (\d+)\)`).FindAllStringSubmatch(string(data), -1)
+	if len(factors) != len(exponents) {
+		t.Errorf("Expected %d currencies in the migration, got %d", len(exponents), len(factors))
+	}
+	for _, f := range factors {
+		exp, ok := Exponent(f[1])
+		if !ok {
+			t.Errorf("Migration scales unknown currency %s", f[1])
+			continue
+		}
+		if want := strconv.FormatInt(pow10(exp), 10); f[2] != want {
+			t.Errorf("Migration scales %s by %s, expected %s", f[1], f[2], want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoRate is returned when no exchange rate is effective for a currency pair
+// at the requested time.
+var ErrNoRate = errors.New("no exchange rate available")
+
+// Rate says that one major unit of From is worth Rate major units of To from
+// EffectiveFrom onwards, until superseded by a later rate for the same pair.
+type Rate struct {
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Rate          string    `json:"rate"`
+	EffectiveFrom time.Time `json:"effective_from"`
+
+	value *big.Rat
+}
+
+func (r *Rate) normalize() error {
+	r.From = strings.ToUpper(r.From)
+	r.To = strings.ToUpper(r.To)
+	if !IsKnown(r.From) {
+		return fmt.Errorf("unknown currency %q", r.From)
+	}
+	if !IsKnown(r.To) {
+		return fmt.Errorf("unknown currency %q", r.To)
+	}
+	v, ok := new(big.Rat).SetString(r.Rate)
+	if !ok || v.Sign() <= 0 {
+		return fmt.Errorf("invalid rate %q for %s/%s", r.Rate, r.From, r.To)
+	}
+	r.value = v
+	return nil
+}
+
+type pair struct{ from, to string }
+
+// RateTable holds exchange rates with effective dates. It is safe for
+// concurrent use.
+type RateTable struct {
+	mu      sync.RWMutex
+	rates   map[pair][]Rate // sorted by EffectiveFrom ascending
+	version uint64
+}
+
+func NewRateTable() *RateTable {
+	return &RateTable{rates: make(map[pair][]Rate)}
+}
+
+// LoadRatesFile reads a JSON array of rates from path into a new table.
+func LoadRatesFile(path string) (*RateTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var rates []Rate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse rates file: %w", err)
+	}
+
+	table := NewRateTable()
+	if err := table.Add(rates...); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Add inserts rates into the table. A rate with the same pair and effective
+// date as an existing one replaces it. Either all rates are added or none.
+func (t *RateTable) Add(rates ...Rate) error {
+	for i := range rates {
+		if err := rates[i].normalize(); err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, r := range rates {
+		key := pair{r.From, r.To}
+		list := t.rates[key]
+
+		replaced := false
+		for i := range list {
+			if list[i].EffectiveFrom.Equal(r.EffectiveFrom) {
+				list[i] = r
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			list = append(list, r)
+			sort.Slice(list, func(i, j int) bool {
+				return list[i].EffectiveFrom.Before(list[j].EffectiveFrom)
+			})
+		}
+		t.rates[key] = list
+	}
+	t.version++
+	return nil
+}
+
+// Version returns a counter that changes whenever rates are added.
+func (t *RateTable) Version() uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.version
+}
+
+// All returns every rate in the table ordered by pair and effective date.
+func (t *RateTable) All() []Rate {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	all := make([]Rate, 0)
+	for _, list := range t.rates {
+		all = append(all, list...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].From != all[j].From {
+			return all[i].From < all[j].From
+		}
+		if all[i].To != all[j].To {
+			return all[i].To < all[j].To
+		}
+		return all[i].EffectiveFrom.Before(all[j].EffectiveFrom)
+	})
+	return all
+}
+
+// lookup returns the rate for from→to effective at the given time.
+func (t *RateTable) lookup(from, to string, at time.Time) (*big.Rat, bool) {
+	list := t.rates[pair{from, to}]
+	for i := len(list) - 1; i >= 0; i-- {
+		if !list[i].EffectiveFrom.After(at) {
+			return list[i].value, true
+		}
+	}
+	return nil, false
+}
+
+// Convert converts m into currency to using the rate effective at the given
+// time. The inverse of a to→from rate is used when no direct rate exists.
+func (t *RateTable) Convert(m Money, to string, at time.Time) (Money, error) {
+	from := strings.ToUpper(m.Currency)
+	to = strings.ToUpper(to)
+	if !IsKnown(from) {
+		return Money{}, fmt.Errorf("unknown currency %q", from)
+	}
+	if !IsKnown(to) {
+		return Money{}, fmt.Errorf("unknown currency %q", to)
+	}
+	if from == to {
+		return New(m.Amount, to), nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if rate, ok := t.lookup(from, to, at); ok {
+		return fromRat(new(big.Rat).Mul(m.Rat(), rate), to), nil
+	}
+	if rate, ok := t.lookup(to, from, at); ok {
+		return fromRat(new(big.Rat).Quo(m.Rat(), rate), to), nil
+	}
+
+	return Money{}, fmt.Errorf("%w for %s/%s at %s", ErrNoRate, from, to, at.Format(time.RFC3339))
+}
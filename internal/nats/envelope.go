@@ -0,0 +1,233 @@
+package nats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"order-service/internal/grpc/orderpb"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+	"order-service/internal/schema"
+	"order-service/internal/tracing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// EventOrderCreated is the type of messages carrying a new order.
+const EventOrderCreated = "order.created"
+
+// Format is the wire format of an order message.
+type Format string
+
+// Supported wire formats.
+const (
+	FormatJSON     Format = "json"
+	FormatProtobuf Format = "protobuf"
+)
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	if f == FormatProtobuf {
+		return "application/x-protobuf"
+	}
+	return "application/json"
+}
+
+// ParseFormat parses a format name as accepted by the publisher's --format
+// flag.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatJSON, FormatProtobuf:
+		return Format(s), nil
+	}
+	return "", fmt.Errorf("unknown message format %q, expected json or protobuf", s)
+}
+
+// DetectFormat reports the format of a message. JSON messages are objects;
+// a protobuf OrderEnvelope can never start with '{', which would be a
+// deprecated group tag for field 15.
+func DetectFormat(data []byte) Format {
+	if trimmed := bytes.TrimLeft(data, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '{' {
+		return FormatJSON
+	}
+	return FormatProtobuf
+}
+
+// Envelope wraps an order published to NATS with its schema version, event
+// type, producer metadata and the trace context. Order holds the order
+// encoded in Format, so the metadata is available before the order is
+// decoded.
+type Envelope struct {
+	Format        Format
+	SchemaVersion int
+	Type          string
+	Producer      string
+	MessageID     string
+	Timestamp     time.Time
+	Headers       map[string]string
+	Order         []byte
+}
+
+// jsonEnvelope is the JSON wire form of Envelope. Bare order payloads
+// without an envelope are still accepted during the migration to
+// envelopes and are read as the current schema version.
+type jsonEnvelope struct {
+	SchemaVersion int               `json:"schema_version,omitempty"`
+	Type          string            `json:"type,omitempty"`
+	Producer      string            `json:"producer,omitempty"`
+	MessageID     string            `json:"message_id,omitempty"`
+	Timestamp     time.Time         `json:"timestamp,omitzero"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Order         json.RawMessage   `json:"order"`
+}
+
+// EncodeOrder wraps order in a current-version envelope of the given format
+// carrying the trace context of ctx.
+func EncodeOrder(ctx context.Context, order *models.Order, format Format, producer string) ([]byte, error) {
+	env := Envelope{
+		Format:        format,
+		SchemaVersion: schema.CurrentOrderVersion,
+		Type:          EventOrderCreated,
+		Producer:      producer,
+		MessageID:     logging.NewID(),
+		Timestamp:     time.Now().UTC(),
+		Headers:       tracing.Inject(ctx),
+	}
+
+	var err error
+	if format == FormatProtobuf {
+		env.Order, err = proto.Marshal(orderpb.FromModel(order))
+	} else {
+		env.Order, err = json.Marshal(order)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return env.Marshal()
+}
+
+// Marshal encodes the envelope in its format. Order must already be encoded
+// in the same format.
+func (e Envelope) Marshal() ([]byte, error) {
+	if e.Format == FormatProtobuf {
+		pb := &orderpb.OrderEnvelope{
+			Headers:       e.Headers,
+			Order:         e.Order,
+			SchemaVersion: int32(e.SchemaVersion),
+			Type:          e.Type,
+			Producer:      e.Producer,
+			MessageId:     e.MessageID,
+		}
+		if !e.Timestamp.IsZero() {
+			pb.Timestamp = timestamppb.New(e.Timestamp)
+		}
+		return proto.Marshal(pb)
+	}
+	return json.Marshal(jsonEnvelope{
+		SchemaVersion: e.SchemaVersion,
+		Type:          e.Type,
+		Producer:      e.Producer,
+		MessageID:     e.MessageID,
+		Timestamp:     e.Timestamp,
+		Headers:       e.Headers,
+		Order:         e.Order,
+	})
+}
+
+// ParseEnvelope detects the format of a message and splits it into headers
+// and the order payload. JSON data that is not an envelope is treated as a
+// bare order.
+func ParseEnvelope(data []byte) (Envelope, error) {
+	if DetectFormat(data) == FormatProtobuf {
+		var env orderpb.OrderEnvelope
+		if err := proto.Unmarshal(data, &env); err != nil {
+			return Envelope{}, fmt.Errorf("failed to parse protobuf envelope: %w", err)
+		}
+		out := Envelope{
+			Format:        FormatProtobuf,
+			SchemaVersion: int(env.GetSchemaVersion()),
+			Type:          env.GetType(),
+			Producer:      env.GetProducer(),
+			MessageID:     env.GetMessageId(),
+			Headers:       env.GetHeaders(),
+			Order:         env.GetOrder(),
+		}
+		if env.GetTimestamp() != nil {
+			out.Timestamp = env.GetTimestamp().AsTime()
+		}
+		return out, nil
+	}
+
+	var env jsonEnvelope
+	if err := json.Unmarshal(data, &env); err != nil || len(env.Order) == 0 || env.Order[0] != '{' {
+		return Envelope{Format: FormatJSON, Order: data}, nil
+	}
+	return Envelope{
+		Format:        FormatJSON,
+		SchemaVersion: env.SchemaVersion,
+		Type:          env.Type,
+		Producer:      env.Producer,
+		MessageID:     env.MessageID,
+		Timestamp:     env.Timestamp,
+		Headers:       env.Headers,
+		Order:         env.Order,
+	}, nil
+}
+
+// DecodeMessage parses a message and decodes its order the way the
+// subscriber does.
+func DecodeMessage(data []byte) (*models.Order, error) {
+	env, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	var order models.Order
+	if err := env.Decode(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Version returns the schema version of the order. Bare payloads and
+// envelopes without a version are read as the current version.
+func (e Envelope) Version() int {
+	if e.SchemaVersion == 0 {
+		return schema.CurrentOrderVersion
+	}
+	return e.SchemaVersion
+}
+
+// Decode decodes the order carried by the envelope. JSON orders are
+// validated against the JSON Schema of their version and upcast to the
+// current version; protobuf orders must be of the current version.
+func (e Envelope) Decode(order *models.Order) error {
+	if e.Type != "" && e.Type != EventOrderCreated {
+		return fmt.Errorf("unsupported message type %q", e.Type)
+	}
+	version := e.Version()
+
+	if e.Format == FormatProtobuf {
+		if version != schema.CurrentOrderVersion {
+			return fmt.Errorf("%w: %d in protobuf message", schema.ErrUnknownVersion, version)
+		}
+		var pb orderpb.Order
+		if err := proto.Unmarshal(e.Order, &pb); err != nil {
+			return err
+		}
+		*order = *orderpb.ToModel(&pb)
+		return nil
+	}
+
+	if err := schema.ValidateOrder(version, e.Order); err != nil {
+		return fmt.Errorf("order does not match schema v%d: %w", version, err)
+	}
+	payload, err := schema.UpcastOrder(version, e.Order)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, order)
+}
@@ -0,0 +1,198 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+	"order-service/internal/schema"
+)
+
+func sampleOrder() *models.Order {
+	order := &models.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       money.New(181700, "USD"),
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: money.New(150000, "USD"),
+			GoodsTotal:   money.New(31700, "USD"),
+		},
+	}
+	for i := 0; i < 4; i++ {
+		order.Items = append(order.Items, models.Item{
+			ChrtID:      9934930 + i,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       money.New(45300, "USD"),
+			Rid:         fmt.Sprintf("ab4219087a764ae0btest%d", i),
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  money.New(31700, "USD"),
+			NmID:        2389212 + i,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		})
+	}
+	order.Denominate()
+	return order
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatJSON, FormatProtobuf} {
+		t.Run(string(format), func(t *testing.T) {
+			want := sampleOrder()
+			data, err := EncodeOrder(context.Background(), want, format, "test-producer")
+			if err != nil {
+				t.Fatalf("EncodeOrder: %v", err)
+			}
+			if got := DetectFormat(data); got != format {
+				t.Fatalf("expected format %s to be detected, got %s", format, got)
+			}
+
+			env, err := ParseEnvelope(data)
+			if err != nil {
+				t.Fatalf("ParseEnvelope: %v", err)
+			}
+			if env.SchemaVersion != schema.CurrentOrderVersion || env.Type != EventOrderCreated ||
+				env.Producer != "test-producer" || env.MessageID == "" || env.Timestamp.IsZero() {
+				t.Errorf("expected envelope metadata to be set, got %+v", env)
+			}
+			var got models.Order
+			if err := env.Decode(&got); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(&got, want) {
+				t.Errorf("round trip changed the order:\n got %+v\nwant %+v", got, *want)
+			}
+		})
+	}
+}
+
+func TestParseEnvelopeBareOrder(t *testing.T) {
+	bare := []byte(`{"order_uid":"abc","track_number":"T1"}`)
+
+	env, err := ParseEnvelope(bare)
+	if err != nil {
+		t.Fatalf("ParseEnvelope: %v", err)
+	}
+	if env.Format != FormatJSON || env.Headers != nil {
+		t.Errorf("expected a JSON message without headers, got %+v", env)
+	}
+	if string(env.Order) != string(bare) {
+		t.Errorf("expected the bare payload back, got %s", env.Order)
+	}
+}
+
+func TestDecodeUpcastsV1(t *testing.T) {
+	env := Envelope{
+		Format:        FormatJSON,
+		SchemaVersion: schema.OrderV1,
+		Type:          EventOrderCreated,
+		Order: []byte(`{"order_uid":"abc","track_number":"T1","entry":"WBIL",
+			"payment":{"currency":"USD","amount":1817,"delivery_cost":1500,"goods_total":317,"custom_fee":0},
+			"items":[{"price":453,"total_price":317}]}`),
+	}
+	data, err := env.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseEnvelope(data)
+	if err != nil {
+		t.Fatalf("ParseEnvelope: %v", err)
+	}
+
+	var order models.Order
+	if err := parsed.Decode(&order); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if order.Payment.Amount != money.New(181700, "USD") || order.Payment.DeliveryCost != money.New(150000, "USD") ||
+		order.Items[0].Price != money.New(45300, "USD") {
+		t.Errorf("expected v1 amounts in minor units, got payment %+v items %+v", order.Payment, order.Items)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		env  Envelope
+	}{
+		{"schema violation", Envelope{Format: FormatJSON, Order: []byte(`{"order_uid":"abc","track_number":"T1","entry":"WBIL","payment":{"currency":"USD","amount":-1}}`)}},
+		{"missing required", Envelope{Format: FormatJSON, Order: []byte(`{"order_uid":"abc"}`)}},
+		{"unknown version", Envelope{Format: FormatJSON, SchemaVersion: 99, Order: []byte(`{}`)}},
+		{"unknown type", Envelope{Format: FormatJSON, Type: "order.deleted", Order: []byte(`{}`)}},
+		{"old protobuf version", Envelope{Format: FormatProtobuf, SchemaVersion: schema.OrderV1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order models.Order
+			if err := tt.env.Decode(&order); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseEnvelopeInvalidProtobuf(t *testing.T) {
+	if _, err := ParseEnvelope([]byte{0x0a, 0xff}); err == nil {
+		t.Error("expected an error for a truncated protobuf envelope")
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat("protobuf"); err != nil || f != FormatProtobuf {
+		t.Errorf("ParseFormat(protobuf) = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func benchmarkDecode(b *testing.B, format Format) {
+	data, err := EncodeOrder(context.Background(), sampleOrder(), format, "test-producer")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		env, err := ParseEnvelope(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var order models.Order
+		if err := env.Decode(&order); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeJSON(b *testing.B)     { benchmarkDecode(b, FormatJSON) }
+func BenchmarkDecodeProtobuf(b *testing.B) { benchmarkDecode(b, FormatProtobuf) }
@@ -0,0 +1,125 @@
+package nats
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// partitions runs work on a fixed set of goroutines. Items submitted with
+// the same key always land on the same goroutine, so they are handled one
+// batch at a time and in submission order, while different keys proceed in
+// parallel.
+type partitions[T any] struct {
+	queues   []chan T
+	handle   func(batch []T)
+	maxBatch int
+	linger   time.Duration
+	wg       sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newPartitions starts n workers, each with a queue of queueSize pending
+// items. A worker passes handle up to maxBatch items at once: after taking
+// an item it waits up to linger for more to arrive. With a zero linger it
+// only takes what is already queued.
+func newPartitions[T any](n, queueSize, maxBatch int, linger time.Duration, handle func(batch []T)) *partitions[T] {
+	if n < 1 {
+		n = 1
+	}
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	p := &partitions[T]{queues: make([]chan T, n), handle: handle, maxBatch: maxBatch, linger: linger}
+	for i := range p.queues {
+		p.queues[i] = make(chan T, queueSize)
+		p.wg.Add(1)
+		go p.work(p.queues[i])
+	}
+	return p
+}
+
+func (p *partitions[T]) work(queue chan T) {
+	defer p.wg.Done()
+
+	batch := make([]T, 0, p.maxBatch)
+	for item := range queue {
+		batch = append(batch[:0], item)
+		open := p.fill(queue, &batch)
+		p.handle(batch)
+		if !open {
+			return
+		}
+	}
+}
+
+// fill adds queued items to batch until it is full or the linger time has
+// passed. It reports false if the queue was closed.
+func (p *partitions[T]) fill(queue chan T, batch *[]T) bool {
+	var deadline <-chan time.Time
+	if p.linger > 0 && p.maxBatch > 1 {
+		timer := time.NewTimer(p.linger)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for len(*batch) < p.maxBatch {
+		if deadline == nil {
+			select {
+			case item, ok := <-queue:
+				if !ok {
+					return false
+				}
+				*batch = append(*batch, item)
+			default:
+				return true
+			}
+			continue
+		}
+		select {
+		case item, ok := <-queue:
+			if !ok {
+				return false
+			}
+			*batch = append(*batch, item)
+		case <-deadline:
+			return true
+		}
+	}
+	return true
+}
+
+// partition returns the index of the worker that handles key.
+func (p *partitions[T]) partition(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// Submit queues item on the partition of key, blocking while that
+// partition's queue is full. It reports false if the partitions have been
+// closed.
+func (p *partitions[T]) Submit(key string, item T) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+	p.queues[p.partition(key)] <- item
+	return true
+}
+
+// Close stops accepting work and waits for queued work to finish.
+func (p *partitions[T]) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, q := range p.queues {
+			close(q)
+		}
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,239 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// run is a partitions item that runs itself.
+type run func()
+
+func runAll(batch []run) {
+	for _, fn := range batch {
+		fn()
+	}
+}
+
+func TestPartitionsPreserveKeyOrder(t *testing.T) {
+	p := newPartitions(4, 8, 1, 0, runAll)
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("order-%d", i%10)
+		i := i
+		p.Submit(key, func() {
+			time.Sleep(time.Duration(i%3) * 10 * time.Microsecond)
+			mu.Lock()
+			seen[key] = append(seen[key], i)
+			mu.Unlock()
+		})
+	}
+	p.Close()
+
+	for key, order := range seen {
+		if len(order) != 50 {
+			t.Errorf("%s: expected 50 items, got %d", key, len(order))
+		}
+		for j := 1; j < len(order); j++ {
+			if order[j] < order[j-1] {
+				t.Fatalf("%s: items ran out of order: %v", key, order)
+			}
+		}
+	}
+}
+
+func TestPartitionsRunKeysInParallel(t *testing.T) {
+	p := newPartitions(2, 1, 1, 0, runAll)
+	defer p.Close()
+
+	// Find two keys that land on different workers.
+	other := ""
+	for i := 0; other == ""; i++ {
+		if key := fmt.Sprintf("order-%d", i); p.partition(key) != p.partition("blocked") {
+			other = key
+		}
+	}
+
+	release := make(chan struct{})
+	p.Submit("blocked", func() { <-release })
+	defer close(release)
+
+	done := make(chan struct{})
+	p.Submit(other, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("work for another order waited behind a blocked order")
+	}
+}
+
+func TestPartitionsCloseDrainsQueue(t *testing.T) {
+	p := newPartitions(2, 100, 1, 0, runAll)
+
+	var ran atomic.Int32
+	for i := 0; i < 100; i++ {
+		p.Submit(fmt.Sprint(i), func() {
+			time.Sleep(100 * time.Microsecond)
+			ran.Add(1)
+		})
+	}
+	p.Close()
+
+	if ran.Load() != 100 {
+		t.Errorf("expected Close to wait for all 100 items, %d ran", ran.Load())
+	}
+	if p.Submit("late", func() {}) {
+		t.Error("expected Submit to fail after Close")
+	}
+}
+
+func TestPartitionsBatchBySizeAndLinger(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]int
+	)
+	p := newPartitions(1, 100, 4, 50*time.Millisecond, func(batch []int) {
+		mu.Lock()
+		batches = append(batches, append([]int(nil), batch...))
+		mu.Unlock()
+	})
+
+	for i := 0; i < 6; i++ {
+		p.Submit("key", i)
+	}
+	// The second batch is handed over when the linger time expires, not
+	// when the queue is closed.
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	got := fmt.Sprint(batches)
+	mu.Unlock()
+	p.Close()
+
+	if want := "[[0 1 2 3] [4 5]]"; got != want {
+		t.Errorf("expected batches %s, got %s", want, got)
+	}
+}
+
+// slowRepo stands in for PostgreSQL, where each transaction costs a network
+// round trip and each row a little more.
+type slowRepo struct {
+	latency time.Duration
+	perRow  time.Duration
+	fail    func(orders []*models.Order) bool
+
+	mu    sync.Mutex
+	saved []string
+}
+
+func (r *slowRepo) SaveOrder(_ context.Context, order *models.Order) error {
+	_, err := r.SaveOrders(context.Background(), []*models.Order{order})
+	return err
+}
+
+func (r *slowRepo) SaveOrders(_ context.Context, orders []*models.Order) ([]bool, error) {
+	time.Sleep(r.latency + time.Duration(len(orders))*r.perRow)
+	if r.fail != nil && r.fail(orders) {
+		return nil, errors.New("constraint violation")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	written := make([]bool, len(orders))
+	for i, o := range orders {
+		r.saved = append(r.saved, o.OrderUID)
+		written[i] = true
+	}
+	return written, nil
+}
+
+func TestProcessBatchFallsBackToSingleWrites(t *testing.T) {
+	repo := &slowRepo{fail: func(orders []*models.Order) bool {
+		for _, o := range orders {
+			if o.OrderUID == "bad" {
+				return true
+			}
+		}
+		return false
+	}}
+	pipeline := NewPipeline(repo, discardCache{})
+
+	orders := make([]*models.Order, 4)
+	for i, uid := range []string{"a", "bad", "b", "invalid"} {
+		orders[i] = sampleOrder()
+		orders[i].OrderUID = uid
+	}
+	orders[3].TrackNumber = ""
+
+	errs := pipeline.ProcessBatch(context.Background(), orders)
+
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("expected good orders to be stored, got %v", errs)
+	}
+	if errs[1] == nil || errors.Is(errs[1], ErrInvalidOrder) {
+		t.Errorf("expected a save error for the bad order, got %v", errs[1])
+	}
+	if !errors.Is(errs[3], ErrInvalidOrder) {
+		t.Errorf("expected a validation error, got %v", errs[3])
+	}
+	if fmt.Sprint(repo.saved) != "[a b]" {
+		t.Errorf("expected a and b to be saved in order, got %v", repo.saved)
+	}
+}
+
+// benchmarkIngest measures the delivery path of the subscriber: messages are
+// decoded on one goroutine, as STAN delivers them, and stored by workers.
+func benchmarkIngest(b *testing.B, workers, batchSize int) {
+	repo := &slowRepo{latency: 500 * time.Microsecond, perRow: 5 * time.Microsecond}
+	pipeline := NewPipeline(repo, discardCache{})
+
+	messages := make([][]byte, 64)
+	for i := range messages {
+		order := sampleOrder()
+		order.OrderUID = fmt.Sprintf("order-%d", i)
+		data, err := EncodeOrder(context.Background(), order, FormatJSON, "bench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		messages[i] = data
+	}
+
+	p := newPartitions(workers, 256, batchSize, time.Millisecond, func(batch []*models.Order) {
+		for _, err := range pipeline.ProcessBatch(context.Background(), batch) {
+			if err != nil {
+				b.Error(err)
+			}
+		}
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		env, err := ParseEnvelope(messages[i%len(messages)])
+		if err != nil {
+			b.Fatal(err)
+		}
+		order := new(models.Order)
+		if err := env.Decode(order); err != nil {
+			b.Fatal(err)
+		}
+		p.Submit(order.OrderUID, order)
+	}
+	p.Close()
+}
+
+func BenchmarkIngest(b *testing.B) {
+	for _, workers := range []int{1, 4, 16} {
+		for _, batchSize := range []int{1, 32} {
+			b.Run(fmt.Sprintf("workers=%d/batch=%d", workers, batchSize), func(b *testing.B) {
+				benchmarkIngest(b, workers, batchSize)
+			})
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidOrder is returned by Pipeline.Process for orders that fail
+// validation. Such orders are never stored.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// BatchOrderHandler stores several orders in one transaction. Either all
+// of them are stored or none is; written reports for each order whether it
+// was stored by this call rather than before.
+type BatchOrderHandler interface {
+	SaveOrders(ctx context.Context, orders []*models.Order) (written []bool, err error)
+}
+
+// Pipeline validates incoming orders and stores them in the database and the
+// cache. It is shared by the NATS subscriber and other ingestion paths so
+// that every order is handled the same way.
+type Pipeline struct {
+	repo  OrderHandler
+	cache CacheHandler
+}
+
+func NewPipeline(repo OrderHandler, cache CacheHandler) *Pipeline {
+	return &Pipeline{repo: repo, cache: cache}
+}
+
+// Process validates order, fills in defaults and stores it. The order is
+// cached only after it has been saved to the database. An order whose UID
+// is already stored is accepted but neither saved nor cached, so the cache
+// keeps the stored copy.
+func (p *Pipeline) Process(ctx context.Context, order *models.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "process order", trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() { tracing.End(span, err) }()
+
+	if err := prepare(ctx, order); err != nil {
+		return err
+	}
+	written, err := p.save(ctx, order)
+	if err != nil {
+		return err
+	}
+	if written {
+		p.cacheOrder(ctx, order)
+	}
+	return nil
+}
+
+// ProcessBatch is like Process for several orders and returns the error of
+// each. Valid orders are written in one transaction if the repository
+// supports it; if that fails they are written one by one, so a single bad
+// order only fails itself.
+func (p *Pipeline) ProcessBatch(ctx context.Context, orders []*models.Order) []error {
+	ctx, span := tracer.Start(ctx, "process batch", trace.WithAttributes(attribute.Int("batch.size", len(orders))))
+	defer span.End()
+
+	errs := make([]error, len(orders))
+	var valid []*models.Order
+	for i, order := range orders {
+		if errs[i] = prepare(ctx, order); errs[i] == nil {
+			valid = append(valid, order)
+		}
+	}
+
+	batcher, ok := p.repo.(BatchOrderHandler)
+	if ok && len(valid) > 1 {
+		written, err := batcher.SaveOrders(ctx, valid)
+		if err == nil {
+			slog.DebugContext(ctx, "Orders saved to database", "count", len(valid))
+			for i, order := range valid {
+				if written[i] {
+					p.cacheOrder(ctx, order)
+				}
+			}
+			return errs
+		}
+		span.RecordError(err)
+		slog.WarnContext(ctx, "Batch write failed, saving orders one by one", "count", len(valid), "error", err)
+	}
+
+	for i, order := range orders {
+		if errs[i] != nil {
+			continue
+		}
+		var written bool
+		if written, errs[i] = p.save(ctx, order); written {
+			p.cacheOrder(ctx, order)
+		}
+	}
+	return errs
+}
+
+// Validate checks order as Process does before storing it and fills in
+// defaults. Validation errors wrap ErrInvalidOrder.
+func Validate(ctx context.Context, order *models.Order) error {
+	return prepare(ctx, order)
+}
+
+// prepare validates order and fills in defaults.
+func prepare(ctx context.Context, order *models.Order) error {
+	if err := validate(ctx, order); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOrder, err)
+	}
+
+	// Set current timestamp if not provided
+	if order.DateCreated.IsZero() {
+		order.DateCreated = time.Now()
+	}
+	return nil
+}
+
+// save stores order and reports whether it was written, rather than
+// stored before.
+func (p *Pipeline) save(ctx context.Context, order *models.Order) (bool, error) {
+	err := p.repo.SaveOrder(ctx, order)
+	if errors.Is(err, models.ErrAlreadyStored) {
+		slog.DebugContext(ctx, "Order already stored, keeping the stored copy")
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save order to DB: %w", err)
+	}
+	slog.DebugContext(ctx, "Order saved to database")
+	return true, nil
+}
+
+func (p *Pipeline) cacheOrder(ctx context.Context, order *models.Order) {
+	_, cacheSpan := tracer.Start(ctx, "cache set")
+	p.cache.Set(order.OrderUID, order)
+	cacheSpan.End()
+	slog.DebugContext(ctx, "Order cached")
+}
+
+func validate(ctx context.Context, order *models.Order) error {
+	_, span := tracer.Start(ctx, "validate order")
+	err := order.Validate()
+	tracing.End(span, err)
+	return err
+}
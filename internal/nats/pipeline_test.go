@@ -0,0 +1,93 @@
+package nats
+
+import (
+	"context"
+	"testing"
+
+	"order-service/internal/models"
+)
+
+// keyRepo stores the first order of each UID, like the order_keys table.
+type keyRepo struct {
+	orders map[string]*models.Order
+}
+
+func (r *keyRepo) SaveOrder(_ context.Context, order *models.Order) error {
+	if _, ok := r.orders[order.OrderUID]; ok {
+		return models.ErrAlreadyStored
+	}
+	r.orders[order.OrderUID] = order
+	return nil
+}
+
+func (r *keyRepo) SaveOrders(_ context.Context, orders []*models.Order) ([]bool, error) {
+	written := make([]bool, len(orders))
+	for i, order := range orders {
+		if _, ok := r.orders[order.OrderUID]; !ok {
+			r.orders[order.OrderUID] = order
+			written[i] = true
+		}
+	}
+	return written, nil
+}
+
+type mapCache map[string]*models.Order
+
+func (c mapCache) Set(uid string, order *models.Order) { c[uid] = order }
+
+func trackedOrder(uid, track string) *models.Order {
+	order := sampleOrder()
+	order.OrderUID = uid
+	order.TrackNumber = track
+	return order
+}
+
+// assertCacheMatches checks that the cache holds exactly the stored orders.
+func assertCacheMatches(t *testing.T, cache mapCache, repo *keyRepo) {
+	t.Helper()
+	if len(cache) != len(repo.orders) {
+		t.Fatalf("expected %d cached orders, got %d", len(repo.orders), len(cache))
+	}
+	for uid, stored := range repo.orders {
+		if got := cache[uid]; got != stored {
+			t.Errorf("order %s: cached track number %q, stored %q", uid, got.TrackNumber, stored.TrackNumber)
+		}
+	}
+}
+
+func TestProcessKeepsStoredOrder(t *testing.T) {
+	repo := &keyRepo{orders: make(map[string]*models.Order)}
+	cache := mapCache{}
+	pipeline := NewPipeline(repo, cache)
+
+	for _, track := range []string{"FIRST", "SECOND"} {
+		if err := pipeline.Process(context.Background(), trackedOrder("a", track)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := cache["a"].TrackNumber; got != "FIRST" {
+		t.Errorf("expected the stored copy to stay cached, got track number %q", got)
+	}
+	assertCacheMatches(t, cache, repo)
+}
+
+func TestProcessBatchKeepsStoredOrders(t *testing.T) {
+	repo := &keyRepo{orders: map[string]*models.Order{"a": trackedOrder("a", "STORED")}}
+	cache := mapCache{"a": repo.orders["a"]}
+	pipeline := NewPipeline(repo, cache)
+
+	orders := []*models.Order{
+		trackedOrder("a", "REDELIVERED"),
+		trackedOrder("b", "FIRST"),
+		trackedOrder("b", "SECOND"),
+	}
+	for _, err := range pipeline.ProcessBatch(context.Background(), orders) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := cache["b"].TrackNumber; got != "FIRST" {
+		t.Errorf("expected the first copy of b to be cached, got track number %q", got)
+	}
+	assertCacheMatches(t, cache, repo)
+}
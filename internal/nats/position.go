@@ -0,0 +1,115 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// StartPosition selects where the subscription starts reading the channel.
+type StartPosition string
+
+const (
+	// StartDurable continues from the position the server keeps for the
+	// durable subscription.
+	StartDurable StartPosition = "durable"
+	// StartStored continues after the position stored in the database,
+	// with a subscription the server keeps no position for.
+	StartStored StartPosition = "stored"
+)
+
+// ParseStartPosition parses the NATS_START_FROM setting.
+func ParseStartPosition(value string) (StartPosition, error) {
+	switch p := StartPosition(strings.ToLower(value)); p {
+	case StartDurable, StartStored:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown start position %q, expected durable or stored", value)
+}
+
+// PositionStore reads the consumer position that the repository stores
+// along with every write of the subscriber.
+type PositionStore interface {
+	ConsumerPosition(ctx context.Context, subject string) (uint64, error)
+}
+
+// positionTracker follows the messages being processed, so the position
+// stored with a transaction never passes a message that is still waiting
+// for its own transaction. Workers commit in any order, so the position is
+// just below the oldest message not handled yet.
+type positionTracker struct {
+	mu sync.Mutex
+	// pending holds the messages delivered and not handled yet,
+	// including those to be redelivered after a failure.
+	pending map[uint64]bool
+	// delivered is the highest sequence delivered.
+	delivered uint64
+	// handled is the sequence up to which every message is handled.
+	handled uint64
+}
+
+func newPositionTracker() *positionTracker {
+	return &positionTracker{pending: make(map[uint64]bool)}
+}
+
+// restore moves the tracker to a position read from the database.
+func (t *positionTracker) restore(seq uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handled = max(t.handled, seq)
+	t.delivered = max(t.delivered, seq)
+}
+
+// deliver records a delivered message. It returns false for a message at
+// or below the handled position, which must be skipped.
+func (t *positionTracker) deliver(seq uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if seq <= t.handled {
+		return false
+	}
+	t.pending[seq] = true
+	t.delivered = max(t.delivered, seq)
+	return true
+}
+
+// done records that a message is handled: its effects are committed.
+func (t *positionTracker) done(seq uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pending, seq)
+	t.handled = max(t.handled, t.position(nil))
+}
+
+// through returns the position to store with a transaction that commits
+// the messages seqs.
+func (t *positionTracker) through(seqs ...uint64) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return max(t.handled, t.position(seqs))
+}
+
+// position returns the sequence below the oldest pending message other than
+// seqs, or the highest delivered one if there is none. Callers must hold
+// t.mu. The scan is bounded by MaxInflight.
+func (t *positionTracker) position(seqs []uint64) uint64 {
+	oldest := uint64(0)
+	for seq := range t.pending {
+		if (oldest == 0 || seq < oldest) && !slices.Contains(seqs, seq) {
+			oldest = seq
+		}
+	}
+	if oldest == 0 {
+		return t.delivered
+	}
+	return oldest - 1
+}
+
+// state returns the handled position.
+func (t *positionTracker) state() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.handled
+}
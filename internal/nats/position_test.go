@@ -0,0 +1,139 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"order-service/internal/models"
+
+	"github.com/nats-io/stan.go"
+	"github.com/nats-io/stan.go/pb"
+)
+
+func TestPositionTrackerWaitsForOldestMessage(t *testing.T) {
+	p := newPositionTracker()
+	p.restore(10)
+	for _, seq := range []uint64{11, 12, 13} {
+		if !p.deliver(seq) {
+			t.Fatalf("expected message %d to be processed", seq)
+		}
+	}
+
+	// 12 commits first, but 11 is still being written
+	if got := p.through(12); got != 10 {
+		t.Errorf("expected position 10 while 11 is pending, got %d", got)
+	}
+	p.done(12)
+	if got := p.through(11); got != 12 {
+		t.Errorf("expected position 12 once 11 commits, got %d", got)
+	}
+	p.done(11)
+	if got := p.state(); got != 12 {
+		t.Errorf("expected handled position 12, got %d", got)
+	}
+
+	for _, seq := range []uint64{10, 11, 12} {
+		if p.deliver(seq) {
+			t.Errorf("expected redelivered message %d to be skipped", seq)
+		}
+	}
+	if !p.deliver(13) {
+		t.Error("expected the pending message 13 to be processed when redelivered")
+	}
+	p.done(13)
+	if got := p.state(); got != 13 {
+		t.Errorf("expected handled position 13, got %d", got)
+	}
+}
+
+// positionRepo records the position stored with every successful write.
+type positionRepo struct {
+	fail      string
+	positions []uint64
+}
+
+func (r *positionRepo) SaveOrder(ctx context.Context, order *models.Order) error {
+	_, err := r.SaveOrders(ctx, []*models.Order{order})
+	return err
+}
+
+func (r *positionRepo) SaveOrders(ctx context.Context, orders []*models.Order) ([]bool, error) {
+	for _, o := range orders {
+		if o.OrderUID == r.fail {
+			return nil, errors.New("constraint violation")
+		}
+	}
+	if fn, ok := models.PositionFromContext(ctx); ok {
+		r.positions = append(r.positions, fn(orders).Sequence)
+	}
+	return make([]bool, len(orders)), nil
+}
+
+func positionBatch(p *positionTracker, uids ...string) []received {
+	batch := make([]received, len(uids))
+	for i, uid := range uids {
+		order := sampleOrder()
+		order.OrderUID = uid
+		seq := uint64(i + 1)
+		p.deliver(seq)
+		batch[i] = received{msg: &stan.Msg{MsgProto: pb.MsgProto{Subject: "orders", Sequence: seq}}, order: order}
+	}
+	return batch
+}
+
+func batchOrders(batch []received) []*models.Order {
+	orders := make([]*models.Order, len(batch))
+	for i, m := range batch {
+		orders[i] = m.order
+	}
+	return orders
+}
+
+func TestPositionCoversCommittedBatch(t *testing.T) {
+	repo := &positionRepo{}
+	s := &Subscriber{pipeline: NewPipeline(repo, discardCache{}), positions: newPositionTracker()}
+	batch := positionBatch(s.positions, "a", "b", "c")
+
+	ctx := s.withPosition(context.Background(), batch)
+	for _, err := range s.pipeline.ProcessBatch(ctx, batchOrders(batch)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []uint64{3}; !reflect.DeepEqual(repo.positions, want) {
+		t.Errorf("expected positions %v, got %v", want, repo.positions)
+	}
+}
+
+func TestPositionSkipsFailedOrder(t *testing.T) {
+	repo := &positionRepo{fail: "b"}
+	s := &Subscriber{pipeline: NewPipeline(repo, discardCache{}), positions: newPositionTracker()}
+	batch := positionBatch(s.positions, "a", "b", "c")
+
+	ctx := s.withPosition(context.Background(), batch)
+	s.pipeline.ProcessBatch(ctx, batchOrders(batch))
+
+	// The batch fails and is written order by order: neither a nor c may
+	// cover b, which is redelivered
+	if len(repo.positions) != 2 {
+		t.Fatalf("expected a and c to be written, got positions %v", repo.positions)
+	}
+	for _, pos := range repo.positions {
+		if pos >= 2 {
+			t.Errorf("expected no position to pass the failed message, got %v", repo.positions)
+		}
+	}
+}
+
+func TestParseStartPosition(t *testing.T) {
+	for value, want := range map[string]StartPosition{"durable": StartDurable, "Stored": StartStored} {
+		if got, err := ParseStartPosition(value); err != nil || got != want {
+			t.Errorf("ParseStartPosition(%q) = %q, %v", value, got, err)
+		}
+	}
+	if _, err := ParseStartPosition("latest"); err == nil {
+		t.Error("expected an unknown start position to be rejected")
+	}
+}
@@ -0,0 +1,220 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"order-service/internal/models"
+
+	"github.com/nats-io/stan.go"
+)
+
+// RebuildStore is the database a rebuild writes to.
+type RebuildStore interface {
+	OrderHandler
+	BatchOrderHandler
+	StoredOrders(ctx context.Context, orderUIDs []string) (map[string]bool, error)
+}
+
+// RebuildOptions configures a rebuild. Without StartSequence or StartTime
+// the whole channel is replayed.
+type RebuildOptions struct {
+	Subject       string
+	StartSequence uint64
+	StartTime     time.Time
+	// DryRun only counts what would be written.
+	DryRun bool
+	// BatchSize is the number of orders written per transaction. Defaults
+	// to 500.
+	BatchSize int
+	// Idle ends the rebuild once no message arrived for this long, which
+	// means the channel has been read to its end. Defaults to 5s.
+	Idle time.Duration
+	// Progress, if set, is called after every batch.
+	Progress func(RebuildProgress)
+}
+
+// RebuildProgress counts the messages handled by a rebuild. New orders were
+// written, or would be in a dry run; existing ones were already stored and
+// are left untouched.
+type RebuildProgress struct {
+	Received      uint64 `json:"received"`
+	New           uint64 `json:"new"`
+	Existing      uint64 `json:"existing"`
+	Invalid       uint64 `json:"invalid"`
+	FirstSequence uint64 `json:"first_sequence"`
+	LastSequence  uint64 `json:"last_sequence"`
+}
+
+// Rebuild replays the channel from the chosen position through the same
+// decoding and validation as the subscriber and stores the orders that are
+// missing from the database. Stored orders are never changed, so a rebuild
+// can be repeated or run next to the service. The orders were announced
+// when they were first stored, so they are written without outbox events or
+// webhook deliveries (see models.WithReplay). It reads the channel with its
+// own non-durable subscription on sc, which should be a connection with a
+// client ID of its own; the service's durable subscription is not touched.
+func Rebuild(ctx context.Context, sc stan.Conn, store RebuildStore, opts RebuildOptions) (RebuildProgress, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.Idle <= 0 {
+		opts.Idle = 5 * time.Second
+	}
+	start := stan.DeliverAllAvailable()
+	switch {
+	case opts.StartSequence > 0:
+		start = stan.StartAtSequence(opts.StartSequence)
+	case !opts.StartTime.IsZero():
+		start = stan.StartAtTime(opts.StartTime)
+	}
+
+	done := make(chan struct{})
+	msgs := make(chan *stan.Msg, opts.BatchSize)
+	sub, err := sc.Subscribe(opts.Subject, func(msg *stan.Msg) {
+		select {
+		case msgs <- msg:
+		case <-done:
+		}
+	},
+		start,
+		stan.SetManualAckMode(),
+		stan.AckWait(time.Minute),
+		stan.MaxInflight(opts.BatchSize),
+	)
+	if err != nil {
+		return RebuildProgress{}, fmt.Errorf("failed to subscribe to subject %s: %w", opts.Subject, err)
+	}
+	defer func() {
+		// Release a delivery blocked on msgs before unsubscribing
+		close(done)
+		sub.Unsubscribe()
+	}()
+
+	r := newRebuilder(store, opts.DryRun)
+	idle := time.NewTimer(opts.Idle)
+	defer idle.Stop()
+	batch := make([]*stan.Msg, 0, opts.BatchSize)
+	for {
+		batch = batch[:0]
+		select {
+		case <-ctx.Done():
+			return r.progress, ctx.Err()
+		case <-idle.C:
+			return r.progress, nil
+		case msg := <-msgs:
+			batch = append(batch, msg)
+		}
+	fill:
+		for len(batch) < opts.BatchSize {
+			select {
+			case msg := <-msgs:
+				batch = append(batch, msg)
+			default:
+				break fill
+			}
+		}
+
+		if err := r.process(ctx, batch); err != nil {
+			return r.progress, err
+		}
+		for _, msg := range batch {
+			if err := msg.Ack(); err != nil {
+				return r.progress, fmt.Errorf("failed to ack message %d: %w", msg.Sequence, err)
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(r.progress)
+		}
+		idle.Reset(opts.Idle)
+	}
+}
+
+// rebuilder stores the orders of replayed messages.
+type rebuilder struct {
+	store    RebuildStore
+	pipeline *Pipeline
+	dryRun   bool
+	progress RebuildProgress
+
+	// seen holds the orders a dry run would have written, as they are
+	// not in the database for later batches to find.
+	seen map[string]bool
+}
+
+func newRebuilder(store RebuildStore, dryRun bool) *rebuilder {
+	r := &rebuilder{store: store, pipeline: NewPipeline(store, discardCache{}), dryRun: dryRun}
+	if dryRun {
+		r.seen = make(map[string]bool)
+	}
+	return r
+}
+
+// process handles a batch of messages in delivery order. Messages that
+// cannot be decoded or fail validation are counted and skipped; a database
+// error ends the rebuild.
+func (r *rebuilder) process(ctx context.Context, msgs []*stan.Msg) error {
+	var (
+		orders []*models.Order
+		uids   []string
+	)
+	for _, msg := range msgs {
+		r.progress.Received++
+		if r.progress.FirstSequence == 0 {
+			r.progress.FirstSequence = msg.Sequence
+		}
+		r.progress.LastSequence = msg.Sequence
+
+		order, err := DecodeMessage(msg.Data)
+		if err == nil {
+			err = Validate(ctx, order)
+		}
+		if err != nil {
+			r.progress.Invalid++
+			slog.WarnContext(ctx, "Skipping invalid message", "sequence", msg.Sequence, "error", err)
+			continue
+		}
+		orders = append(orders, order)
+		uids = append(uids, order.OrderUID)
+	}
+	if len(orders) == 0 {
+		return nil
+	}
+
+	stored, err := r.store.StoredOrders(ctx, uids)
+	if err != nil {
+		return err
+	}
+	// Like the subscriber, the first message of an order wins
+	var fresh []*models.Order
+	for _, order := range orders {
+		if stored[order.OrderUID] || r.seen[order.OrderUID] {
+			r.progress.Existing++
+			continue
+		}
+		stored[order.OrderUID] = true
+		if r.dryRun {
+			r.seen[order.OrderUID] = true
+		}
+		fresh = append(fresh, order)
+	}
+	r.progress.New += uint64(len(fresh))
+	if r.dryRun || len(fresh) == 0 {
+		return nil
+	}
+
+	for _, err := range r.pipeline.ProcessBatch(models.WithReplay(ctx), fresh) {
+		if err != nil && !errors.Is(err, ErrInvalidOrder) {
+			return err
+		}
+	}
+	return nil
+}
+
+// discardCache is the cache of a rebuild, which only writes the database.
+type discardCache struct{}
+
+func (discardCache) Set(string, *models.Order) {}
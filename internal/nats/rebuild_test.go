@@ -0,0 +1,137 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"order-service/internal/models"
+
+	"github.com/nats-io/stan.go"
+	"github.com/nats-io/stan.go/pb"
+)
+
+// rebuildRepo is a slowRepo that already holds some orders. Like the
+// repository, it enqueues an outbox event for every order written outside
+// a replay.
+type rebuildRepo struct {
+	slowRepo
+	stored map[string]bool
+	err    error
+	outbox []string
+}
+
+func (r *rebuildRepo) SaveOrder(ctx context.Context, order *models.Order) error {
+	_, err := r.SaveOrders(ctx, []*models.Order{order})
+	return err
+}
+
+func (r *rebuildRepo) SaveOrders(ctx context.Context, orders []*models.Order) ([]bool, error) {
+	written, err := r.slowRepo.SaveOrders(ctx, orders)
+	if err == nil && !models.IsReplay(ctx) {
+		for _, o := range orders {
+			r.outbox = append(r.outbox, o.OrderUID)
+		}
+	}
+	return written, err
+}
+
+func (r *rebuildRepo) StoredOrders(_ context.Context, uids []string) (map[string]bool, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	found := make(map[string]bool)
+	for _, uid := range uids {
+		if r.stored[uid] {
+			found[uid] = true
+		}
+	}
+	return found, nil
+}
+
+// replay encodes one message per order UID; an empty UID gives a message
+// that cannot be decoded.
+func replay(t *testing.T, first uint64, uids ...string) []*stan.Msg {
+	t.Helper()
+	msgs := make([]*stan.Msg, len(uids))
+	for i, uid := range uids {
+		data := []byte("not an order")
+		if uid != "" {
+			order := sampleOrder()
+			order.OrderUID = uid
+			var err error
+			if data, err = EncodeOrder(context.Background(), order, FormatJSON, "test-producer"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		msgs[i] = &stan.Msg{MsgProto: pb.MsgProto{Sequence: first + uint64(i), Data: data}}
+	}
+	return msgs
+}
+
+func TestRebuildStoresMissingOrders(t *testing.T) {
+	repo := &rebuildRepo{stored: map[string]bool{"a": true}}
+	r := newRebuilder(repo, false)
+
+	if err := r.process(context.Background(), replay(t, 10, "a", "b", "", "b", "c")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b", "c"}; !reflect.DeepEqual(repo.saved, want) {
+		t.Errorf("expected %v to be saved, got %v", want, repo.saved)
+	}
+	want := RebuildProgress{Received: 5, New: 2, Existing: 2, Invalid: 1, FirstSequence: 10, LastSequence: 14}
+	if r.progress != want {
+		t.Errorf("expected progress %+v, got %+v", want, r.progress)
+	}
+}
+
+func TestRebuildEnqueuesNoEvents(t *testing.T) {
+	repo := &rebuildRepo{}
+	r := newRebuilder(repo, false)
+
+	// A single order takes the SaveOrder path, several take SaveOrders
+	for _, batch := range [][]*stan.Msg{replay(t, 1, "a"), replay(t, 2, "b", "c")} {
+		if err := r.process(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.saved) != 3 {
+		t.Fatalf("expected every order to be written, got %v", repo.saved)
+	}
+	if len(repo.outbox) != 0 {
+		t.Errorf("expected no pending outbox events after a rebuild, got %v", repo.outbox)
+	}
+}
+
+func TestRebuildDryRun(t *testing.T) {
+	repo := &rebuildRepo{stored: map[string]bool{"a": true}}
+	r := newRebuilder(repo, true)
+
+	for _, batch := range [][]*stan.Msg{replay(t, 1, "a", "b"), replay(t, 3, "b", "c")} {
+		if err := r.process(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.saved) != 0 {
+		t.Errorf("expected a dry run to write nothing, got %v", repo.saved)
+	}
+	if r.progress.New != 2 || r.progress.Existing != 2 {
+		t.Errorf("expected 2 new and 2 existing orders across batches, got %+v", r.progress)
+	}
+}
+
+func TestRebuildStopsOnDatabaseError(t *testing.T) {
+	repo := &rebuildRepo{err: errors.New("connection refused")}
+	r := newRebuilder(repo, false)
+
+	if err := r.process(context.Background(), replay(t, 1, "a")); err == nil {
+		t.Fatal("expected the database error to be returned")
+	}
+
+	repo.err = nil
+	repo.fail = func(orders []*models.Order) bool { return true }
+	if err := r.process(context.Background(), replay(t, 2, "b")); err == nil {
+		t.Fatal("expected a failed write to be returned")
+	}
+}
@@ -2,16 +2,27 @@ package nats
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"order-service/internal/logging"
 	"order-service/internal/models"
+	"order-service/internal/tracing"
 
 	"github.com/nats-io/stan.go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("nats")
+
+// OrderHandler stores orders. SaveOrder returns models.ErrAlreadyStored for
+// an order whose UID is already stored.
 type OrderHandler interface {
 	SaveOrder(ctx context.Context, order *models.Order) error
 }
@@ -20,81 +31,537 @@ type CacheHandler interface {
 	Set(orderUID string, order *models.Order)
 }
 
+// DeadLetterStore keeps messages that can never be processed.
+type DeadLetterStore interface {
+	SaveDeadLetter(ctx context.Context, letter models.DeadLetter) error
+}
+
 type Subscriber struct {
-	sc           stan.Conn
+	sc          stan.Conn
+	pipeline    *Pipeline
+	deadLetters DeadLetterStore
+
+	// With a position store every write also stores the consumer
+	// position, and messages at or below it are skipped.
+	positionStore PositionStore
+	startFrom     StartPosition
+	positions     *positionTracker
+
+	workers     int
+	maxInflight int
+	batchSize   int
+	batchLinger time.Duration
+
+	// control serialises Subscribe, Pause, Resume and Close, which may wait
+	// for the workers without holding mu.
+	control sync.Mutex
+	// mu guards the subscription, which is replaced on every resume along
+	// with the workers that process its messages.
+	mu           sync.Mutex
+	subject      string
 	subscription stan.Subscription
-	repo         OrderHandler
-	cache        CacheHandler
+	partitions   *partitions[received]
+	pausedAt     time.Time
+
+	received     atomic.Uint64
+	acked        atomic.Uint64
+	failed       atomic.Uint64
+	deadLettered atomic.Uint64
+	skipped      atomic.Uint64
+	lastSequence atomic.Uint64
+}
+
+// durableName identifies the service's position in the channel on the
+// server, so it survives restarts and pauses.
+const durableName = "order-service-durable"
+
+// SubscriptionState describes the subscription and the messages handled
+// since the service started.
+type SubscriptionState struct {
+	Subject      string     `json:"subject"`
+	StartFrom    string     `json:"start_from"`
+	Durable      string     `json:"durable,omitempty"`
+	Connected    bool       `json:"connected"`
+	Paused       bool       `json:"paused"`
+	PausedAt     *time.Time `json:"paused_at,omitempty"`
+	Workers      int        `json:"workers"`
+	MaxInflight  int        `json:"max_inflight"`
+	BatchSize    int        `json:"batch_size"`
+	Received     uint64     `json:"received"`
+	Acked        uint64     `json:"acked"`
+	Failed       uint64     `json:"failed"`
+	DeadLettered uint64     `json:"dead_lettered"`
+	Skipped      uint64     `json:"skipped"`
+	LastSequence uint64     `json:"last_sequence"`
+	// Position is the sequence up to which every message is handled,
+	// with a position store only.
+	Position uint64 `json:"position,omitempty"`
+}
+
+// ErrNotSubscribed is returned when pausing or resuming a subscriber that
+// never subscribed.
+var ErrNotSubscribed = errors.New("not subscribed")
+
+// received is a decoded message waiting for a worker.
+type received struct {
+	ctx   context.Context
+	span  trace.Span
+	msg   *stan.Msg
+	order *models.Order
+}
+
+// SubscriberOption configures optional Subscriber settings.
+type SubscriberOption func(*Subscriber)
+
+// WithConcurrency processes messages on workers goroutines with at most
+// maxInflight messages delivered and not yet acknowledged. Messages of the
+// same order are always processed by the same worker, in delivery order.
+func WithConcurrency(workers, maxInflight int) SubscriberOption {
+	return func(s *Subscriber) {
+		s.workers = workers
+		s.maxInflight = maxInflight
+	}
+}
+
+// WithBatching lets each worker write up to size orders in one
+// transaction. A worker waits up to linger after taking a message for more
+// messages of its partition to arrive.
+func WithBatching(size int, linger time.Duration) SubscriberOption {
+	return func(s *Subscriber) {
+		s.batchSize = size
+		s.batchLinger = linger
+	}
+}
+
+// WithDeadLetters stores messages that fail decoding or validation in store
+// and acknowledges them, instead of leaving them to be redelivered after
+// every AckWait.
+func WithDeadLetters(store DeadLetterStore) SubscriberOption {
+	return func(s *Subscriber) {
+		s.deadLetters = store
+	}
 }
 
-func NewSubscriber(natsURL, clusterID, clientID string, repo OrderHandler, cache CacheHandler) (*Subscriber, error) {
+// WithStoredPosition stores the consumer position in the same transaction
+// as every write of the subscriber and skips messages at or below it, so a
+// message whose ack was lost is not processed again. With StartStored the
+// subscription starts after the stored position instead of using the
+// durable, so the database alone decides where reading continues.
+func WithStoredPosition(store PositionStore, start StartPosition) SubscriberOption {
+	return func(s *Subscriber) {
+		s.positionStore = store
+		s.startFrom = start
+	}
+}
+
+func NewSubscriber(natsURL, clusterID, clientID string, pipeline *Pipeline, opts ...SubscriberOption) (*Subscriber, error) {
 	sc, err := stan.Connect(clusterID, clientID,
 		stan.NatsURL(natsURL),
 		stan.SetConnectionLostHandler(func(_ stan.Conn, err error) {
-			log.Printf("Connection lost: %v", err)
+			slog.Error("NATS Streaming connection lost", "error", err)
 		}),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to NATS Streaming: %w", err)
 	}
 
-	return &Subscriber{
-		sc:    sc,
-		repo:  repo,
-		cache: cache,
-	}, nil
+	s := &Subscriber{
+		sc:          sc,
+		pipeline:    pipeline,
+		workers:     1,
+		maxInflight: stan.DefaultMaxInflight,
+		batchSize:   1,
+		startFrom:   StartDurable,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.positionStore != nil {
+		s.positions = newPositionTracker()
+	} else {
+		s.startFrom = StartDurable
+	}
+	if s.workers < 1 {
+		s.workers = 1
+	}
+	if s.maxInflight < 1 {
+		s.maxInflight = stan.DefaultMaxInflight
+	}
+	if s.batchSize < 1 {
+		s.batchSize = 1
+	}
+	return s, nil
 }
 
 func (s *Subscriber) Subscribe(subject string) error {
-	sub, err := s.sc.Subscribe(subject, s.messageHandler,
+	s.control.Lock()
+	defer s.control.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subject = subject
+	return s.subscribe()
+}
+
+// subscribe starts the subscription and its workers. Callers must hold
+// s.mu.
+func (s *Subscriber) subscribe() error {
+	opts := []stan.SubscriptionOption{
 		stan.SetManualAckMode(),
-		stan.DurableName("order-service-durable"),
-		stan.AckWait(30*time.Second),
-	)
+		stan.AckWait(30 * time.Second),
+		stan.MaxInflight(s.maxInflight),
+	}
+	if s.positions != nil {
+		// Read on every subscribe, so Resume also continues from what
+		// the database holds
+		stored, err := s.positionStore.ConsumerPosition(context.Background(), s.subject)
+		if err != nil {
+			return err
+		}
+		s.positions.restore(stored)
+		slog.Info("Loaded consumer position", "subject", s.subject, "sequence", stored)
+	}
+	if s.startFrom == StartStored {
+		// Messages delivered before a pause that are not handled yet are
+		// above the position, so they are delivered again
+		if next := s.positions.state() + 1; next > 1 {
+			opts = append(opts, stan.StartAtSequence(next))
+		} else {
+			opts = append(opts, stan.DeliverAllAvailable())
+		}
+	} else {
+		opts = append(opts, stan.DurableName(durableName))
+	}
+
+	// A partition queue never fills up by more than the server delivers
+	// ahead of acks.
+	parts := newPartitions(s.workers, s.maxInflight, s.batchSize, s.batchLinger, s.processBatch)
+	sub, err := s.sc.Subscribe(s.subject, func(msg *stan.Msg) { s.messageHandler(parts, msg) }, opts...)
 	if err != nil {
-		return fmt.Errorf("failed to subscribe to subject %s: %w", subject, err)
+		parts.Close()
+		return fmt.Errorf("failed to subscribe to subject %s: %w", s.subject, err)
 	}
 
 	s.subscription = sub
-	log.Printf("Successfully subscribed to subject: %s", subject)
+	s.partitions = parts
+	slog.Info("Subscribed to NATS subject", "subject", s.subject, "start_from", s.startFrom,
+		"workers", s.workers, "max_inflight", s.maxInflight, "batch_size", s.batchSize, "batch_linger", s.batchLinger)
 	return nil
 }
 
-func (s *Subscriber) messageHandler(msg *stan.Msg) {
-	log.Printf("Received message: %s", string(msg.Data))
+// Pause stops receiving messages. The durable subscription is closed, not
+// removed, so the server keeps the position and Resume continues from it;
+// starting from the stored position, Resume continues after that one.
+// Messages already received are processed and acknowledged first; those
+// delivered later are redelivered after Resume.
+func (s *Subscriber) Pause() error {
+	s.control.Lock()
+	defer s.control.Unlock()
+	s.mu.Lock()
+	sub, parts, subject := s.subscription, s.partitions, s.subject
+	s.mu.Unlock()
+	if sub == nil {
+		if subject == "" {
+			return ErrNotSubscribed
+		}
+		return nil
+	}
 
-	var order models.Order
-	if err := json.Unmarshal(msg.Data, &order); err != nil {
-		log.Printf("Failed to unmarshal order: %v", err)
+	// The subscription stays open until the workers are done, so their
+	// acks reach the server. State is not blocked meanwhile.
+	parts.Close()
+	err := sub.Close()
+
+	s.mu.Lock()
+	s.subscription, s.partitions = nil, nil
+	s.pausedAt = time.Now()
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to pause subscription: %w", err)
+	}
+	slog.Info("Paused NATS subscription", "subject", subject)
+	return nil
+}
+
+// Resume continues a paused subscription from where it stopped.
+func (s *Subscriber) Resume() error {
+	s.control.Lock()
+	defer s.control.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subject == "" {
+		return ErrNotSubscribed
+	}
+	if s.subscription != nil {
+		return nil
+	}
+	if err := s.subscribe(); err != nil {
+		return err
+	}
+	s.pausedAt = time.Time{}
+	return nil
+}
+
+// State returns the current state of the subscription.
+func (s *Subscriber) State() SubscriptionState {
+	s.mu.Lock()
+	state := SubscriptionState{
+		Subject:     s.subject,
+		StartFrom:   string(s.startFrom),
+		Paused:      s.subject != "" && s.subscription == nil,
+		Workers:     s.workers,
+		MaxInflight: s.maxInflight,
+		BatchSize:   s.batchSize,
+	}
+	if s.startFrom == StartDurable {
+		state.Durable = durableName
+	}
+	if state.Paused {
+		pausedAt := s.pausedAt
+		state.PausedAt = &pausedAt
+	}
+	s.mu.Unlock()
+
+	if nc := s.sc.NatsConn(); nc != nil {
+		state.Connected = nc.IsConnected()
+	}
+	state.Received = s.received.Load()
+	state.Acked = s.acked.Load()
+	state.Failed = s.failed.Load()
+	state.DeadLettered = s.deadLettered.Load()
+	state.Skipped = s.skipped.Load()
+	state.LastSequence = s.lastSequence.Load()
+	if s.positions != nil {
+		state.Position = s.positions.state()
+	}
+	return state
+}
+
+// messageHandler runs on the subscription's delivery goroutine. It decodes
+// the message and hands it to the partition of its order, so orders are
+// stored concurrently but each order's messages in delivery order.
+func (s *Subscriber) messageHandler(parts *partitions[received], msg *stan.Msg) {
+	s.received.Add(1)
+	s.lastSequence.Store(msg.Sequence)
+	if s.positions != nil && !s.positions.deliver(msg.Sequence) {
+		s.skip(msg)
 		return
 	}
+	ctx, span, order, err := s.receive(msg)
+	if err != nil {
+		s.failed.Add(1)
+		s.deadLetter(ctx, msg, err)
+		tracing.End(span, err)
+		return
+	}
+
+	span.AddEvent("queued")
+	if !parts.Submit(order.OrderUID, received{ctx: ctx, span: span, msg: msg, order: order}) {
+		// Shutting down or pausing: the message stays unacknowledged and
+		// is redelivered.
+		tracing.End(span, errSubscriberClosed)
+	}
+}
+
+var errSubscriberClosed = errors.New("subscriber is closed")
+
+// skip acknowledges a message at or below the stored position. Its effects
+// are committed, but its ack was lost.
+func (s *Subscriber) skip(msg *stan.Msg) {
+	s.skipped.Add(1)
+	slog.Info("Skipping message already handled", "subject", msg.Subject, "sequence", msg.Sequence)
+	if err := msg.Ack(); err != nil {
+		slog.Error("Failed to ack message", "subject", msg.Subject, "sequence", msg.Sequence, "error", err)
+	}
+}
+
+// receive parses and decodes msg. The returned span covers the whole
+// handling of the message and must be ended by the caller.
+func (s *Subscriber) receive(msg *stan.Msg) (context.Context, trace.Span, *models.Order, error) {
+	env, parseErr := ParseEnvelope(msg.Data)
+
+	// The receive span continues the trace started by the publisher.
+	ctx, span := tracer.Start(tracing.Extract(context.Background(), env.Headers), "orders receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "stan"),
+			attribute.String("messaging.destination.name", msg.Subject),
+			attribute.Int64("messaging.message.sequence", int64(msg.Sequence)),
+			attribute.Bool("messaging.message.redelivered", msg.Redelivered),
+			attribute.String("messaging.message.format", string(env.Format)),
+			attribute.String("messaging.message.id", env.MessageID),
+			attribute.Int("messaging.message.schema_version", env.Version()),
+		))
+
+	// Every log line emitted while handling the message carries its
+	// subject and sequence, and the order UID once it is known. The
+	// payload itself is never logged as it contains customer PII.
+	ctx = logging.With(ctx, "subject", msg.Subject, "sequence", msg.Sequence)
+	if env.MessageID != "" {
+		ctx = logging.With(ctx, "message_id", env.MessageID, "producer", env.Producer)
+	}
+	if sc := span.SpanContext(); sc.IsValid() {
+		ctx = logging.With(ctx, "trace_id", sc.TraceID().String())
+	}
+	slog.DebugContext(ctx, "Received message", "size", len(msg.Data), "format", env.Format, "schema_version", env.Version(), "redelivered", msg.Redelivered)
 
-	// Set current timestamp if not provided
-	if order.DateCreated.IsZero() {
-		order.DateCreated = time.Now()
+	if parseErr != nil {
+		slog.ErrorContext(ctx, "Failed to parse message envelope", "error", parseErr)
+		return ctx, span, nil, parseErr
 	}
 
-	// Save to database
-	ctx := context.Background()
-	if err := s.repo.SaveOrder(ctx, &order); err != nil {
-		log.Printf("Failed to save order to DB: %v", err)
+	var order models.Order
+	if err := decodeOrder(ctx, env, &order); err != nil {
+		slog.ErrorContext(ctx, "Failed to decode order", "error", err)
+		return ctx, span, nil, err
+	}
+	ctx = logging.With(ctx, "order_uid", order.OrderUID)
+	span.SetAttributes(attribute.String("order.uid", order.OrderUID))
+	return ctx, span, &order, nil
+}
+
+// processBatch stores the orders of a batch and acknowledges each message
+// whose order has been committed.
+func (s *Subscriber) processBatch(batch []received) {
+	if len(batch) == 1 {
+		m := batch[0]
+		m.span.AddEvent("dequeued")
+		err := s.pipeline.Process(s.withPosition(m.ctx, batch), m.order)
+		tracing.End(m.span, s.finish(m.ctx, m.msg, err))
 		return
 	}
 
-	// Save to cache
-	s.cache.Set(order.OrderUID, &order)
+	// The batch has its own trace, linked to that of every message in it.
+	links := make([]trace.Link, len(batch))
+	orders := make([]*models.Order, len(batch))
+	for i, m := range batch {
+		m.span.AddEvent("dequeued")
+		links[i] = trace.Link{SpanContext: m.span.SpanContext()}
+		orders[i] = m.order
+	}
+	ctx, span := tracer.Start(context.Background(), "orders batch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("batch.size", len(batch))))
+	errs := s.pipeline.ProcessBatch(s.withPosition(ctx, batch), orders)
+	span.End()
+
+	for i, m := range batch {
+		tracing.End(m.span, s.finish(m.ctx, m.msg, errs[i]))
+	}
+}
+
+// withPosition makes the writes of the orders of batch store the consumer
+// position. A transaction covers the messages of the orders it writes, as
+// a failed batch is written again order by order.
+func (s *Subscriber) withPosition(ctx context.Context, batch []received) context.Context {
+	if s.positions == nil {
+		return ctx
+	}
+	subject := batch[0].msg.Subject
+	return models.WithPosition(ctx, func(orders []*models.Order) models.ConsumerPosition {
+		var seqs []uint64
+		for _, m := range batch {
+			if slices.Contains(orders, m.order) {
+				seqs = append(seqs, m.msg.Sequence)
+			}
+		}
+		return models.ConsumerPosition{Subject: subject, Sequence: s.positions.through(seqs...)}
+	})
+}
 
-	log.Printf("Order %s processed successfully", order.OrderUID)
+// handled records that the effects of msg are committed, so the position
+// may pass it.
+func (s *Subscriber) handled(msg *stan.Msg) {
+	if s.positions != nil {
+		s.positions.done(msg.Sequence)
+	}
+}
+
+// finish acknowledges msg if its order was stored, and returns the error
+// of the message.
+func (s *Subscriber) finish(ctx context.Context, msg *stan.Msg, err error) error {
+	if err != nil {
+		s.failed.Add(1)
+		slog.ErrorContext(ctx, "Failed to process order", "error", err)
+		if errors.Is(err, ErrInvalidOrder) {
+			s.deadLetter(ctx, msg, err)
+		}
+		return err
+	}
+
+	slog.InfoContext(ctx, "Order processed")
+	s.handled(msg)
 
 	// Acknowledge the message
 	if err := msg.Ack(); err != nil {
-		log.Printf("Failed to ack message: %v", err)
+		slog.ErrorContext(ctx, "Failed to ack message", "error", err)
+		return err
+	}
+	s.acked.Add(1)
+	return nil
+}
+
+// deadLetter stores msg with the reason it failed and acknowledges it.
+// Without a store, or if storing fails, msg stays unacknowledged and is
+// redelivered.
+func (s *Subscriber) deadLetter(ctx context.Context, msg *stan.Msg, cause error) {
+	if s.deadLetters == nil {
+		return
+	}
+	if s.positions != nil {
+		ctx = models.WithPosition(ctx, func([]*models.Order) models.ConsumerPosition {
+			return models.ConsumerPosition{Subject: msg.Subject, Sequence: s.positions.through(msg.Sequence)}
+		})
+	}
+	err := s.deadLetters.SaveDeadLetter(ctx, models.DeadLetter{
+		Subject:  msg.Subject,
+		Sequence: msg.Sequence,
+		Data:     msg.Data,
+		Error:    cause.Error(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to store dead letter", "error", err)
+		return
+	}
+	s.handled(msg)
+	if err := msg.Ack(); err != nil {
+		slog.ErrorContext(ctx, "Failed to ack message", "error", err)
+		return
 	}
+	s.deadLettered.Add(1)
+	slog.WarnContext(ctx, "Moved message to dead letters", "reason", cause)
 }
 
+func decodeOrder(ctx context.Context, env Envelope, order *models.Order) error {
+	_, span := tracer.Start(ctx, "decode order")
+	err := env.Decode(order)
+	tracing.End(span, err)
+	return err
+}
+
+// Publish sends data to subject over the subscriber's connection and waits
+// for the server to acknowledge it.
+func (s *Subscriber) Publish(subject string, data []byte) error {
+	return s.sc.Publish(subject, data)
+}
+
+// Close finishes the messages already handed to workers, so their acks
+// still reach the server, then closes the subscription and connection. Like
+// Pause, it keeps the durable subscription on the server, so the next start
+// continues from its position; unsubscribing would delete the durable and
+// restart it from the beginning of the channel.
 func (s *Subscriber) Close() error {
-	if s.subscription != nil {
-		if err := s.subscription.Unsubscribe(); err != nil {
+	s.control.Lock()
+	defer s.control.Unlock()
+	s.mu.Lock()
+	sub, parts := s.subscription, s.partitions
+	s.mu.Unlock()
+
+	if parts != nil {
+		parts.Close()
+	}
+	if sub != nil {
+		if err := sub.Close(); err != nil {
 			return err
 		}
 	}
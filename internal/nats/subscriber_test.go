@@ -0,0 +1,90 @@
+package nats
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/stan.go"
+)
+
+// fakeSubscription records how the subscription was ended.
+type fakeSubscription struct {
+	stan.Subscription
+	closed, unsubscribed atomic.Bool
+}
+
+func (s *fakeSubscription) Close() error {
+	s.closed.Store(true)
+	return nil
+}
+
+func (s *fakeSubscription) Unsubscribe() error {
+	s.unsubscribed.Store(true)
+	return nil
+}
+
+type fakeConn struct {
+	stan.Conn
+	closed bool
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) NatsConn() *nats.Conn { return nil }
+
+func TestCloseKeepsDurable(t *testing.T) {
+	sub, conn := &fakeSubscription{}, &fakeConn{}
+	s := &Subscriber{sc: conn, subscription: sub, subject: "orders", startFrom: StartDurable}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sub.unsubscribed.Load() || !sub.closed.Load() {
+		t.Errorf("expected the subscription to be closed, not removed: closed=%v unsubscribed=%v",
+			sub.closed.Load(), sub.unsubscribed.Load())
+	}
+	if !conn.closed {
+		t.Error("expected the connection to be closed")
+	}
+}
+
+func TestPauseDrainsBeforeClosing(t *testing.T) {
+	sub := &fakeSubscription{}
+	release := make(chan struct{})
+	var closedWhileHandling atomic.Bool
+	parts := newPartitions(1, 4, 1, 0, func([]received) {
+		<-release
+		// The ack of a queued message needs the open subscription
+		closedWhileHandling.Store(sub.closed.Load())
+	})
+	parts.Submit("a", received{})
+	s := &Subscriber{sc: &fakeConn{}, subscription: sub, partitions: parts, subject: "orders", startFrom: StartDurable}
+
+	paused := make(chan error)
+	go func() { paused <- s.Pause() }()
+
+	// State answers while the workers are still busy
+	state := make(chan SubscriptionState)
+	go func() { state <- s.State() }()
+	select {
+	case <-state:
+	case <-time.After(time.Second):
+		t.Fatal("expected State not to wait for the drain")
+	}
+
+	close(release)
+	if err := <-paused; err != nil {
+		t.Fatal(err)
+	}
+	if closedWhileHandling.Load() {
+		t.Error("expected the subscription to stay open until queued messages are handled")
+	}
+	if !sub.closed.Load() || !s.State().Paused {
+		t.Error("expected the subscription to be closed and paused")
+	}
+}
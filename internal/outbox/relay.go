@@ -0,0 +1,270 @@
+// Package outbox publishes events recorded in the outbox table to NATS.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/nats"
+	"order-service/internal/schema"
+	"order-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("outbox")
+
+// Store reads pending events and records their delivery.
+type Store interface {
+	PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	MarkEventsSent(ctx context.Context, ids []int64) error
+	MarkEventFailed(ctx context.Context, id int64, reason string, dead bool) error
+	OutboxBacklog(ctx context.Context) (int, time.Time, error)
+}
+
+// Publisher sends a message and returns once the server has stored it.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// Config configures a Relay.
+type Config struct {
+	// Subjects maps event types to NATS subjects. Events of other types
+	// are not published.
+	Subjects map[string]string
+	// Producer is written to the envelope of every message.
+	Producer string
+	// Interval is how often the outbox is polled.
+	Interval time.Duration
+	// BatchSize is the number of events read per query.
+	BatchSize int
+	// MaxAttempts is the number of failed attempts after which an event is
+	// dead and no longer retried, so that an event that can never be
+	// published does not hold up the outbox. Defaults to 10.
+	MaxAttempts int
+}
+
+// ParseSubjects parses a comma-separated list of type=subject pairs, e.g.
+// "order.persisted=orders.persisted".
+func ParseSubjects(s string) (map[string]string, error) {
+	subjects := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		eventType, subject, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(eventType) == "" || strings.TrimSpace(subject) == "" {
+			return nil, fmt.Errorf("invalid outbox subject %q, expected type=subject", pair)
+		}
+		subjects[strings.TrimSpace(eventType)] = strings.TrimSpace(subject)
+	}
+	return subjects, nil
+}
+
+// Stats describes the relay's progress. Pending and OldestAge are measured
+// after each poll.
+type Stats struct {
+	Pending          int       `json:"pending"`
+	OldestAgeSeconds float64   `json:"oldest_age_seconds"`
+	Published        uint64    `json:"published"`
+	Failed           uint64    `json:"failed"`
+	Dead             uint64    `json:"dead"`
+	LastPoll         time.Time `json:"last_poll"`
+	LastError        string    `json:"last_error,omitempty"`
+}
+
+// Relay publishes outbox events in order and marks them sent. Delivery is
+// at least once: an event is published again if marking it sent fails, so
+// consumers should deduplicate on message_id. Events of one order are
+// published in the order they were recorded; after a failure the order's
+// remaining events wait for the next poll, unless it is dead. One relay
+// should run per database.
+type Relay struct {
+	store Store
+	pub   Publisher
+	cfg   Config
+
+	published atomic.Uint64
+	failed    atomic.Uint64
+	dead      atomic.Uint64
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+func NewRelay(store Store, pub Publisher, cfg Config) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	return &Relay{store: store, pub: pub, cfg: cfg}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Outbox relay failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes pending events until the outbox is drained or an event
+// fails, then refreshes the backlog statistics.
+func (r *Relay) Flush(ctx context.Context) error {
+	err := r.drain(ctx)
+
+	pending, oldest, backlogErr := r.store.OutboxBacklog(ctx)
+	if err == nil {
+		err = backlogErr
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.LastPoll = time.Now()
+	if backlogErr == nil {
+		r.stats.Pending = pending
+		r.stats.OldestAgeSeconds = 0
+		if !oldest.IsZero() {
+			r.stats.OldestAgeSeconds = time.Since(oldest).Seconds()
+		}
+	}
+	r.stats.LastError = ""
+	if err != nil {
+		r.stats.LastError = err.Error()
+	}
+	return err
+}
+
+func (r *Relay) drain(ctx context.Context) error {
+	for {
+		events, err := r.store.PendingEvents(ctx, r.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		// An order whose event failed must not have its later events
+		// published before it.
+		blocked := make(map[string]bool)
+		var sent []int64
+		for _, e := range events {
+			if blocked[e.OrderUID] {
+				continue
+			}
+			if err := r.publish(ctx, e); err != nil {
+				r.failed.Add(1)
+				logger := slog.With("event_id", e.ID, "order_uid", e.OrderUID, "type", e.Type, "attempt", e.Attempts+1)
+
+				// A dead event no longer holds back the later events of
+				// its order.
+				dead := e.Attempts+1 >= r.cfg.MaxAttempts
+				if dead {
+					r.dead.Add(1)
+					logger.ErrorContext(ctx, "Outbox event is dead", "error", err)
+				} else {
+					blocked[e.OrderUID] = true
+					logger.WarnContext(ctx, "Failed to publish outbox event", "error", err)
+				}
+				if err := r.store.MarkEventFailed(ctx, e.ID, err.Error(), dead); err != nil {
+					return err
+				}
+				continue
+			}
+			sent = append(sent, e.ID)
+		}
+
+		if err := r.store.MarkEventsSent(ctx, sent); err != nil {
+			return err
+		}
+		r.published.Add(uint64(len(sent)))
+
+		if len(blocked) > 0 {
+			return fmt.Errorf("%d orders have unpublished events", len(blocked))
+		}
+		if len(events) < r.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// messageID identifies the message of e downstream. It depends only on the
+// order and the event type, never on the outbox row, so the same event
+// written again, for example after the database is rebuilt from the NATS
+// channel, keeps its ID and receivers deduplicate on it. Each event type
+// is recorded at most once per order.
+func messageID(e models.OutboxEvent) string {
+	return e.OrderUID + ":" + e.Type
+}
+
+func (r *Relay) publish(ctx context.Context, e models.OutboxEvent) (err error) {
+	subject, ok := r.cfg.Subjects[e.Type]
+	if !ok {
+		return fmt.Errorf("no subject configured for event type %q", e.Type)
+	}
+
+	var headers map[string]string
+	if len(e.Headers) > 0 {
+		if err := json.Unmarshal(e.Headers, &headers); err != nil {
+			return fmt.Errorf("invalid event headers: %w", err)
+		}
+	}
+
+	// The publish span continues the trace of the request that stored
+	// the order.
+	ctx, span := tracer.Start(tracing.Extract(ctx, headers), "outbox publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "stan"),
+			attribute.String("messaging.destination.name", subject),
+			attribute.String("order.uid", e.OrderUID),
+		))
+	defer func() { tracing.End(span, err) }()
+
+	data, err := nats.Envelope{
+		Format:        nats.FormatJSON,
+		SchemaVersion: schema.CurrentOrderVersion,
+		Type:          e.Type,
+		Producer:      r.cfg.Producer,
+		MessageID:     messageID(e),
+		Timestamp:     e.CreatedAt.UTC(),
+		Headers:       tracing.Inject(ctx),
+		Order:         e.Payload,
+	}.Marshal()
+	if err != nil {
+		return err
+	}
+	return r.pub.Publish(subject, data)
+}
+
+// Stats returns the relay's progress.
+func (r *Relay) Stats() Stats {
+	r.mu.Lock()
+	stats := r.stats
+	r.mu.Unlock()
+	stats.Published = r.published.Load()
+	stats.Failed = r.failed.Load()
+	stats.Dead = r.dead.Load()
+	return stats
+}
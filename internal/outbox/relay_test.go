@@ -0,0 +1,271 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/nats"
+)
+
+type fakeStore struct {
+	mu      sync.Mutex
+	events  []models.OutboxEvent
+	sent    map[int64]bool
+	failed  map[int64]string
+	dead    map[int64]bool
+	markErr error
+}
+
+func newFakeStore(events ...models.OutboxEvent) *fakeStore {
+	return &fakeStore{events: events, sent: map[int64]bool{}, failed: map[int64]string{}, dead: map[int64]bool{}}
+}
+
+func (s *fakeStore) PendingEvents(_ context.Context, limit int) ([]models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.OutboxEvent
+	for _, e := range s.events {
+		if !s.sent[e.ID] && !s.dead[e.ID] && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) MarkEventsSent(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.markErr != nil {
+		return s.markErr
+	}
+	for _, id := range ids {
+		s.sent[id] = true
+	}
+	return nil
+}
+
+func (s *fakeStore) MarkEventFailed(_ context.Context, id int64, reason string, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed[id] = reason
+	s.dead[id] = dead
+	for i := range s.events {
+		if s.events[i].ID == id {
+			s.events[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) OutboxBacklog(context.Context) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var pending int
+	var oldest time.Time
+	for _, e := range s.events {
+		if s.sent[e.ID] || s.dead[e.ID] {
+			continue
+		}
+		pending++
+		if oldest.IsZero() || e.CreatedAt.Before(oldest) {
+			oldest = e.CreatedAt
+		}
+	}
+	return pending, oldest, nil
+}
+
+type published struct {
+	subject string
+	env     nats.Envelope
+}
+
+type fakePublisher struct {
+	messages []published
+	fail     func(env nats.Envelope) bool
+}
+
+func (p *fakePublisher) Publish(subject string, data []byte) error {
+	env, err := nats.ParseEnvelope(data)
+	if err != nil {
+		return err
+	}
+	if p.fail != nil && p.fail(env) {
+		return errors.New("nats unavailable")
+	}
+	p.messages = append(p.messages, published{subject, env})
+	return nil
+}
+
+func event(id int64, orderUID string) models.OutboxEvent {
+	return models.OutboxEvent{
+		ID:        id,
+		OrderUID:  orderUID,
+		Type:      "order.persisted",
+		Payload:   []byte(`{"order_uid":"` + orderUID + `"}`),
+		CreatedAt: time.Now().Add(-time.Minute),
+	}
+}
+
+var testConfig = Config{
+	Subjects:  map[string]string{"order.persisted": "orders.persisted"},
+	Producer:  "test",
+	BatchSize: 2,
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+	store := newFakeStore(event(1, "a"), event(2, "b"), event(3, "c"))
+	pub := &fakePublisher{}
+	relay := NewRelay(store, pub, testConfig)
+
+	if err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if len(pub.messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(pub.messages))
+	}
+	for i, m := range pub.messages {
+		if want := string(rune('a'+i)) + ":order.persisted"; m.env.MessageID != want {
+			t.Errorf("message %d: expected ID %s, got %s", i, want, m.env.MessageID)
+		}
+		if m.subject != "orders.persisted" || m.env.Type != "order.persisted" || m.env.Producer != "test" {
+			t.Errorf("unexpected message %+v", m)
+		}
+	}
+	stats := relay.Stats()
+	if stats.Pending != 0 || stats.Published != 3 || stats.LastError != "" {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestRelayHoldsBackLaterEventsOfFailedOrder(t *testing.T) {
+	archived := event(3, "a")
+	archived.Type = "order.archived"
+	store := newFakeStore(event(1, "a"), event(2, "b"), archived)
+	pub := &fakePublisher{fail: func(env nats.Envelope) bool { return env.MessageID == "a:order.persisted" }}
+	subjects := map[string]string{"order.persisted": "orders.persisted", "order.archived": "orders.archived"}
+	relay := NewRelay(store, pub, Config{Subjects: subjects, BatchSize: 10})
+
+	if err := relay.Flush(context.Background()); err == nil {
+		t.Fatal("expected Flush to report the failed event")
+	}
+	if len(pub.messages) != 1 || pub.messages[0].env.MessageID != "b:order.persisted" {
+		t.Fatalf("expected only order b to be published, got %+v", pub.messages)
+	}
+	if store.failed[1] == "" || store.sent[3] {
+		t.Errorf("expected event 1 to be marked failed and event 3 to wait, got failed=%v sent=%v", store.failed, store.sent)
+	}
+
+	stats := relay.Stats()
+	if stats.Pending != 2 || stats.Failed != 1 || stats.OldestAgeSeconds < 59 || stats.LastError == "" {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// Once NATS recovers the held back events go out in order.
+	pub.fail = nil
+	if err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if len(pub.messages) != 3 || pub.messages[1].env.MessageID != "a:order.persisted" || pub.messages[2].env.MessageID != "a:order.archived" {
+		t.Errorf("expected events 1 and 3 in order, got %+v", pub.messages)
+	}
+}
+
+func TestRelayRepublishesWhenMarkingFails(t *testing.T) {
+	store := newFakeStore(event(1, "a"))
+	store.markErr = errors.New("db down")
+	pub := &fakePublisher{}
+	relay := NewRelay(store, pub, testConfig)
+
+	if err := relay.Flush(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+	store.markErr = nil
+	if err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if len(pub.messages) != 2 {
+		t.Errorf("expected the event to be published again, got %d messages", len(pub.messages))
+	}
+}
+
+func TestRelayMessageIDSurvivesRewrite(t *testing.T) {
+	// The same event written again, e.g. by a rebuild, gets another row ID
+	var ids []string
+	for _, id := range []int64{1, 7} {
+		pub := &fakePublisher{}
+		if err := NewRelay(newFakeStore(event(id, "a")), pub, testConfig).Flush(context.Background()); err != nil {
+			t.Fatalf("Flush: %v", err)
+		}
+		ids = append(ids, pub.messages[0].env.MessageID)
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("expected the same message ID for both rows, got %v", ids)
+	}
+}
+
+func TestRelayUnknownEventType(t *testing.T) {
+	e := event(1, "a")
+	e.Type = "order.archived"
+	store := newFakeStore(e)
+	relay := NewRelay(store, &fakePublisher{}, testConfig)
+
+	if err := relay.Flush(context.Background()); err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(store.failed[1], "no subject") {
+		t.Errorf("expected the event to be marked failed, got %q", store.failed[1])
+	}
+}
+
+func TestParseSubjects(t *testing.T) {
+	subjects, err := ParseSubjects(" order.persisted = orders.persisted, order.updated=orders.updated,")
+	if err != nil {
+		t.Fatalf("ParseSubjects: %v", err)
+	}
+	if len(subjects) != 2 || subjects["order.persisted"] != "orders.persisted" || subjects["order.updated"] != "orders.updated" {
+		t.Errorf("unexpected subjects %v", subjects)
+	}
+	if _, err := ParseSubjects("order.persisted"); err == nil {
+		t.Error("expected an error for a pair without a subject")
+	}
+}
+
+func TestRelayGivesUpOnEventsThatNeverPublish(t *testing.T) {
+	// Unpublishable events fill the whole first batch
+	var events []models.OutboxEvent
+	for i, uid := range []string{"a", "b", "c"} {
+		e := event(int64(i+1), uid)
+		e.Type = "order.archived"
+		events = append(events, e)
+	}
+	events = append(events, event(4, "a"), event(5, "d"))
+	store := newFakeStore(events...)
+	pub := &fakePublisher{}
+	relay := NewRelay(store, pub, Config{Subjects: testConfig.Subjects, BatchSize: 3, MaxAttempts: 2})
+
+	if err := relay.Flush(context.Background()); err == nil {
+		t.Fatal("expected Flush to report the failed events")
+	}
+	if len(pub.messages) != 0 {
+		t.Fatalf("expected nothing to be published before the events are dead, got %+v", pub.messages)
+	}
+
+	if err := relay.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !store.dead[1] || !store.dead[2] || !store.dead[3] {
+		t.Errorf("expected events 1-3 to be dead, got %v", store.dead)
+	}
+	if len(pub.messages) != 2 || pub.messages[0].env.MessageID != "a:order.persisted" || pub.messages[1].env.MessageID != "d:order.persisted" {
+		t.Errorf("expected the events behind the dead ones to be published, got %+v", pub.messages)
+	}
+	if stats := relay.Stats(); stats.Pending != 0 || stats.Dead != 3 || stats.Failed != 6 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(limit Limit) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(limit)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestLimiterBurstThenDeny(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		res := l.Allow("client")
+		if !res.Allowed {
+			t.Fatalf("Request %d: expected to be allowed", i)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("Request %d: expected remaining %d, got %d", i, 2-i, res.Remaining)
+		}
+	}
+
+	res := l.Allow("client")
+	if res.Allowed {
+		t.Fatal("Expected request over burst to be denied")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("Expected retry after 1s, got %v", res.RetryAfter)
+	}
+	if res.Reset != 3*time.Second {
+		t.Errorf("Expected reset in 3s, got %v", res.Reset)
+	}
+}
+
+func TestLimiterRefills(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 2, Burst: 1})
+
+	if !l.Allow("client").Allowed {
+		t.Fatal("Expected first request to be allowed")
+	}
+	if l.Allow("client").Allowed {
+		t.Fatal("Expected second request to be denied")
+	}
+
+	clock.advance(500 * time.Millisecond)
+	if !l.Allow("client").Allowed {
+		t.Error("Expected request to be allowed after refill")
+	}
+}
+
+func TestLimiterKeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 1})
+
+	if !l.Allow("a").Allowed || !l.Allow("b").Allowed {
+		t.Fatal("Expected both clients to be allowed")
+	}
+	if l.Allow("a").Allowed {
+		t.Error("Expected client a to be limited")
+	}
+}
+
+func TestLimiterDropsIdleBuckets(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Rate: 1, Burst: 2})
+
+	l.Allow("a")
+	clock.advance(5 * time.Second)
+	l.Allow("b")
+
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("Expected idle bucket to be dropped")
+	}
+}
@@ -0,0 +1,310 @@
+// Package reconcile compares the order cache with the database. Every
+// order is reduced to a hash of its content, so a run finds orders missing
+// on either side as well as orders whose cached content differs from the
+// stored one, and can repair the cache from the database.
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Problems found for an order.
+const (
+	MissingInCache = "missing_in_cache"
+	MissingInDB    = "missing_in_db"
+	Differs        = "differs"
+)
+
+// maxDrifts caps the drifts listed in a report; the counts are always
+// complete.
+const maxDrifts = 100
+
+// Store reads orders from the database.
+type Store interface {
+	StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(*models.Order) error) error
+	GetOrder(ctx context.Context, orderUID string) (*models.Order, error)
+}
+
+// Cache is the order cache being checked.
+type Cache interface {
+	Get(orderUID string) (*models.Order, bool)
+	GetAll() []models.Order
+	Set(orderUID string, order *models.Order)
+	Delete(orderUID string) bool
+}
+
+// Config configures a Reconciler.
+type Config struct {
+	// Window limits the check to orders created within it, matching the
+	// cache restore window. Zero checks every order.
+	Window time.Duration
+	// Interval is how often Run reconciles. Zero only reconciles on
+	// demand.
+	Interval time.Duration
+	// Repair makes periodic runs correct the cache from the database.
+	Repair bool
+}
+
+// Drift is an order whose cached and stored versions disagree.
+type Drift struct {
+	OrderUID  string `json:"order_uid"`
+	Problem   string `json:"problem"`
+	CacheHash string `json:"cache_hash,omitempty"`
+	DBHash    string `json:"db_hash,omitempty"`
+	Repaired  bool   `json:"repaired"`
+}
+
+// Report is the outcome of one reconciliation.
+type Report struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Since is the start of the checked window, zero for every order.
+	Since          time.Time `json:"since"`
+	Repair         bool      `json:"repair"`
+	Stored         int       `json:"stored"`
+	Cached         int       `json:"cached"`
+	MissingInCache int       `json:"missing_in_cache"`
+	MissingInDB    int       `json:"missing_in_db"`
+	Differing      int       `json:"differing"`
+	Repaired       int       `json:"repaired"`
+	// Drifts lists up to 100 drifted orders; Truncated is set when there
+	// were more.
+	Drifts    []Drift `json:"drifts"`
+	Truncated bool    `json:"truncated"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Drifted returns the number of drifted orders.
+func (r *Report) Drifted() int {
+	return r.MissingInCache + r.MissingInDB + r.Differing
+}
+
+// Metrics are the counters of a Reconciler since it was created.
+type Metrics struct {
+	Runs           uint64    `json:"runs"`
+	Failures       uint64    `json:"failures"`
+	Repaired       uint64    `json:"repaired"`
+	LastRun        time.Time `json:"last_run"`
+	MissingInCache int       `json:"missing_in_cache"`
+	MissingInDB    int       `json:"missing_in_db"`
+	Differing      int       `json:"differing"`
+}
+
+// Reconciler checks the cache against the database. Runs are serialised.
+type Reconciler struct {
+	cache Cache
+	store Store
+	cfg   Config
+
+	run sync.Mutex
+
+	mu      sync.Mutex
+	last    *Report
+	metrics Metrics
+}
+
+func New(cache Cache, store Store, cfg Config) *Reconciler {
+	return &Reconciler{cache: cache, store: store, cfg: cfg}
+}
+
+// Run reconciles every Interval until ctx is cancelled. It returns at once
+// without an interval.
+func (r *Reconciler) Run(ctx context.Context) {
+	if r.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Failures are logged and recorded in the report
+			r.Reconcile(ctx, r.cfg.Repair)
+		}
+	}
+}
+
+// Last returns the report of the latest run, or nil before the first.
+func (r *Reconciler) Last() *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Metrics returns the counters of all runs so far.
+func (r *Reconciler) Metrics() Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.metrics
+}
+
+// Reconcile compares the cache with the database and, with repair, puts
+// stored orders into the cache and drops cached orders the database does
+// not have. Every drift is confirmed by reading the order again, so orders
+// that were being written during the comparison are not reported.
+func (r *Reconciler) Reconcile(ctx context.Context, repair bool) (*Report, error) {
+	r.run.Lock()
+	defer r.run.Unlock()
+
+	report := &Report{StartedAt: time.Now(), Repair: repair, Drifts: []Drift{}}
+	if r.cfg.Window > 0 {
+		report.Since = report.StartedAt.Add(-r.cfg.Window)
+	}
+	err := r.reconcile(ctx, report)
+	report.FinishedAt = time.Now()
+
+	r.mu.Lock()
+	r.last = report
+	r.metrics.Runs++
+	r.metrics.LastRun = report.FinishedAt
+	if err != nil {
+		report.Error = err.Error()
+		r.metrics.Failures++
+	} else {
+		r.metrics.Repaired += uint64(report.Repaired)
+		r.metrics.MissingInCache = report.MissingInCache
+		r.metrics.MissingInDB = report.MissingInDB
+		r.metrics.Differing = report.Differing
+	}
+	r.mu.Unlock()
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to reconcile cache with database", "error", err)
+		return report, err
+	}
+	if report.Drifted() > 0 {
+		slog.WarnContext(ctx, "Cache differs from database",
+			"missing_in_cache", report.MissingInCache, "missing_in_db", report.MissingInDB,
+			"differing", report.Differing, "repaired", report.Repaired)
+	} else {
+		slog.InfoContext(ctx, "Cache matches database", "orders", report.Stored)
+	}
+	return report, nil
+}
+
+func (r *Reconciler) reconcile(ctx context.Context, report *Report) error {
+	cached := make(map[string]string)
+	for _, order := range r.cache.GetAll() {
+		if !order.DateCreated.Before(report.Since) {
+			cached[order.OrderUID] = Hash(&order)
+		}
+	}
+	report.Cached = len(cached)
+
+	var suspects []string
+	err := r.store.StreamOrders(ctx, models.OrderFilter{From: report.Since}, func(order *models.Order) error {
+		report.Stored++
+		if hash, ok := cached[order.OrderUID]; !ok || hash != Hash(order) {
+			suspects = append(suspects, order.OrderUID)
+		}
+		delete(cached, order.OrderUID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read orders: %w", err)
+	}
+	for uid := range cached {
+		suspects = append(suspects, uid)
+	}
+	sort.Strings(suspects)
+
+	for _, uid := range suspects {
+		drift, stored, err := r.confirm(ctx, uid)
+		if err != nil {
+			return err
+		}
+		if drift == nil {
+			continue
+		}
+		switch drift.Problem {
+		case MissingInCache:
+			report.MissingInCache++
+		case MissingInDB:
+			report.MissingInDB++
+		case Differs:
+			report.Differing++
+		}
+		if report.Repair {
+			if stored != nil {
+				r.cache.Set(uid, stored)
+			} else {
+				r.cache.Delete(uid)
+			}
+			drift.Repaired = true
+			report.Repaired++
+		}
+		if len(report.Drifts) < maxDrifts {
+			report.Drifts = append(report.Drifts, *drift)
+		} else {
+			report.Truncated = true
+		}
+	}
+	return ctx.Err()
+}
+
+// confirm compares the current cached and stored versions of an order. It
+// returns nil if they agree, along with the stored order.
+func (r *Reconciler) confirm(ctx context.Context, uid string) (*Drift, *models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	stored, err := r.store.GetOrder(ctx, uid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read order %s: %w", uid, err)
+	}
+	cached, ok := r.cache.Get(uid)
+
+	drift := &Drift{OrderUID: uid}
+	if ok {
+		drift.CacheHash = Hash(cached)
+	}
+	if stored != nil {
+		drift.DBHash = Hash(stored)
+	}
+	switch {
+	case stored == nil && !ok:
+		return nil, nil, nil
+	case stored == nil:
+		drift.Problem = MissingInDB
+	case !ok:
+		drift.Problem = MissingInCache
+	case drift.CacheHash != drift.DBHash:
+		drift.Problem = Differs
+	default:
+		return nil, stored, nil
+	}
+	return drift, stored, nil
+}
+
+// Hash returns a hash of the order's content as the database stores it:
+// date_created is kept without a time zone and with microsecond precision,
+// and an order without items has no items row either way.
+func Hash(order *models.Order) string {
+	o := *order
+	t := o.DateCreated.Round(time.Microsecond)
+	o.DateCreated = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if len(o.Items) == 0 {
+		o.Items = nil
+	}
+
+	data, err := json.Marshal(&o)
+	if err != nil {
+		// Only a date outside years 0-9999 fails to encode
+		data = []byte(fmt.Sprintf("%+v", o))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,197 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+)
+
+type fakeStore struct {
+	orders map[string]models.Order
+	err    error
+}
+
+func (s *fakeStore) StreamOrders(_ context.Context, filter models.OrderFilter, fn func(*models.Order) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	for _, o := range s.orders {
+		if filter.Match(&o) {
+			if err := fn(&o); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) GetOrder(_ context.Context, uid string) (*models.Order, error) {
+	o, ok := s.orders[uid]
+	if !ok {
+		return nil, nil
+	}
+	return &o, nil
+}
+
+type fakeCache struct {
+	orders map[string]*models.Order
+}
+
+func (c *fakeCache) Get(uid string) (*models.Order, bool) {
+	o, ok := c.orders[uid]
+	return o, ok
+}
+
+func (c *fakeCache) GetAll() []models.Order {
+	orders := make([]models.Order, 0, len(c.orders))
+	for _, o := range c.orders {
+		orders = append(orders, *o)
+	}
+	return orders
+}
+
+func (c *fakeCache) Set(uid string, order *models.Order) { c.orders[uid] = order }
+
+func (c *fakeCache) Delete(uid string) bool {
+	_, ok := c.orders[uid]
+	delete(c.orders, uid)
+	return ok
+}
+
+func order(uid, track string, created time.Time) models.Order {
+	return models.Order{OrderUID: uid, TrackNumber: track, Entry: "WBIL", DateCreated: created}
+}
+
+// setup returns a store and a cache that agree on "same", and disagree on
+// "db_only", "cache_only" and "changed".
+func setup(now time.Time) (*fakeStore, *fakeCache) {
+	store := &fakeStore{orders: map[string]models.Order{
+		"same":    order("same", "T1", now),
+		"db_only": order("db_only", "T2", now),
+		"changed": order("changed", "T3", now),
+	}}
+	cacheOnly := order("cache_only", "T4", now)
+	changed := order("changed", "T3-edited", now)
+	same := store.orders["same"]
+	cache := &fakeCache{orders: map[string]*models.Order{
+		"same":       &same,
+		"cache_only": &cacheOnly,
+		"changed":    &changed,
+	}}
+	return store, cache
+}
+
+func TestReconcileReportsDrift(t *testing.T) {
+	store, cache := setup(time.Now())
+	r := New(cache, store, Config{})
+
+	report, err := r.Reconcile(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Stored != 3 || report.Cached != 3 {
+		t.Errorf("Expected 3 stored and 3 cached orders, got %d and %d", report.Stored, report.Cached)
+	}
+	if report.MissingInCache != 1 || report.MissingInDB != 1 || report.Differing != 1 {
+		t.Errorf("Unexpected counts %+v", report)
+	}
+	want := map[string]string{"db_only": MissingInCache, "cache_only": MissingInDB, "changed": Differs}
+	if len(report.Drifts) != len(want) {
+		t.Fatalf("Expected %d drifts, got %+v", len(want), report.Drifts)
+	}
+	for _, d := range report.Drifts {
+		if want[d.OrderUID] != d.Problem || d.Repaired {
+			t.Errorf("Unexpected drift %+v", d)
+		}
+	}
+	if _, ok := cache.orders["db_only"]; ok {
+		t.Error("Expected the cache to be left alone without repair")
+	}
+	if r.Last() != report {
+		t.Error("Expected Last to return the latest report")
+	}
+}
+
+func TestReconcileRepairsCache(t *testing.T) {
+	store, cache := setup(time.Now())
+	r := New(cache, store, Config{})
+
+	report, err := r.Reconcile(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != 3 {
+		t.Errorf("Expected 3 repaired orders, got %d", report.Repaired)
+	}
+	if _, ok := cache.orders["cache_only"]; ok {
+		t.Error("Expected the order missing in the database to be dropped")
+	}
+	if o, ok := cache.orders["changed"]; !ok || o.TrackNumber != "T3" {
+		t.Errorf("Expected the stored version of the changed order, got %+v", o)
+	}
+
+	report, err = r.Reconcile(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted() != 0 {
+		t.Errorf("Expected no drift after repair, got %+v", report.Drifts)
+	}
+	if m := r.Metrics(); m.Runs != 2 || m.Repaired != 3 || m.Differing != 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+}
+
+func TestReconcileWindow(t *testing.T) {
+	now := time.Now()
+	store, cache := setup(now)
+	old := order("old", "T5", now.Add(-48*time.Hour))
+	store.orders["old"] = old
+	r := New(cache, store, Config{Window: 24 * time.Hour})
+
+	report, err := r.Reconcile(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MissingInCache != 1 {
+		t.Errorf("Expected orders outside the window to be skipped, got %+v", report.Drifts)
+	}
+}
+
+func TestReconcileFailure(t *testing.T) {
+	store, cache := setup(time.Now())
+	store.err = errors.New("connection refused")
+	r := New(cache, store, Config{})
+
+	report, err := r.Reconcile(context.Background(), true)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if report.Error == "" || r.Metrics().Failures != 1 {
+		t.Errorf("Expected the failure to be recorded, got %+v", report)
+	}
+}
+
+func TestHashMatchesStoredForm(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	received := order("a", "T", time.Date(2024, 3, 1, 10, 30, 0, 123456789, msk))
+	received.Items = []models.Item{}
+
+	// date_created is TIMESTAMP: the wall clock is kept, rounded to
+	// microseconds, and read back as UTC
+	stored := order("a", "T", time.Date(2024, 3, 1, 10, 30, 0, 123457000, time.UTC))
+	stored.Delivery.ID, stored.Payment.ID = 7, 9
+
+	if Hash(&received) != Hash(&stored) {
+		t.Error("Expected a received order and its stored form to hash equally")
+	}
+
+	stored.Payment.Amount = money.New(100, "USD")
+	if Hash(&received) == Hash(&stored) {
+		t.Error("Expected different content to hash differently")
+	}
+}
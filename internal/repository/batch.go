@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// orderColumns is the number of orders columns written by SaveOrders.
+// PostgreSQL accepts at most 65535 parameters per statement.
+const orderColumns = 11
+
+// MaxBatchSize is the largest number of orders SaveOrders accepts.
+const MaxBatchSize = 65535 / orderColumns
+
+// SaveOrders stores orders in a single transaction: the orders with one
+// multi-row INSERT and their delivery, payment and items with COPY. Like
+// SaveOrder, orders that are already stored are left untouched and produce
+// no events. Either every order is stored or, on error, none is.
+//
+// written reports for each order whether this call stored it. It is false
+// for orders that were already stored and for later copies of an order
+// that occurs more than once in the batch.
+func (r *OrderRepository) SaveOrders(ctx context.Context, orders []*models.Order) (written []bool, err error) {
+	if len(orders) == 0 {
+		return nil, nil
+	}
+	if len(orders) > MaxBatchSize {
+		return nil, fmt.Errorf("batch of %d orders exceeds the maximum of %d", len(orders), MaxBatchSize)
+	}
+
+	ctx, span := tracer.Start(ctx, "SaveOrders", trace.WithAttributes(attribute.Int("batch.size", len(orders))))
+	defer func() { tracing.End(span, err) }()
+
+	dates := make([]time.Time, len(orders))
+	for i, order := range orders {
+		dates[i] = order.DateCreated
+	}
+	err = r.withPartitions(ctx, dates, func() error {
+		written, err = r.saveOrders(ctx, orders)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return written, nil
+}
+
+func (r *OrderRepository) saveOrders(ctx context.Context, orders []*models.Order) ([]bool, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	claimed, err := insertOrderKeys(ctx, tx, orders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert order keys: %w", err)
+	}
+
+	// A batch may carry the same order twice; only its first copy is new.
+	var fresh []*models.Order
+	written := make([]bool, len(orders))
+	for i, order := range orders {
+		if claimed[order.OrderUID] {
+			fresh = append(fresh, order)
+			written[i] = true
+			delete(claimed, order.OrderUID)
+		}
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("batch.inserted", len(fresh)))
+	if len(fresh) == 0 {
+		if err := commitPosition(ctx, tx, orders); err != nil {
+			return nil, err
+		}
+		return written, nil
+	}
+
+	if err = insertOrders(ctx, tx, fresh); err != nil {
+		return nil, fmt.Errorf("failed to insert orders: %w", err)
+	}
+
+	err = copyRows(ctx, tx, "delivery", []string{"order_uid", "date_created", "name", "phone", "zip", "city", "address", "region", "email"},
+		fresh, func(o *models.Order, add func(...interface{}) error) error {
+			d := o.Delivery
+			return add(o.OrderUID, o.DateCreated, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email)
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy delivery: %w", err)
+	}
+
+	err = copyRows(ctx, tx, "payment", []string{"order_uid", "date_created", "transaction", "request_id", "currency", "provider",
+		"amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"},
+		fresh, func(o *models.Order, add func(...interface{}) error) error {
+			p := o.Payment
+			return add(o.OrderUID, o.DateCreated, p.Transaction, p.RequestID, p.Currency, p.Provider,
+				p.Amount, p.PaymentDt, p.Bank, p.DeliveryCost, p.GoodsTotal, p.CustomFee)
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy payment: %w", err)
+	}
+
+	err = copyRows(ctx, tx, "items", []string{"order_uid", "date_created", "chrt_id", "track_number", "price", "rid", "name",
+		"sale", "size", "total_price", "nm_id", "brand", "status"},
+		fresh, func(o *models.Order, add func(...interface{}) error) error {
+			for _, item := range o.Items {
+				err := add(o.OrderUID, o.DateCreated, item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name,
+					item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy items: %w", err)
+	}
+
+	for _, order := range fresh {
+		if err = announceOrder(ctx, tx, order); err != nil {
+			return nil, err
+		}
+	}
+	if err = savePosition(ctx, tx, orders); err != nil {
+		return nil, err
+	}
+
+	err = traced(ctx, "COMMIT", "COMMIT", func(context.Context) error {
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return written, nil
+}
+
+// insertOrderKeys claims the UIDs of orders and returns those that were not
+// stored before.
+func insertOrderKeys(ctx context.Context, tx *sqlx.Tx, orders []*models.Order) (map[string]bool, error) {
+	args := make([]interface{}, 0, len(orders)*2)
+	for _, o := range orders {
+		args = append(args, o.OrderUID, o.DateCreated)
+	}
+	query := `
+		INSERT INTO order_keys (order_uid, date_created)
+		VALUES ` + valuesClause(len(orders), 2) + `
+		ON CONFLICT (order_uid) DO NOTHING
+		RETURNING order_uid
+	`
+
+	claimed := make(map[string]bool, len(orders))
+	err := traced(ctx, "INSERT order_keys", query, func(ctx context.Context) error {
+		var uids []string
+		if err := tx.SelectContext(ctx, &uids, query, args...); err != nil {
+			return err
+		}
+		for _, uid := range uids {
+			claimed[uid] = true
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// insertOrders inserts the orders rows with one statement.
+func insertOrders(ctx context.Context, tx *sqlx.Tx, orders []*models.Order) error {
+	args := make([]interface{}, 0, len(orders)*orderColumns)
+	for _, o := range orders {
+		args = append(args, o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+			o.CustomerID, o.DeliveryService, o.Shardkey, o.SmID, o.DateCreated, o.OofShard)
+	}
+	query := `
+		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+		VALUES ` + valuesClause(len(orders), orderColumns)
+
+	return traced(ctx, "INSERT orders", query, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+// valuesClause returns the placeholders of a multi-row VALUES list, e.g.
+// "($1, $2), ($3, $4)" for two rows of two columns.
+func valuesClause(rows, columns int) string {
+	var b strings.Builder
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j := 0; j < columns; j++ {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(i*columns + j + 1))
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// copyRows streams the rows produced by rows for each order into table with
+// COPY FROM STDIN.
+func copyRows(ctx context.Context, tx *sqlx.Tx, table string, columns []string, orders []*models.Order,
+	rows func(o *models.Order, add func(...interface{}) error) error) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	query := pq.CopyIn(table, columns...)
+	return traced(ctx, "COPY "+table, query, func(ctx context.Context) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		add := func(values ...interface{}) error {
+			_, err := stmt.ExecContext(ctx, values...)
+			return err
+		}
+		for _, o := range orders {
+			if err := rows(o, add); err != nil {
+				return err
+			}
+		}
+		// An Exec without arguments flushes the buffered rows.
+		_, err = stmt.ExecContext(ctx)
+		return err
+	})
+}
+
+// StoredOrders returns which of the given orders are already stored.
+func (r *OrderRepository) StoredOrders(ctx context.Context, orderUIDs []string) (map[string]bool, error) {
+	stored := make(map[string]bool)
+	if len(orderUIDs) == 0 {
+		return stored, nil
+	}
+	query := `SELECT order_uid FROM order_keys WHERE order_uid = ANY($1)`
+	err := traced(ctx, "SELECT order_keys", query, func(ctx context.Context) error {
+		var uids []string
+		if err := r.db.SelectContext(ctx, &uids, query, pq.Array(orderUIDs)); err != nil {
+			return err
+		}
+		for _, uid := range uids {
+			stored[uid] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up stored orders: %w", err)
+	}
+	return stored, nil
+}
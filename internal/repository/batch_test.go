@@ -0,0 +1,12 @@
+package repository
+
+import "testing"
+
+func TestValuesClause(t *testing.T) {
+	if got, want := valuesClause(2, 3), "($1, $2, $3), ($4, $5, $6)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got, want := valuesClause(1, 1), "($1)"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
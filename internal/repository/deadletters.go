@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"order-service/internal/models"
+)
+
+// SaveDeadLetter records a message that cannot be processed, along with
+// the consumer position carried by ctx.
+func (r *OrderRepository) SaveDeadLetter(ctx context.Context, letter models.DeadLetter) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO dead_letters (subject, sequence, data, error)
+		VALUES ($1, $2, $3, $4)
+	`
+	err = traced(ctx, "INSERT dead_letters", query, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, query, letter.Subject, int64(letter.Sequence), letter.Data, letter.Error)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save dead letter: %w", err)
+	}
+	if err := savePosition(ctx, tx, nil); err != nil {
+		return err
+	}
+
+	err = traced(ctx, "COMMIT", "COMMIT", func(context.Context) error {
+		return tx.Commit()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// DeadLetters returns up to limit dead letters, oldest first. Replayed
+// letters are only included if all is set.
+func (r *OrderRepository) DeadLetters(ctx context.Context, all bool, limit int) ([]models.DeadLetter, error) {
+	var letters []models.DeadLetter
+	query := `
+		SELECT id, subject, sequence, data, error, created_at, replayed_at
+		FROM dead_letters WHERE $1 OR replayed_at IS NULL ORDER BY id LIMIT $2
+	`
+	err := traced(ctx, "SELECT dead_letters", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &letters, query, all, limit)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letters: %w", err)
+	}
+	return letters, nil
+}
+
+// GetDeadLetter returns the dead letter with the given id, or nil if there
+// is none.
+func (r *OrderRepository) GetDeadLetter(ctx context.Context, id int64) (*models.DeadLetter, error) {
+	var letter models.DeadLetter
+	query := `
+		SELECT id, subject, sequence, data, error, created_at, replayed_at
+		FROM dead_letters WHERE id = $1
+	`
+	err := traced(ctx, "SELECT dead_letters", query, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &letter, query, id)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+	return &letter, nil
+}
+
+// MarkDeadLetterReplayed records that a dead letter was published again.
+func (r *OrderRepository) MarkDeadLetterReplayed(ctx context.Context, id int64) error {
+	query := `UPDATE dead_letters SET replayed_at = now() WHERE id = $1`
+	err := traced(ctx, "UPDATE dead_letters", query, func(ctx context.Context) error {
+		_, err := r.db.ExecContext(ctx, query, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark dead letter replayed: %w", err)
+	}
+	return nil
+}
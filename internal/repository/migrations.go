@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AppliedMigrations returns the applied migrations and when they were
+// applied, by file name without extension.
+func (r *OrderRepository) AppliedMigrations(ctx context.Context) (map[string]time.Time, error) {
+	var rows []struct {
+		Version   string    `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	query := `SELECT version, applied_at FROM schema_migrations`
+	err := traced(ctx, "SELECT schema_migrations", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &rows, query)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	applied := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row.AppliedAt
+	}
+	return applied, nil
+}
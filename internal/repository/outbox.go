@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/tracing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Outbox event types.
+const (
+	EventOrderPersisted = "order.persisted"
+)
+
+// enqueueEvent records an outbox event within tx, so that it is published
+// if and only if the change it describes is committed. Every method that
+// changes or deletes an order must call it before committing. Retention
+// dropping archived months is not such a change and records no events.
+func enqueueEvent(ctx context.Context, tx *sqlx.Tx, orderUID, eventType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox payload: %w", err)
+	}
+	var headers []byte
+	if h := tracing.Inject(ctx); h != nil {
+		if headers, err = json.Marshal(h); err != nil {
+			return fmt.Errorf("failed to encode outbox headers: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO outbox (order_uid, event_type, headers, payload)
+		VALUES ($1, $2, $3, $4)
+	`
+	return traced(ctx, "INSERT outbox", query, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, query, orderUID, eventType, headers, body)
+		return err
+	})
+}
+
+// announceOrder enqueues the outbox event and webhook deliveries of a newly
+// stored order within tx. Replayed orders were announced when they were
+// first stored, so they are skipped.
+func announceOrder(ctx context.Context, tx *sqlx.Tx, order *models.Order) error {
+	if models.IsReplay(ctx) {
+		return nil
+	}
+	if err := enqueueEvent(ctx, tx, order.OrderUID, EventOrderPersisted, order); err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+	if err := enqueueWebhooks(ctx, tx, models.EventOrderCreated, order); err != nil {
+		return fmt.Errorf("failed to enqueue webhooks: %w", err)
+	}
+	return nil
+}
+
+// PendingEvents returns up to limit unsent outbox events that are not dead,
+// oldest first.
+func (r *OrderRepository) PendingEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	query := `
+		SELECT id, order_uid, event_type, headers, payload, created_at, attempts
+		FROM outbox WHERE sent_at IS NULL AND dead_at IS NULL ORDER BY id LIMIT $1
+	`
+	err := traced(ctx, "SELECT outbox", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &events, query, limit)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending outbox events: %w", err)
+	}
+	return events, nil
+}
+
+// MarkEventsSent records that the events were published.
+func (r *OrderRepository) MarkEventsSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `UPDATE outbox SET sent_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = ANY($1)`
+	err := traced(ctx, "UPDATE outbox", query, func(ctx context.Context) error {
+		_, err := r.db.ExecContext(ctx, query, pq.Array(ids))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox events sent: %w", err)
+	}
+	return nil
+}
+
+// MarkEventFailed records a failed attempt to publish an event. A dead
+// event is not returned by PendingEvents again.
+func (r *OrderRepository) MarkEventFailed(ctx context.Context, id int64, reason string, dead bool) error {
+	query := `
+		UPDATE outbox SET attempts = attempts + 1, last_error = $2,
+			dead_at = CASE WHEN $3 THEN now() END
+		WHERE id = $1
+	`
+	err := traced(ctx, "UPDATE outbox", query, func(ctx context.Context) error {
+		_, err := r.db.ExecContext(ctx, query, id, reason, dead)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+	return nil
+}
+
+// OutboxBacklog returns the number of pending events and the creation time
+// of the oldest one, which is zero when there are none.
+func (r *OrderRepository) OutboxBacklog(ctx context.Context) (int, time.Time, error) {
+	var row struct {
+		Pending int          `db:"pending"`
+		Oldest  sql.NullTime `db:"oldest"`
+	}
+	query := `SELECT count(*) AS pending, min(created_at) AS oldest FROM outbox WHERE sent_at IS NULL AND dead_at IS NULL`
+	err := traced(ctx, "SELECT outbox", query, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &row, query)
+	})
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to get outbox backlog: %w", err)
+	}
+	return row.Pending, row.Oldest.Time, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Month returns the first day of the month containing t, taken from t's
+// wall clock. date_created is stored without a time zone, so that is the
+// month whose partition holds t.
+func Month(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// EnsurePartitions creates the partitions of orders and its child tables
+// for the month containing day, if they do not exist yet.
+func (r *OrderRepository) EnsurePartitions(ctx context.Context, day time.Time) error {
+	month := Month(day)
+	query := `SELECT ensure_order_partitions($1::date)`
+	err := traced(ctx, "SELECT ensure_order_partitions", query, func(ctx context.Context) error {
+		_, err := r.db.ExecContext(ctx, query, month.Format(time.DateOnly))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create partitions for %s: %w", month.Format("2006-01"), err)
+	}
+
+	r.partitionsMu.Lock()
+	r.partitions[month] = true
+	r.partitionsMu.Unlock()
+	return nil
+}
+
+// ensurePartitions creates the partitions for every month of dates that
+// this repository has not seen yet.
+func (r *OrderRepository) ensurePartitions(ctx context.Context, dates ...time.Time) error {
+	var missing []time.Time
+	r.partitionsMu.Lock()
+	for _, d := range dates {
+		if month := Month(d); !r.partitions[month] {
+			missing = append(missing, month)
+		}
+	}
+	r.partitionsMu.Unlock()
+
+	for _, month := range missing {
+		if err := r.EnsurePartitions(ctx, month); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withPartitions runs save after making sure the partitions for dates
+// exist. If save fails because another instance dropped a partition in the
+// meantime, the partitions are created again and save is retried once.
+func (r *OrderRepository) withPartitions(ctx context.Context, dates []time.Time, save func() error) error {
+	if err := r.ensurePartitions(ctx, dates...); err != nil {
+		return err
+	}
+	err := save()
+	if !isMissingPartition(err) {
+		return err
+	}
+
+	r.partitionsMu.Lock()
+	clear(r.partitions)
+	r.partitionsMu.Unlock()
+	if err := r.ensurePartitions(ctx, dates...); err != nil {
+		return err
+	}
+	return save()
+}
+
+// isMissingPartition reports whether err is PostgreSQL's "no partition of
+// relation found for row".
+func isMissingPartition(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23514" && strings.Contains(pqErr.Message, "no partition")
+}
+
+// OrderPartitions returns the months that have an orders partition, oldest
+// first.
+func (r *OrderRepository) OrderPartitions(ctx context.Context) ([]time.Time, error) {
+	var names []string
+	query := `
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'orders'::regclass
+	`
+	err := traced(ctx, "SELECT pg_inherits", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &names, query)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list order partitions: %w", err)
+	}
+
+	months := make([]time.Time, 0, len(names))
+	for _, name := range names {
+		month, err := time.Parse("orders_2006_01", name)
+		if err != nil {
+			continue
+		}
+		months = append(months, month)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months, nil
+}
+
+// DropPartition drops the partitions of the month containing day from
+// orders and its child tables. It fails without dropping anything unless
+// the month holds exactly expected orders.
+func (r *OrderRepository) DropPartition(ctx context.Context, day time.Time, expected int) error {
+	month := Month(day)
+	query := `SELECT drop_order_partitions($1::date, $2)`
+	err := traced(ctx, "SELECT drop_order_partitions", query, func(ctx context.Context) error {
+		_, err := r.db.ExecContext(ctx, query, month.Format(time.DateOnly), expected)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drop partitions for %s: %w", month.Format("2006-01"), err)
+	}
+
+	r.partitionsMu.Lock()
+	delete(r.partitions, month)
+	r.partitionsMu.Unlock()
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"order-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ConsumerPosition returns the stored position of the subscriber of
+// subject, zero if it has none.
+func (r *OrderRepository) ConsumerPosition(ctx context.Context, subject string) (uint64, error) {
+	query := `SELECT sequence FROM consumer_positions WHERE subject = $1`
+	var sequence int64
+	err := traced(ctx, "SELECT consumer_positions", query, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &sequence, query, subject)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get consumer position: %w", err)
+	}
+	return uint64(sequence), nil
+}
+
+// ConsumerPositions returns the stored positions of every subject.
+func (r *OrderRepository) ConsumerPositions(ctx context.Context) ([]models.ConsumerPosition, error) {
+	query := `SELECT subject, sequence, updated_at FROM consumer_positions ORDER BY subject`
+	var positions []models.ConsumerPosition
+	err := traced(ctx, "SELECT consumer_positions", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &positions, query)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer positions: %w", err)
+	}
+	return positions, nil
+}
+
+// savePosition stores the consumer position carried by ctx, if any, as part
+// of tx. orders are those tx writes. The position never moves back, as
+// transactions of concurrent workers commit in any order.
+func savePosition(ctx context.Context, tx *sqlx.Tx, orders []*models.Order) error {
+	fn, ok := models.PositionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	pos := fn(orders)
+	if pos.Sequence == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO consumer_positions (subject, sequence, updated_at) VALUES ($1, $2, now())
+		ON CONFLICT (subject) DO UPDATE
+		SET sequence = GREATEST(consumer_positions.sequence, EXCLUDED.sequence), updated_at = now()
+	`
+	err := traced(ctx, "UPSERT consumer_positions", query, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, query, pos.Subject, int64(pos.Sequence))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save consumer position: %w", err)
+	}
+	return nil
+}
+
+// commitPosition commits tx for orders that were all stored before, so
+// only the consumer position changes. Without a position there is nothing
+// to commit.
+func commitPosition(ctx context.Context, tx *sqlx.Tx, orders []*models.Order) error {
+	if _, ok := models.PositionFromContext(ctx); !ok {
+		return nil
+	}
+	if err := savePosition(ctx, tx, orders); err != nil {
+		return err
+	}
+	err := traced(ctx, "COMMIT", "COMMIT", func(context.Context) error {
+		return tx.Commit()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -3,20 +3,48 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"order-service/internal/models"
+	"order-service/internal/tracing"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = tracing.Tracer("repository")
+
+// traced runs a single SQL statement within its own client span.
+func traced(ctx context.Context, name, query string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.statement", strings.TrimSpace(query)),
+	))
+	err := fn(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
+	return err
+}
+
 type OrderRepository struct {
 	db *sqlx.DB
+
+	// partitions holds the months whose partitions are known to exist.
+	partitionsMu sync.Mutex
+	partitions   map[time.Time]bool
 }
 
 func NewOrderRepository(db *sqlx.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+	return &OrderRepository{db: db, partitions: make(map[time.Time]bool)}
 }
 
 func NewPostgresDB(host, port, user, password, dbname string) (*sqlx.DB, error) {
@@ -34,79 +62,142 @@ func NewPostgresDB(host, port, user, password, dbname string) (*sqlx.DB, error)
 	return db, nil
 }
 
-func (r *OrderRepository) SaveOrder(ctx context.Context, order *models.Order) error {
+// SaveOrder stores order in a single transaction. Orders that are already
+// stored, for example because a message was redelivered, are left
+// untouched, produce no events and return models.ErrAlreadyStored.
+func (r *OrderRepository) SaveOrder(ctx context.Context, order *models.Order) (err error) {
+	ctx, span := tracer.Start(ctx, "SaveOrder", trace.WithAttributes(attribute.String("order.uid", order.OrderUID)))
+	defer func() {
+		if errors.Is(err, models.ErrAlreadyStored) {
+			span.SetAttributes(attribute.Bool("order.existing", true))
+			span.End()
+			return
+		}
+		tracing.End(span, err)
+	}()
+
+	return r.withPartitions(ctx, []time.Time{order.DateCreated}, func() error {
+		return r.saveOrder(ctx, order)
+	})
+}
+
+func (r *OrderRepository) saveOrder(ctx context.Context, order *models.Order) (err error) {
 	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	// Claim the order UID, which is unique across partitions
+	keyQuery := `
+		INSERT INTO order_keys (order_uid, date_created) VALUES ($1, $2)
+		ON CONFLICT (order_uid) DO NOTHING
+	`
+	var inserted int64
+	err = traced(ctx, "INSERT order_keys", keyQuery, func(ctx context.Context) error {
+		res, err := tx.ExecContext(ctx, keyQuery, order.OrderUID, order.DateCreated)
+		if err != nil {
+			return err
+		}
+		inserted, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert order key: %w", err)
+	}
+	if inserted == 0 {
+		// A redelivered message still moves the consumer position
+		if err := commitPosition(ctx, tx, []*models.Order{order}); err != nil {
+			return err
+		}
+		return models.ErrAlreadyStored
+	}
+
 	// Insert order
 	orderQuery := `
 		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
 			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (order_uid) DO NOTHING
 	`
-	_, err = tx.ExecContext(ctx, orderQuery,
-		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
-		order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
-	)
+	err = traced(ctx, "INSERT orders", orderQuery, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, orderQuery,
+			order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
+			order.InternalSignature, order.CustomerID, order.DeliveryService,
+			order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
+		)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to insert order: %w", err)
 	}
 
 	// Insert delivery
 	deliveryQuery := `
-		INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (order_uid) DO NOTHING
+		INSERT INTO delivery (order_uid, date_created, name, phone, zip, city, address, region, email)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`
-	_, err = tx.ExecContext(ctx, deliveryQuery,
-		order.OrderUID, order.Delivery.Name, order.Delivery.Phone,
-		order.Delivery.Zip, order.Delivery.City, order.Delivery.Address,
-		order.Delivery.Region, order.Delivery.Email,
-	)
+	err = traced(ctx, "INSERT delivery", deliveryQuery, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, deliveryQuery,
+			order.OrderUID, order.DateCreated, order.Delivery.Name, order.Delivery.Phone,
+			order.Delivery.Zip, order.Delivery.City, order.Delivery.Address,
+			order.Delivery.Region, order.Delivery.Email,
+		)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to insert delivery: %w", err)
 	}
 
 	// Insert payment
 	paymentQuery := `
-		INSERT INTO payment (order_uid, transaction, request_id, currency, provider,
+		INSERT INTO payment (order_uid, date_created, transaction, request_id, currency, provider,
 			amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		ON CONFLICT (order_uid) DO NOTHING
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
-	_, err = tx.ExecContext(ctx, paymentQuery,
-		order.OrderUID, order.Payment.Transaction, order.Payment.RequestID,
-		order.Payment.Currency, order.Payment.Provider, order.Payment.Amount,
-		order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost,
-		order.Payment.GoodsTotal, order.Payment.CustomFee,
-	)
+	err = traced(ctx, "INSERT payment", paymentQuery, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, paymentQuery,
+			order.OrderUID, order.DateCreated, order.Payment.Transaction, order.Payment.RequestID,
+			order.Payment.Currency, order.Payment.Provider, order.Payment.Amount,
+			order.Payment.PaymentDt, order.Payment.Bank, order.Payment.DeliveryCost,
+			order.Payment.GoodsTotal, order.Payment.CustomFee,
+		)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to insert payment: %w", err)
 	}
 
 	// Insert items
 	itemQuery := `
-		INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name,
+		INSERT INTO items (order_uid, date_created, chrt_id, track_number, price, rid, name,
 			sale, size, total_price, nm_id, brand, status)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 	for _, item := range order.Items {
-		_, err = tx.ExecContext(ctx, itemQuery,
-			order.OrderUID, item.ChrtID, item.TrackNumber, item.Price,
-			item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice,
-			item.NmID, item.Brand, item.Status,
-		)
+		err = traced(ctx, "INSERT items", itemQuery, func(ctx context.Context) error {
+			_, err := tx.ExecContext(ctx, itemQuery,
+				order.OrderUID, order.DateCreated, item.ChrtID, item.TrackNumber, item.Price,
+				item.Rid, item.Name, item.Sale, item.Size, item.TotalPrice,
+				item.NmID, item.Brand, item.Status,
+			)
+			return err
+		})
 		if err != nil {
 			return fmt.Errorf("failed to insert item: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err = announceOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	if err = savePosition(ctx, tx, []*models.Order{order}); err != nil {
+		return err
+	}
+
+	err = traced(ctx, "COMMIT", "COMMIT", func(context.Context) error {
+		return tx.Commit()
+	})
+	if err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
@@ -116,9 +207,25 @@ func (r *OrderRepository) SaveOrder(ctx context.Context, order *models.Order) er
 func (r *OrderRepository) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
 	var order models.Order
 
+	// The creation date from order_keys selects the partition of every
+	// table below; without it each query would scan all partitions
+	var created time.Time
+	keyQuery := `SELECT date_created FROM order_keys WHERE order_uid = $1`
+	err := traced(ctx, "SELECT order_keys", keyQuery, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &created, keyQuery, orderUID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get order key: %w", err)
+	}
+
 	// Get order
-	orderQuery := `SELECT * FROM orders WHERE order_uid = $1`
-	err := r.db.GetContext(ctx, &order, orderQuery, orderUID)
+	orderQuery := `SELECT * FROM orders WHERE order_uid = $1 AND date_created = $2`
+	err = traced(ctx, "SELECT orders", orderQuery, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &order, orderQuery, orderUID, created)
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -127,47 +234,62 @@ func (r *OrderRepository) GetOrder(ctx context.Context, orderUID string) (*model
 	}
 
 	// Get delivery
-	deliveryQuery := `SELECT * FROM delivery WHERE order_uid = $1`
-	err = r.db.GetContext(ctx, &order.Delivery, deliveryQuery, orderUID)
+	deliveryQuery := `
+		SELECT id, order_uid, name, phone, zip, city, address, region, email
+		FROM delivery WHERE order_uid = $1 AND date_created = $2
+	`
+	err = traced(ctx, "SELECT delivery", deliveryQuery, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &order.Delivery, deliveryQuery, orderUID, created)
+	})
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to get delivery: %w", err)
 	}
 
 	// Get payment
-	paymentQuery := `SELECT * FROM payment WHERE order_uid = $1`
-	err = r.db.GetContext(ctx, &order.Payment, paymentQuery, orderUID)
+	paymentQuery := `
+		SELECT id, order_uid, transaction, request_id, currency, provider, amount,
+			payment_dt, bank, delivery_cost, goods_total, custom_fee
+		FROM payment WHERE order_uid = $1 AND date_created = $2
+	`
+	err = traced(ctx, "SELECT payment", paymentQuery, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &order.Payment, paymentQuery, orderUID, created)
+	})
 	if err != nil && err != sql.ErrNoRows {
 		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
 
 	// Get items
-	itemsQuery := `SELECT * FROM items WHERE order_uid = $1`
-	err = r.db.SelectContext(ctx, &order.Items, itemsQuery, orderUID)
+	itemsQuery := `
+		SELECT id, order_uid, chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		FROM items WHERE order_uid = $1 AND date_created = $2 ORDER BY id
+	`
+	err = traced(ctx, "SELECT items", itemsQuery, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &order.Items, itemsQuery, orderUID, created)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get items: %w", err)
 	}
 
+	order.Denominate()
 	return &order, nil
 }
 
 func (r *OrderRepository) GetAllOrders(ctx context.Context) ([]models.Order, error) {
-	var orderUIDs []string
-	query := `SELECT order_uid FROM orders ORDER BY date_created DESC`
-	err := r.db.SelectContext(ctx, &orderUIDs, query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get order UIDs: %w", err)
-	}
+	return r.GetOrdersSince(ctx, time.Time{})
+}
 
-	orders := make([]models.Order, 0, len(orderUIDs))
-	for _, uid := range orderUIDs {
-		order, err := r.GetOrder(ctx, uid)
-		if err != nil {
-			return nil, err
-		}
-		if order != nil {
-			orders = append(orders, *order)
-		}
+// GetOrdersSince returns the orders created at or after since, newest
+// first. Partitions of earlier months are not read. A zero since returns
+// every order.
+func (r *OrderRepository) GetOrdersSince(ctx context.Context, since time.Time) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.StreamOrders(ctx, models.OrderFilter{From: since}, func(order *models.Order) error {
+		orders = append(orders, *order)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
-
 	return orders, nil
 }
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"order-service/internal/models"
+)
+
+// streamBatchSize is the number of rows fetched from the cursor at a time.
+const streamBatchSize = 500
+
+// filterClause renders f as a WHERE clause over the orders (o) and payment
+// (p) tables, returning the clause and its arguments.
+func filterClause(f models.OrderFilter) (string, []interface{}) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.CustomerID != "" {
+		add("o.customer_id = $%d", f.CustomerID)
+	}
+	if f.DeliveryService != "" {
+		add("o.delivery_service = $%d", f.DeliveryService)
+	}
+	if f.Entry != "" {
+		add("o.entry = $%d", f.Entry)
+	}
+	if f.Currency != "" {
+		add("upper(p.currency) = upper($%d)", f.Currency)
+	}
+	if !f.From.IsZero() {
+		add("o.date_created >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("o.date_created < $%d", f.To)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// StreamOrders reads every order matching filter through a server-side
+// cursor, newest first, and passes each one to fn. Memory use is bounded by
+// the batch size regardless of the number of orders. Cancelling ctx or
+// returning an error from fn stops the stream.
+func (r *OrderRepository) StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(*models.Order) error) error {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := filterClause(filter)
+	cursorQuery := `
+		DECLARE order_export NO SCROLL CURSOR FOR
+		SELECT o.order_uid, o.track_number, o.entry, COALESCE(o.locale, ''),
+			COALESCE(o.internal_signature, ''), COALESCE(o.customer_id, ''),
+			COALESCE(o.delivery_service, ''), COALESCE(o.shardkey, ''),
+			COALESCE(o.sm_id, 0), o.date_created, COALESCE(o.oof_shard, ''),
+			COALESCE(d.name, ''), COALESCE(d.phone, ''), COALESCE(d.zip, ''),
+			COALESCE(d.city, ''), COALESCE(d.address, ''), COALESCE(d.region, ''),
+			COALESCE(d.email, ''),
+			COALESCE(p.transaction, ''), COALESCE(p.request_id, ''),
+			COALESCE(p.currency, ''), COALESCE(p.provider, ''), COALESCE(p.amount, 0),
+			COALESCE(p.payment_dt, 0), COALESCE(p.bank, ''), COALESCE(p.delivery_cost, 0),
+			COALESCE(p.goods_total, 0), COALESCE(p.custom_fee, 0),
+			i.id, COALESCE(i.chrt_id, 0), COALESCE(i.track_number, ''),
+			COALESCE(i.price, 0), COALESCE(i.rid, ''), COALESCE(i.name, ''),
+			COALESCE(i.sale, 0), COALESCE(i.size, ''), COALESCE(i.total_price, 0),
+			COALESCE(i.nm_id, 0), COALESCE(i.brand, ''), COALESCE(i.status, 0)
+		FROM orders o
+		LEFT JOIN delivery d ON d.order_uid = o.order_uid AND d.date_created = o.date_created
+		LEFT JOIN payment p ON p.order_uid = o.order_uid AND p.date_created = o.date_created
+		LEFT JOIN items i ON i.order_uid = o.order_uid AND i.date_created = o.date_created
+		` + where + `
+		ORDER BY o.date_created DESC, o.order_uid, i.id
+	`
+	err = traced(ctx, "DECLARE order_export", cursorQuery, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, cursorQuery, args...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open export cursor: %w", err)
+	}
+
+	var current *models.Order
+	for {
+		fetchQuery := fmt.Sprintf("FETCH FORWARD %d FROM order_export", streamBatchSize)
+		var rows *sql.Rows
+		err := traced(ctx, "FETCH order_export", fetchQuery, func(ctx context.Context) (err error) {
+			rows, err = tx.QueryContext(ctx, fetchQuery)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to fetch from export cursor: %w", err)
+		}
+
+		fetched := 0
+		for rows.Next() {
+			fetched++
+
+			var (
+				o      models.Order
+				item   models.Item
+				itemID sql.NullInt64
+			)
+			err := rows.Scan(
+				&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale,
+				&o.InternalSignature, &o.CustomerID, &o.DeliveryService, &o.Shardkey,
+				&o.SmID, &o.DateCreated, &o.OofShard,
+				&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip,
+				&o.Delivery.City, &o.Delivery.Address, &o.Delivery.Region,
+				&o.Delivery.Email,
+				&o.Payment.Transaction, &o.Payment.RequestID,
+				&o.Payment.Currency, &o.Payment.Provider, &o.Payment.Amount,
+				&o.Payment.PaymentDt, &o.Payment.Bank, &o.Payment.DeliveryCost,
+				&o.Payment.GoodsTotal, &o.Payment.CustomFee,
+				&itemID, &item.ChrtID, &item.TrackNumber,
+				&item.Price, &item.Rid, &item.Name,
+				&item.Sale, &item.Size, &item.TotalPrice,
+				&item.NmID, &item.Brand, &item.Status,
+			)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan order row: %w", err)
+			}
+
+			if current == nil || current.OrderUID != o.OrderUID {
+				if current != nil {
+					current.Denominate()
+					if err := fn(current); err != nil {
+						rows.Close()
+						return err
+					}
+				}
+				o.Items = []models.Item{}
+				current = &o
+			}
+			if itemID.Valid {
+				item.ID = itemID.Int64
+				item.OrderID = o.OrderUID
+				current.Items = append(current.Items, item)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read export cursor: %w", err)
+		}
+		rows.Close()
+
+		if fetched < streamBatchSize {
+			break
+		}
+	}
+
+	if current != nil {
+		current.Denominate()
+		return fn(current)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+func TestFilterClause(t *testing.T) {
+	where, args := filterClause(models.OrderFilter{})
+	if where != "" || len(args) != 0 {
+		t.Errorf("Expected empty clause, got %q %v", where, args)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	where, args = filterClause(models.OrderFilter{
+		DeliveryService: "meest",
+		Currency:        "usd",
+		From:            from,
+	})
+
+	want := "WHERE o.delivery_service = $1 AND upper(p.currency) = upper($2) AND o.date_created >= $3"
+	if where != want {
+		t.Errorf("Expected %q, got %q", want, where)
+	}
+	if len(args) != 3 || args[0] != "meest" || args[1] != "usd" || args[2] != from {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"order-service/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// subscriptionRow scans event_types, which sqlx cannot map to []string.
+type subscriptionRow struct {
+	models.WebhookSubscription
+	EventTypes pq.StringArray `db:"event_types"`
+}
+
+func (r subscriptionRow) subscription() models.WebhookSubscription {
+	s := r.WebhookSubscription
+	s.EventTypes = []string(r.EventTypes)
+	return s
+}
+
+// enqueueWebhooks creates a delivery of the event for every subscription
+// matching it within tx. Every method that changes an order must call it
+// before committing.
+func enqueueWebhooks(ctx context.Context, tx *sqlx.Tx, eventType string, order *models.Order) error {
+	payload, err := json.Marshal(order)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_type, order_uid, payload)
+		SELECT id, $1, $2, $3 FROM webhook_subscriptions
+		WHERE $1 = ANY(event_types)
+			AND (delivery_service = '' OR delivery_service = $4)
+			AND (entry = '' OR entry = $5)
+	`
+	return traced(ctx, "INSERT webhook_deliveries", query, func(ctx context.Context) error {
+		_, err := tx.ExecContext(ctx, query, eventType, order.OrderUID, payload, order.DeliveryService, order.Entry)
+		return err
+	})
+}
+
+// CreateSubscription stores sub and fills in its ID and creation time.
+func (r *OrderRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (url, secret, event_types, delivery_service, entry)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err := traced(ctx, "INSERT webhook_subscriptions", query, func(ctx context.Context) error {
+		return r.db.QueryRowxContext(ctx, query, sub.URL, sub.Secret, pq.Array(sub.EventTypes),
+			sub.DeliveryService, sub.Entry).Scan(&sub.ID, &sub.CreatedAt)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// ListSubscriptions returns all subscriptions without their secrets.
+func (r *OrderRepository) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	var rows []subscriptionRow
+	query := `
+		SELECT id, url, '' AS secret, event_types, delivery_service, entry, created_at
+		FROM webhook_subscriptions ORDER BY id
+	`
+	err := traced(ctx, "SELECT webhook_subscriptions", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &rows, query)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+
+	subs := make([]models.WebhookSubscription, 0, len(rows))
+	for _, row := range rows {
+		subs = append(subs, row.subscription())
+	}
+	return subs, nil
+}
+
+// GetSubscription returns a subscription without its secret, or nil if it
+// does not exist.
+func (r *OrderRepository) GetSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	var row subscriptionRow
+	query := `
+		SELECT id, url, '' AS secret, event_types, delivery_service, entry, created_at
+		FROM webhook_subscriptions WHERE id = $1
+	`
+	err := traced(ctx, "SELECT webhook_subscriptions", query, func(ctx context.Context) error {
+		return r.db.GetContext(ctx, &row, query, id)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	sub := row.subscription()
+	return &sub, nil
+}
+
+// DeleteSubscription removes a subscription and its deliveries. It reports
+// whether the subscription existed.
+func (r *OrderRepository) DeleteSubscription(ctx context.Context, id int64) (bool, error) {
+	var deleted int64
+	query := `DELETE FROM webhook_subscriptions WHERE id = $1`
+	err := traced(ctx, "DELETE webhook_subscriptions", query, func(ctx context.Context) error {
+		res, err := r.db.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		deleted, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+// ListDeliveries returns the most recent deliveries of a subscription,
+// newest first.
+func (r *OrderRepository) ListDeliveries(ctx context.Context, subscriptionID int64, limit int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	query := `
+		SELECT id, subscription_id, event_type, order_uid, status, attempts, next_attempt_at,
+			last_status_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries WHERE subscription_id = $1
+		ORDER BY id DESC LIMIT $2
+	`
+	err := traced(ctx, "SELECT webhook_deliveries", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &deliveries, query, subscriptionID, limit)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt
+// is due, with the URL and secret of their subscription.
+func (r *OrderRepository) DueDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	query := `
+		SELECT d.id, d.subscription_id, d.event_type, d.order_uid, d.payload, d.status, d.attempts,
+			d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at,
+			s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= now()
+		ORDER BY d.next_attempt_at, d.id LIMIT $1
+	`
+	err := traced(ctx, "SELECT webhook_deliveries", query, func(ctx context.Context) error {
+		return r.db.SelectContext(ctx, &deliveries, query, limit)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt stores the outcome of a delivery attempt. status is the new
+// state of the delivery; nextAttempt is used while it stays pending.
+// statusCode is 0 when no response was received.
+func (r *OrderRepository) RecordAttempt(ctx context.Context, id int64, status string, nextAttempt time.Time, statusCode int, reason string) error {
+	query := `
+		UPDATE webhook_deliveries SET
+			status = $2,
+			attempts = attempts + 1,
+			next_attempt_at = $3,
+			last_status_code = NULLIF($4, 0),
+			last_error = NULLIF($5, ''),
+			delivered_at = CASE WHEN $2 = 'delivered' THEN now() END
+		WHERE id = $1
+	`
+	err := traced(ctx, "UPDATE webhook_deliveries", query, func(ctx context.Context) error {
+		_, err := r.db.ExecContext(ctx, query, id, status, nextAttempt, statusCode, reason)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
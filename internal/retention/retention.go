@@ -0,0 +1,215 @@
+// Package retention maintains the monthly partitions of the orders tables:
+// it creates partitions ahead of time and moves months that have fallen out
+// of the retention window to gzip-compressed NDJSON archives before dropping
+// them from the database.
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Store manages the partitions of the orders tables.
+type Store interface {
+	EnsurePartitions(ctx context.Context, day time.Time) error
+	OrderPartitions(ctx context.Context) ([]time.Time, error)
+	StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(*models.Order) error) error
+	DropPartition(ctx context.Context, day time.Time, expected int) error
+}
+
+// Config configures an Archiver. Zero values use the defaults.
+type Config struct {
+	// Dir is the directory archives are written to. Defaults to "archive".
+	Dir string
+	// RetainMonths is the number of full months kept in the database
+	// besides the current one. Zero keeps every month.
+	RetainMonths int
+	// PremakeMonths is the number of months after the current one whose
+	// partitions are created in advance. Defaults to 3.
+	PremakeMonths int
+	// Interval is how often partitions are maintained. Defaults to 1h.
+	Interval time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Dir == "" {
+		c.Dir = "archive"
+	}
+	if c.PremakeMonths <= 0 {
+		c.PremakeMonths = 3
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Hour
+	}
+	return c
+}
+
+// Archiver creates and retires partitions. Dropping is guarded by the
+// number of archived orders, so several instances may run it at once.
+type Archiver struct {
+	store Store
+	cfg   Config
+}
+
+func NewArchiver(store Store, cfg Config) *Archiver {
+	return &Archiver{store: store, cfg: cfg.withDefaults()}
+}
+
+// Run maintains partitions until ctx is cancelled.
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.Maintain(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			slog.Error("Partition maintenance failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Maintain creates the partitions for the month of now and the following
+// PremakeMonths, then archives and drops every month before the retention
+// window.
+func (a *Archiver) Maintain(ctx context.Context, now time.Time) error {
+	current := month(now)
+	for i := 0; i <= a.cfg.PremakeMonths; i++ {
+		if err := a.store.EnsurePartitions(ctx, current.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+	if a.cfg.RetainMonths <= 0 {
+		return nil
+	}
+
+	cutoff := current.AddDate(0, -a.cfg.RetainMonths, 0)
+	months, err := a.store.OrderPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range months {
+		if !m.Before(cutoff) {
+			break
+		}
+		if err := a.Retire(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retire archives the orders of the month containing day and drops its
+// partitions. The archive is removed again if the drop fails, for example
+// because an order for that month was stored while it was being written.
+func (a *Archiver) Retire(ctx context.Context, day time.Time) error {
+	m := month(day)
+	path, count, err := a.archive(ctx, m)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", m.Format("2006-01"), err)
+	}
+	if err := a.store.DropPartition(ctx, m, count); err != nil {
+		if rmErr := os.Remove(path); rmErr != nil {
+			slog.WarnContext(ctx, "Failed to remove archive", "path", path, "error", rmErr)
+		}
+		return err
+	}
+	slog.InfoContext(ctx, "Archived partition", "month", m.Format("2006-01"), "orders", count, "path", path)
+	return nil
+}
+
+// archive writes the orders of month to a new file in the archive
+// directory and returns its path and the number of orders written.
+func (a *Archiver) archive(ctx context.Context, m time.Time) (string, int, error) {
+	if err := os.MkdirAll(a.cfg.Dir, 0o755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(a.cfg.Dir, ".orders-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	count := 0
+	gz := gzip.NewWriter(tmp)
+	enc := json.NewEncoder(gz)
+	filter := models.OrderFilter{From: m, To: m.AddDate(0, 1, 0)}
+	err = a.store.StreamOrders(ctx, filter, func(order *models.Order) error {
+		count++
+		return enc.Encode(order)
+	})
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = tmp.Close()
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	path, err := a.publish(tmp.Name(), m)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, count, nil
+}
+
+// publish gives the finished archive of month its final name. An existing
+// archive of the same month, left by an earlier retirement of orders that
+// arrived late, is never overwritten: the new one gets a numbered name.
+func (a *Archiver) publish(tmp string, m time.Time) (string, error) {
+	base := "orders-" + m.Format("2006-01")
+	for n := 0; ; n++ {
+		name := base + ".ndjson.gz"
+		if n > 0 {
+			name = base + "." + strconv.Itoa(n) + ".ndjson.gz"
+		}
+		path := filepath.Join(a.cfg.Dir, name)
+		err := os.Link(tmp, path)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := syncDir(a.cfg.Dir); err != nil {
+			os.Remove(path)
+			return "", err
+		}
+		return path, nil
+	}
+}
+
+// syncDir flushes the directory entry of a new file to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// month returns the first day of the month containing t. Partition bounds
+// are in date_created's wall-clock time, so t's location is ignored.
+func month(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
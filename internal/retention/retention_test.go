@@ -0,0 +1,205 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+type fakeStore struct {
+	partitions map[time.Time]bool
+	orders     []models.Order
+	dropped    map[time.Time]int
+	dropErr    error
+}
+
+func newFakeStore(orders ...models.Order) *fakeStore {
+	s := &fakeStore{partitions: map[time.Time]bool{}, orders: orders, dropped: map[time.Time]int{}}
+	for _, o := range orders {
+		s.partitions[month(o.DateCreated)] = true
+	}
+	return s
+}
+
+func (s *fakeStore) EnsurePartitions(_ context.Context, day time.Time) error {
+	s.partitions[month(day)] = true
+	return nil
+}
+
+func (s *fakeStore) OrderPartitions(context.Context) ([]time.Time, error) {
+	var months []time.Time
+	for m := range s.partitions {
+		months = append(months, m)
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months, nil
+}
+
+func (s *fakeStore) StreamOrders(_ context.Context, filter models.OrderFilter, fn func(*models.Order) error) error {
+	for i := range s.orders {
+		if filter.Match(&s.orders[i]) {
+			if err := fn(&s.orders[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) DropPartition(_ context.Context, day time.Time, expected int) error {
+	if s.dropErr != nil {
+		return s.dropErr
+	}
+	delete(s.partitions, month(day))
+	s.dropped[month(day)] = expected
+	return nil
+}
+
+func order(uid string, created time.Time) models.Order {
+	return models.Order{OrderUID: uid, TrackNumber: "TRACK", Entry: "WBIL", DateCreated: created}
+}
+
+func date(year int, m time.Month, day int) time.Time {
+	return time.Date(year, m, day, 12, 0, 0, 0, time.UTC)
+}
+
+func readArchive(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uids []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var o models.Order
+		if err := json.Unmarshal(scanner.Bytes(), &o); err != nil {
+			t.Fatalf("invalid archive line %q: %v", scanner.Text(), err)
+		}
+		uids = append(uids, o.OrderUID)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return uids
+}
+
+func TestMaintainCreatesPartitionsAhead(t *testing.T) {
+	store := newFakeStore()
+	a := NewArchiver(store, Config{Dir: t.TempDir(), PremakeMonths: 2})
+
+	if err := a.Maintain(context.Background(), date(2026, time.November, 20)); err != nil {
+		t.Fatal(err)
+	}
+
+	months, _ := store.OrderPartitions(context.Background())
+	if got := fmt.Sprint(formatMonths(months)); got != "[2026-11 2026-12 2027-01]" {
+		t.Errorf("unexpected partitions %s", got)
+	}
+}
+
+func TestMaintainArchivesAndDropsOldMonths(t *testing.T) {
+	dir := t.TempDir()
+	store := newFakeStore(
+		order("a", date(2026, time.May, 3)),
+		order("b", date(2026, time.May, 31)),
+		order("c", date(2026, time.June, 1)),
+		order("d", date(2026, time.July, 15)),
+		order("e", date(2026, time.October, 2)),
+	)
+	a := NewArchiver(store, Config{Dir: dir, RetainMonths: 3})
+
+	if err := a.Maintain(context.Background(), date(2026, time.October, 19)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := store.dropped[month(date(2026, time.May, 1))]; got != 2 {
+		t.Errorf("expected May to be dropped with 2 orders, got %d", got)
+	}
+	if got := store.dropped[month(date(2026, time.June, 1))]; got != 1 {
+		t.Errorf("expected June to be dropped with 1 order, got %d", got)
+	}
+	if _, ok := store.dropped[month(date(2026, time.July, 1))]; ok {
+		t.Error("July is within the retention window and must be kept")
+	}
+
+	if got := fmt.Sprint(readArchive(t, filepath.Join(dir, "orders-2026-05.ndjson.gz"))); got != "[a b]" {
+		t.Errorf("unexpected May archive %s", got)
+	}
+	if got := fmt.Sprint(readArchive(t, filepath.Join(dir, "orders-2026-06.ndjson.gz"))); got != "[c]" {
+		t.Errorf("unexpected June archive %s", got)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".orders-*")); len(leftovers) > 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestMaintainKeepsEverythingWithoutRetention(t *testing.T) {
+	store := newFakeStore(order("a", date(2020, time.January, 1)))
+	a := NewArchiver(store, Config{Dir: t.TempDir()})
+
+	if err := a.Maintain(context.Background(), date(2026, time.October, 19)); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.dropped) > 0 {
+		t.Errorf("expected no partitions to be dropped, got %v", store.dropped)
+	}
+}
+
+func TestRetireRemovesArchiveWhenDropFails(t *testing.T) {
+	dir := t.TempDir()
+	store := newFakeStore(order("a", date(2026, time.May, 3)))
+	store.dropErr = errors.New("partition orders_2026_05 holds 2 orders, 1 were archived")
+	a := NewArchiver(store, Config{Dir: dir})
+
+	if err := a.Retire(context.Background(), date(2026, time.May, 1)); err == nil {
+		t.Fatal("expected the drop error")
+	}
+	if files, _ := os.ReadDir(dir); len(files) > 0 {
+		t.Errorf("expected the archive to be removed, found %v", files)
+	}
+}
+
+func TestRetireKeepsEarlierArchive(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "orders-2026-05.ndjson.gz")
+	if err := os.WriteFile(existing, []byte("earlier"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := newFakeStore(order("late", date(2026, time.May, 3)))
+	a := NewArchiver(store, Config{Dir: dir})
+
+	if err := a.Retire(context.Background(), date(2026, time.May, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "earlier" {
+		t.Error("the earlier archive was overwritten")
+	}
+	if got := fmt.Sprint(readArchive(t, filepath.Join(dir, "orders-2026-05.1.ndjson.gz"))); got != "[late]" {
+		t.Errorf("unexpected second archive %s", got)
+	}
+}
+
+func formatMonths(months []time.Time) []string {
+	out := make([]string, len(months))
+	for i, m := range months {
+		out[i] = m.Format("2006-01")
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+)
+
+// Versions of the order message schema.
+const (
+	// OrderV1 is the original message format, with amounts in major units
+	// of payment.currency.
+	OrderV1 = 1
+	// OrderV2 expresses amounts in minor units of payment.currency.
+	OrderV2 = 2
+
+	// CurrentOrderVersion is the version models.Order decodes directly.
+	CurrentOrderVersion = OrderV2
+)
+
+// ErrUnknownVersion is returned for order messages of a schema version this
+// service has no schema for.
+var ErrUnknownVersion = errors.New("unknown order schema version")
+
+// Upcaster rewrites a decoded order payload of one schema version into the
+// next one in place.
+type Upcaster func(order map[string]any) error
+
+var (
+	orderSchemas = make(map[int]*Schema)
+	upcasters    = make(map[int]Upcaster)
+)
+
+func init() {
+	// v1 had the same fields as v2; only the unit of amounts changed.
+	registerOrder(OrderV1, "Amounts are integers in major units of payment.currency.")
+	registerOrder(OrderV2, "Amounts are integers in minor units of payment.currency (ISO 4217).")
+	RegisterUpcaster(OrderV1, upcastAmountsToMinor)
+}
+
+func registerOrder(version int, description string) {
+	s := Generate(models.Order{})
+	s.ID = OrderSchemaPath(version)
+	s.Title = fmt.Sprintf("Order v%d", version)
+	s.Description = description
+	orderSchemas[version] = s
+}
+
+// OrderSchemaPath returns the path the schema of version is served at.
+func OrderSchemaPath(version int) string {
+	return fmt.Sprintf("/api/schemas/order/v%d", version)
+}
+
+// OrderSchema returns the schema of an order message version.
+func OrderSchema(version int) (*Schema, bool) {
+	s, ok := orderSchemas[version]
+	return s, ok
+}
+
+// OrderVersions lists the known order schema versions in ascending order.
+func OrderVersions() []int {
+	versions := make([]int, 0, len(orderSchemas))
+	for v := range orderSchemas {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// RegisterUpcaster registers u to translate order payloads of version from
+// into version from+1. It must be called during initialization.
+func RegisterUpcaster(from int, u Upcaster) {
+	upcasters[from] = u
+}
+
+// ValidateOrder checks an order payload against the schema of version.
+func ValidateOrder(version int, payload []byte) error {
+	s, ok := OrderSchema(version)
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return s.Validate(payload)
+}
+
+// UpcastOrder translates an order payload of version into the current
+// version. Payloads already at the current version are returned unchanged.
+func UpcastOrder(version int, payload []byte) ([]byte, error) {
+	if version == CurrentOrderVersion {
+		return payload, nil
+	}
+	if _, ok := orderSchemas[version]; !ok || version > CurrentOrderVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	v, err := decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	order, ok := v.(map[string]any)
+	if !ok {
+		return nil, errors.New("order payload is not an object")
+	}
+	for ; version < CurrentOrderVersion; version++ {
+		u, ok := upcasters[version]
+		if !ok {
+			return nil, fmt.Errorf("no upcaster from order v%d", version)
+		}
+		if err := u(order); err != nil {
+			return nil, fmt.Errorf("upcast order v%d: %w", version, err)
+		}
+	}
+	return json.Marshal(order)
+}
+
+// upcastAmountsToMinor converts v1 amounts from major to minor units.
+func upcastAmountsToMinor(order map[string]any) error {
+	payment, _ := order["payment"].(map[string]any)
+	currency, _ := payment["currency"].(string)
+	exp, ok := money.Exponent(currency)
+	if !ok {
+		return fmt.Errorf("unknown currency %q", currency)
+	}
+	factor := int64(math.Pow10(exp))
+
+	scale := func(obj map[string]any, key string) error {
+		n, ok := obj[key].(json.Number)
+		if !ok {
+			return nil
+		}
+		major, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if major > math.MaxInt64/factor || major < math.MinInt64/factor {
+			return fmt.Errorf("%s: %d overflows in minor units", key, major)
+		}
+		obj[key] = json.Number(strconv.FormatInt(major*factor, 10))
+		return nil
+	}
+
+	for _, key := range []string{"amount", "delivery_cost", "goods_total", "custom_fee"} {
+		if err := scale(payment, key); err != nil {
+			return fmt.Errorf("payment.%w", err)
+		}
+	}
+	items, _ := order["items"].([]any)
+	for i, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"price", "total_price"} {
+			if err := scale(obj, key); err != nil {
+				return fmt.Errorf("items[%d].%w", i, err)
+			}
+		}
+	}
+	return nil
+}
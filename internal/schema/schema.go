@@ -0,0 +1,153 @@
+// Package schema generates JSON Schemas from model structs, validates
+// payloads against them and upcasts order messages from older schema
+// versions.
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/money"
+)
+
+// Draft is the JSON Schema dialect of generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema produced by Generate and understood by
+// Validate.
+type Schema struct {
+	Draft       string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Type        Types              `json:"type"`
+	Format      string             `json:"format,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Minimum     *int64             `json:"minimum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+
+	// order lists Properties in struct field order for Validate.
+	order []string
+}
+
+// Types is the "type" keyword: a single type name, or several when a value
+// may be one of them.
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Has reports whether name is one of the types.
+func (t Types) Has(name string) bool {
+	for _, n := range t {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	moneyType = reflect.TypeOf(money.Money{})
+)
+
+// Generate builds the schema of v's type from its json tags. Fields tagged
+// `schema:"required"` must be present and, for strings, non-empty;
+// `schema:"min=N"` sets the minimum of a number. Other fields are optional
+// and unknown properties are allowed, so producers can add fields before
+// consumers know about them.
+func Generate(v any) *Schema {
+	s := generate(reflect.TypeOf(v))
+	s.Draft = Draft
+	return s
+}
+
+func generate(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	}
+	if t == moneyType {
+		// Encoded as the bare amount in minor units
+		return &Schema{Type: Types{"integer"}}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		s := &Schema{Type: Types{"object"}, Properties: make(map[string]*Schema)}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, ok := jsonName(f)
+			if !ok {
+				continue
+			}
+			prop := generate(f.Type)
+			for _, opt := range strings.Split(f.Tag.Get("schema"), ",") {
+				switch {
+				case opt == "required":
+					s.Required = append(s.Required, name)
+					if prop.Type.Has("string") {
+						one := 1
+						prop.MinLength = &one
+					}
+				case strings.HasPrefix(opt, "min="):
+					if n, err := strconv.ParseInt(opt[len("min="):], 10, 64); err == nil {
+						prop.Minimum = &n
+					}
+				}
+			}
+			s.Properties[name] = prop
+			s.order = append(s.order, name)
+		}
+		return s
+	case reflect.Slice, reflect.Array:
+		// Go encodes nil slices as null.
+		return &Schema{Type: Types{"array", "null"}, Items: generate(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	}
+	return &Schema{Type: Types{"object"}}
+}
+
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	return name, true
+}
@@ -0,0 +1,133 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+	"order-service/internal/money"
+)
+
+func TestGenerateOrder(t *testing.T) {
+	s := Generate(models.Order{})
+
+	if s.Draft != Draft || !s.Type.Has("object") {
+		t.Fatalf("unexpected root schema %+v", s)
+	}
+	if got := s.Properties["order_uid"]; got == nil || got.MinLength == nil || *got.MinLength != 1 {
+		t.Errorf("expected order_uid to be a non-empty string, got %+v", got)
+	}
+	if got := s.Properties["date_created"]; got == nil || got.Format != "date-time" {
+		t.Errorf("expected date_created to be a date-time, got %+v", got)
+	}
+	items := s.Properties["items"]
+	if items == nil || !items.Type.Has("array") || items.Items == nil {
+		t.Fatalf("expected items to be an array, got %+v", items)
+	}
+	if price := items.Items.Properties["price"]; price == nil || price.Minimum == nil || *price.Minimum != 0 {
+		t.Errorf("expected item price to have minimum 0, got %+v", price)
+	}
+	if _, ok := s.Properties["ID"]; ok {
+		t.Error("fields tagged json:\"-\" must not appear in the schema")
+	}
+}
+
+func TestGeneratedSchemaAcceptsEncodedOrders(t *testing.T) {
+	order := models.Order{
+		OrderUID:    "abc",
+		TrackNumber: "T1",
+		Entry:       "WBIL",
+		DateCreated: time.Now(),
+		Payment:     models.Payment{Currency: "USD", Amount: money.New(100, "USD")},
+	}
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateOrder(CurrentOrderVersion, data); err != nil {
+		t.Errorf("expected an encoded order to be valid, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		path    string
+	}{
+		{"not an object", `[]`, ""},
+		{"missing required", `{"order_uid":"a","track_number":"t","payment":{"currency":"USD"}}`, ""},
+		{"empty required string", `{"order_uid":"","track_number":"t","entry":"e","payment":{"currency":"USD"}}`, "/order_uid"},
+		{"wrong type", `{"order_uid":"a","track_number":"t","entry":"e","payment":{"currency":"USD"},"sm_id":"1"}`, "/sm_id"},
+		{"fractional integer", `{"order_uid":"a","track_number":"t","entry":"e","payment":{"currency":"USD","amount":1.5}}`, "/payment/amount"},
+		{"below minimum", `{"order_uid":"a","track_number":"t","entry":"e","payment":{"currency":"USD"},"items":[{"price":-1}]}`, "/items/0/price"},
+		{"bad date", `{"order_uid":"a","track_number":"t","entry":"e","payment":{"currency":"USD"},"date_created":"yesterday"}`, "/date_created"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOrder(CurrentOrderVersion, []byte(tt.payload))
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a ValidationError, got %v", err)
+			}
+			if verr.Path != tt.path {
+				t.Errorf("expected path %q, got %q (%v)", tt.path, verr.Path, err)
+			}
+		})
+	}
+}
+
+func TestValidateAllowsNullsAndUnknownFields(t *testing.T) {
+	payload := `{"order_uid":"a","track_number":"t","entry":"e","payment":{"currency":"USD"},"items":null,"new_field":1}`
+	if err := ValidateOrder(CurrentOrderVersion, []byte(payload)); err != nil {
+		t.Errorf("expected payload to be valid, got %v", err)
+	}
+}
+
+func TestUpcastOrderV1(t *testing.T) {
+	payload := `{"payment":{"currency":"JPY","amount":500},"items":[{"price":200,"total_price":100}]}`
+	if _, err := UpcastOrder(OrderV1, []byte(payload)); err != nil {
+		t.Fatalf("UpcastOrder: %v", err)
+	}
+
+	payload = `{"payment":{"currency":"USD","amount":18,"custom_fee":0},"items":[{"price":4,"total_price":3}]}`
+	out, err := UpcastOrder(OrderV1, []byte(payload))
+	if err != nil {
+		t.Fatalf("UpcastOrder: %v", err)
+	}
+	var order models.Order
+	if err := json.Unmarshal(out, &order); err != nil {
+		t.Fatal(err)
+	}
+	if order.Payment.Amount.Amount != 1800 || order.Items[0].Price.Amount != 400 || order.Items[0].TotalPrice.Amount != 300 {
+		t.Errorf("expected amounts in cents, got %+v %+v", order.Payment, order.Items)
+	}
+}
+
+func TestUpcastOrderErrors(t *testing.T) {
+	if _, err := UpcastOrder(OrderV1, []byte(`{"payment":{"currency":"XXX","amount":1}}`)); err == nil {
+		t.Error("expected an error for an unknown currency")
+	}
+	if _, err := UpcastOrder(7, []byte(`{}`)); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+	payload := []byte(`{"order_uid":"a"}`)
+	if out, err := UpcastOrder(CurrentOrderVersion, payload); err != nil || string(out) != string(payload) {
+		t.Errorf("expected current payloads unchanged, got %s, %v", out, err)
+	}
+}
+
+func TestOrderVersions(t *testing.T) {
+	versions := OrderVersions()
+	if len(versions) != 2 || versions[0] != OrderV1 || versions[len(versions)-1] != CurrentOrderVersion {
+		t.Errorf("unexpected versions %v", versions)
+	}
+	for _, v := range versions {
+		s, _ := OrderSchema(v)
+		if s.ID != OrderSchemaPath(v) {
+			t.Errorf("expected $id %s, got %s", OrderSchemaPath(v), s.ID)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError describes the first value that does not match a schema.
+// Path is a JSON Pointer to the value.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + e.Message
+}
+
+// Validate checks that data is a JSON document matching s.
+func (s *Schema) Validate(data []byte) error {
+	v, err := decode(data)
+	if err != nil {
+		return &ValidationError{Message: err.Error()}
+	}
+	return s.validate("", v)
+}
+
+// decode parses data keeping numbers as json.Number so that integers are
+// checked exactly.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *Schema) validate(path string, v any) error {
+	fail := func(format string, args ...any) error {
+		return &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if v == nil && s.Type.Has("null") {
+		return nil
+	}
+
+	switch s.Type[0] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fail("expected object, got %s", typeOf(v))
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fail("missing required property %q", name)
+			}
+		}
+		for _, name := range s.propertyNames() {
+			if value, ok := obj[name]; ok {
+				if err := s.Properties[name].validate(path+"/"+escape(name), value); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fail("expected array, got %s", typeOf(v))
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				if err := s.Items.validate(path+"/"+strconv.Itoa(i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("expected string, got %s", typeOf(v))
+		}
+		if s.MinLength != nil && len([]rune(str)) < *s.MinLength {
+			return fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fail("expected RFC 3339 date-time")
+			}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fail("expected integer, got %s", typeOf(v))
+		}
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		if err != nil {
+			return fail("expected integer, got %s", n)
+		}
+		if s.Minimum != nil && i < *s.Minimum {
+			return fail("must be at least %d", *s.Minimum)
+		}
+	case "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fail("expected number, got %s", typeOf(v))
+		}
+		if s.Minimum != nil {
+			if f, _ := n.Float64(); f < float64(*s.Minimum) {
+				return fail("must be at least %d", *s.Minimum)
+			}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("expected boolean, got %s", typeOf(v))
+		}
+	}
+	return nil
+}
+
+// propertyNames returns the property names in a stable order so that the
+// first error reported for a payload does not vary between runs.
+func (s *Schema) propertyNames() []string {
+	if len(s.order) == len(s.Properties) {
+		return s.order
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escape encodes name as a JSON Pointer reference token.
+func escape(name string) string {
+	if !strings.ContainsAny(name, "~/") {
+		return name
+	}
+	return pointerEscaper.Replace(name)
+}
@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return rec
+}
+
+func TestInjectExtractContinuesTrace(t *testing.T) {
+	rec := recordSpans(t)
+	tracer := Tracer("test")
+
+	ctx, producer := tracer.Start(context.Background(), "publish")
+	headers := Inject(ctx)
+	producer.End()
+
+	if headers["traceparent"] == "" {
+		t.Fatalf("expected traceparent header, got %v", headers)
+	}
+
+	_, consumer := tracer.Start(Extract(context.Background(), headers), "receive")
+	consumer.End()
+
+	spans := rec.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].SpanContext().TraceID() != spans[1].SpanContext().TraceID() {
+		t.Error("consumer span should share the producer's trace ID")
+	}
+	if spans[1].Parent().SpanID() != spans[0].SpanContext().SpanID() {
+		t.Error("consumer span should be a child of the producer span")
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	recordSpans(t)
+	if headers := Inject(context.Background()); headers != nil {
+		t.Errorf("expected no headers without an active span, got %v", headers)
+	}
+	ctx := context.Background()
+	if Extract(ctx, nil) != ctx {
+		t.Error("Extract without headers should return ctx unchanged")
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	rec := recordSpans(t)
+
+	_, span := Tracer("test").Start(context.Background(), "failing")
+	End(span, errors.New("boom"))
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Errorf("expected error status, got %v", spans[0].Status())
+	}
+	if len(spans[0].Events()) == 0 {
+		t.Error("expected the error to be recorded as an event")
+	}
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup("test", "jaeger", ""); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
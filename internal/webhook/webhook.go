@@ -0,0 +1,253 @@
+// Package webhook delivers order events to subscribers over HTTP. Payloads
+// are signed with HMAC-SHA256 using each subscription's secret, and failed
+// deliveries are retried with exponential backoff until they are dead.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Store reads due deliveries and records the outcome of attempts.
+type Store interface {
+	DueDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id int64, status string, nextAttempt time.Time, statusCode int, reason string) error
+}
+
+// Config configures a Dispatcher. Zero values use the defaults.
+type Config struct {
+	// Interval is how often due deliveries are polled. Defaults to 1s.
+	Interval time.Duration
+	// Timeout bounds a single attempt. Defaults to 10s.
+	Timeout time.Duration
+	// MaxAttempts is the number of attempts after which a delivery is
+	// dead. Defaults to 8.
+	MaxAttempts int
+	// BackoffBase is the delay after the first failure; it doubles with
+	// every further failure up to BackoffMax. Default to 10s and 1h.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Concurrency is the number of deliveries sent at once. Defaults to 4.
+	Concurrency int
+	// BatchSize is the number of deliveries read per poll. Defaults to 100.
+	BatchSize int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 8
+	}
+	if c.BackoffBase <= 0 {
+		c.BackoffBase = 10 * time.Second
+	}
+	if c.BackoffMax <= 0 {
+		c.BackoffMax = time.Hour
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 4
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	return c
+}
+
+// Backoff returns the delay before the next attempt after attempts failed
+// attempts.
+func (c Config) Backoff(attempts int) time.Duration {
+	c = c.withDefaults()
+	d := c.BackoffBase
+	for i := 1; i < attempts && d < c.BackoffMax; i++ {
+		d *= 2
+	}
+	if d > c.BackoffMax {
+		d = c.BackoffMax
+	}
+	return d
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Order     json.RawMessage `json:"order"`
+}
+
+// Sign returns the signature header value for a body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>".
+// Including the timestamp lets receivers reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature produced by Sign in constant time.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Dispatcher sends due deliveries. One dispatcher should run per database.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	cfg    Config
+}
+
+func NewDispatcher(store Store, client *http.Client, cfg Config) *Dispatcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Dispatcher{store: store, client: client, cfg: cfg.withDefaults()}
+}
+
+// Run polls for due deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Webhook dispatch failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush attempts every delivery that is due and records the outcomes.
+func (d *Dispatcher) Flush(ctx context.Context) error {
+	for {
+		deliveries, err := d.store.DueDeliveries(ctx, d.cfg.BatchSize)
+		if err != nil {
+			return err
+		}
+
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			firstErr error
+			sem      = make(chan struct{}, d.cfg.Concurrency)
+		)
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(delivery models.WebhookDelivery) {
+				defer func() { <-sem; wg.Done() }()
+				if err := d.attempt(ctx, delivery); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}(delivery)
+		}
+		wg.Wait()
+
+		if firstErr != nil {
+			return firstErr
+		}
+		if len(deliveries) < d.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// attempt sends a delivery once and records the result. It only returns an
+// error if the result could not be recorded.
+func (d *Dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) error {
+	code, err := d.send(ctx, delivery)
+
+	attempts := delivery.Attempts + 1
+	logger := slog.With("delivery_id", delivery.ID, "subscription_id", delivery.SubscriptionID,
+		"order_uid", delivery.OrderUID, "attempt", attempts)
+
+	status, next, reason := models.DeliveryDelivered, time.Now(), ""
+	if err != nil {
+		reason = err.Error()
+		if attempts >= d.cfg.MaxAttempts {
+			status = models.DeliveryDead
+			logger.WarnContext(ctx, "Webhook delivery is dead", "status_code", code, "error", err)
+		} else {
+			status = models.DeliveryPending
+			next = time.Now().Add(d.cfg.Backoff(attempts))
+			logger.InfoContext(ctx, "Webhook delivery failed, will retry", "status_code", code, "retry_at", next, "error", err)
+		}
+	}
+	return d.store.RecordAttempt(ctx, delivery.ID, status, next, code, reason)
+}
+
+// send posts the signed payload and returns the response status. Any
+// non-2xx response is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	id := strconv.FormatInt(delivery.ID, 10)
+	body, err := json.Marshal(Payload{
+		ID:        id,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt.UTC(),
+		Order:     delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-service-webhooks")
+	req.Header.Set(HeaderID, id)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
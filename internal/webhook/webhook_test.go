@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+type attemptRecord struct {
+	status     string
+	next       time.Time
+	statusCode int
+	reason     string
+}
+
+type fakeStore struct {
+	mu         sync.Mutex
+	deliveries []models.WebhookDelivery
+	attempts   map[int64][]attemptRecord
+}
+
+func newFakeStore(deliveries ...models.WebhookDelivery) *fakeStore {
+	return &fakeStore{deliveries: deliveries, attempts: map[int64][]attemptRecord{}}
+}
+
+func (s *fakeStore) DueDeliveries(_ context.Context, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == models.DeliveryPending && !d.NextAttemptAt.After(time.Now()) && len(out) < limit {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeStore) RecordAttempt(_ context.Context, id int64, status string, next time.Time, statusCode int, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == id {
+			s.deliveries[i].Status = status
+			s.deliveries[i].Attempts++
+			s.deliveries[i].NextAttemptAt = next
+		}
+	}
+	s.attempts[id] = append(s.attempts[id], attemptRecord{status, next, statusCode, reason})
+	return nil
+}
+
+func delivery(id int64, url string) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		ID:             id,
+		SubscriptionID: 1,
+		EventType:      models.EventOrderCreated,
+		OrderUID:       "order-" + strconv.FormatInt(id, 10),
+		Payload:        json.RawMessage(`{"order_uid":"order-` + strconv.FormatInt(id, 10) + `"}`),
+		Status:         models.DeliveryPending,
+		CreatedAt:      time.Now(),
+		URL:            url,
+		Secret:         "0123456789abcdef",
+	}
+}
+
+func TestDispatcherSendsSignedPayload(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []*http.Request
+		body []byte
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		reqs = append(reqs, r)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer receiver.Close()
+
+	store := newFakeStore(delivery(7, receiver.URL))
+	if err := NewDispatcher(store, nil, Config{}).Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	r := reqs[0]
+	if r.Header.Get(HeaderID) != "7" || r.Header.Get(HeaderEvent) != models.EventOrderCreated {
+		t.Errorf("unexpected headers %v", r.Header)
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid timestamp: %v", err)
+	}
+	if !Verify("0123456789abcdef", timestamp, body, r.Header.Get(HeaderSignature)) {
+		t.Errorf("signature %s does not verify", r.Header.Get(HeaderSignature))
+	}
+	if Verify("another-secret!!", timestamp, body, r.Header.Get(HeaderSignature)) {
+		t.Error("signature verified with the wrong secret")
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload.ID != "7" || payload.Type != models.EventOrderCreated || string(payload.Order) != `{"order_uid":"order-7"}` {
+		t.Errorf("unexpected payload %s", body)
+	}
+
+	if a := store.attempts[7]; len(a) != 1 || a[0].status != models.DeliveryDelivered || a[0].statusCode != 200 {
+		t.Errorf("expected the delivery to be marked delivered, got %+v", a)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	d := delivery(1, receiver.URL)
+	d.Attempts = 2
+	store := newFakeStore(d)
+	cfg := Config{MaxAttempts: 4, BackoffBase: time.Minute, BackoffMax: time.Hour}
+
+	start := time.Now()
+	if err := NewDispatcher(store, nil, cfg).Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	a := store.attempts[1]
+	if len(a) != 1 || a[0].status != models.DeliveryPending || a[0].statusCode != 503 || a[0].reason == "" {
+		t.Fatalf("expected a pending retry, got %+v", a)
+	}
+	// Third failed attempt: base * 2^2.
+	if delay := a[0].next.Sub(start); delay < 4*time.Minute || delay > 4*time.Minute+5*time.Second {
+		t.Errorf("expected a 4m backoff, got %s", delay)
+	}
+}
+
+func TestDispatcherMarksDeadAfterMaxAttempts(t *testing.T) {
+	d := delivery(1, "http://127.0.0.1:1/unreachable")
+	d.Attempts = 2
+	store := newFakeStore(d)
+
+	if err := NewDispatcher(store, nil, Config{MaxAttempts: 3, Timeout: time.Second}).Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	a := store.attempts[1]
+	if len(a) != 1 || a[0].status != models.DeliveryDead || a[0].statusCode != 0 || a[0].reason == "" {
+		t.Errorf("expected the delivery to be dead, got %+v", a)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := Config{BackoffBase: 10 * time.Second, BackoffMax: time.Minute}
+	for attempts, want := range map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		4:  time.Minute,
+		50: time.Minute,
+	} {
+		if got := cfg.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+-- Monetary amounts are stored in minor units of payment.currency (ISO 4217).
+-- Rows written before hold major units, so they are multiplied by the minor
+-- units per major unit of their currency in the same transaction that widens
+-- the columns. The widened type marks the conversion as done, so running the
+-- file again changes nothing. Stop the service and the publisher before
+-- applying it: orders written by the old version meanwhile would be missed.
+BEGIN;
+
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'payment' AND column_name = 'amount') = 'bigint' THEN
+        RETURN;
+    END IF;
+
+    -- Mirrors internal/money/currency.go
+    CREATE TEMP TABLE currency_factors (code TEXT PRIMARY KEY, factor BIGINT NOT NULL) ON COMMIT DROP;
+    INSERT INTO currency_factors (code, factor) VALUES
+        ('BIF', 1), ('CLP', 1), ('DJF', 1), ('GNF', 1), ('ISK', 1), ('JPY', 1),
+        ('KMF', 1), ('KRW', 1), ('PYG', 1), ('RWF', 1), ('UGX', 1), ('VND', 1),
+        ('VUV', 1), ('XAF', 1), ('XOF', 1), ('XPF', 1), ('BHD', 1000), ('IQD', 1000),
+        ('JOD', 1000), ('KWD', 1000), ('LYD', 1000), ('OMR', 1000), ('TND', 1000), ('AED', 100),
+        ('AMD', 100), ('ARS', 100), ('AUD', 100), ('AZN', 100), ('BGN', 100), ('BRL', 100),
+        ('BYN', 100), ('CAD', 100), ('CHF', 100), ('CNY', 100), ('CZK', 100), ('DKK', 100),
+        ('EGP', 100), ('EUR', 100), ('GBP', 100), ('GEL', 100), ('HKD', 100), ('HUF', 100),
+        ('IDR', 100), ('ILS', 100), ('INR', 100), ('KGS', 100), ('KZT', 100), ('MDL', 100),
+        ('MXN', 100), ('MYR', 100), ('NOK', 100), ('NZD', 100), ('PHP', 100), ('PLN', 100),
+        ('RON', 100), ('RSD', 100), ('RUB', 100), ('SAR', 100), ('SEK', 100), ('SGD', 100),
+        ('THB', 100), ('TJS', 100), ('TMT', 100), ('TRY', 100), ('TWD', 100), ('UAH', 100),
+        ('USD', 100), ('UZS', 100), ('ZAR', 100);
+
+    IF EXISTS (SELECT 1 FROM payment p
+        WHERE NOT EXISTS (SELECT 1 FROM currency_factors c WHERE c.code = upper(p.currency))) THEN
+        RAISE EXCEPTION 'payments in currencies missing from internal/money/currency.go: %',
+            (SELECT string_agg(DISTINCT coalesce(currency, 'NULL'), ', ') FROM payment p
+             WHERE NOT EXISTS (SELECT 1 FROM currency_factors c WHERE c.code = upper(p.currency)));
+    END IF;
+
+    ALTER TABLE payment
+        ALTER COLUMN amount TYPE BIGINT,
+        ALTER COLUMN delivery_cost TYPE BIGINT,
+        ALTER COLUMN goods_total TYPE BIGINT,
+        ALTER COLUMN custom_fee TYPE BIGINT;
+
+    ALTER TABLE items
+        ALTER COLUMN price TYPE BIGINT,
+        ALTER COLUMN total_price TYPE BIGINT;
+
+    UPDATE items i SET
+        price = i.price * c.factor,
+        total_price = i.total_price * c.factor
+    FROM payment p JOIN currency_factors c ON c.code = upper(p.currency)
+    WHERE i.order_uid = p.order_uid AND c.factor <> 1;
+
+    UPDATE payment p SET
+        amount = p.amount * c.factor,
+        delivery_cost = p.delivery_cost * c.factor,
+        goods_total = p.goods_total * c.factor,
+        custom_fee = p.custom_fee * c.factor
+    FROM currency_factors c
+    WHERE c.code = upper(p.currency) AND c.factor <> 1;
+END $$;
+
+COMMENT ON COLUMN payment.amount IS 'Minor units of payment.currency';
+COMMENT ON COLUMN items.price IS 'Minor units of payment.currency';
+
+COMMIT;
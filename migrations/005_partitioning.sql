@@ -0,0 +1,205 @@
+-- Range-partition orders and its child tables by the month of date_created.
+-- Partitions are named <table>_YYYY_MM and are created ahead of time by the
+-- service; old ones are archived and dropped by the retention job.
+BEGIN;
+
+-- Order UIDs stay unique across partitions. The partition key has to be part
+-- of every unique constraint of a partitioned table, so uniqueness of
+-- order_uid alone is enforced here.
+CREATE TABLE IF NOT EXISTS order_keys (
+    order_uid VARCHAR(255) PRIMARY KEY,
+    date_created TIMESTAMP NOT NULL
+);
+
+-- drop_order_partitions deletes the keys of a month by date_created
+CREATE INDEX IF NOT EXISTS idx_order_keys_date ON order_keys (date_created);
+
+-- Creates the partitions of all four tables for the month containing day.
+CREATE OR REPLACE FUNCTION ensure_order_partitions(day date) RETURNS void AS $$
+DECLARE
+    start_at date := date_trunc('month', day);
+    suffix text := to_char(start_at, 'YYYY_MM');
+    parent text;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('order_partitions'));
+    FOREACH parent IN ARRAY ARRAY['orders', 'delivery', 'payment', 'items'] LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+            parent || '_' || suffix, parent, start_at, start_at + interval '1 month');
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Drops the partitions of the month containing day and the keys of their
+-- orders. Fails without dropping anything unless the month holds exactly
+-- expected orders, so orders stored after the month was archived are kept.
+CREATE OR REPLACE FUNCTION drop_order_partitions(day date, expected bigint) RETURNS void AS $$
+DECLARE
+    start_at date := date_trunc('month', day);
+    suffix text := to_char(start_at, 'YYYY_MM');
+    child text;
+    actual bigint;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('order_partitions'));
+    IF to_regclass('orders_' || suffix) IS NULL THEN
+        RETURN;
+    END IF;
+
+    EXECUTE format('LOCK TABLE %I IN ACCESS EXCLUSIVE MODE', 'orders_' || suffix);
+    EXECUTE format('SELECT count(*) FROM %I', 'orders_' || suffix) INTO actual;
+    IF actual <> expected THEN
+        RAISE EXCEPTION 'partition orders_% holds % orders, % were archived', suffix, actual, expected;
+    END IF;
+
+    DELETE FROM order_keys WHERE date_created >= start_at AND date_created < start_at + interval '1 month';
+    FOREACH child IN ARRAY ARRAY['items', 'delivery', 'payment', 'orders'] LOOP
+        IF to_regclass(child || '_' || suffix) IS NOT NULL THEN
+            EXECUTE format('ALTER TABLE %I DETACH PARTITION %I', child, child || '_' || suffix);
+            EXECUTE format('DROP TABLE %I', child || '_' || suffix);
+        END IF;
+    END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Convert the tables once; make migrate runs every migration again.
+DO $$
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE oid = 'orders'::regclass) = 'p' THEN
+        RETURN;
+    END IF;
+
+    -- Move the existing tables and the names of their indexes out of the way.
+    ALTER TABLE items RENAME TO items_legacy;
+    ALTER TABLE payment RENAME TO payment_legacy;
+    ALTER TABLE delivery RENAME TO delivery_legacy;
+    ALTER TABLE orders RENAME TO orders_legacy;
+    ALTER INDEX IF EXISTS orders_pkey RENAME TO orders_legacy_pkey;
+    ALTER INDEX IF EXISTS delivery_pkey RENAME TO delivery_legacy_pkey;
+    ALTER INDEX IF EXISTS delivery_order_uid_key RENAME TO delivery_legacy_order_uid_key;
+    ALTER INDEX IF EXISTS payment_pkey RENAME TO payment_legacy_pkey;
+    ALTER INDEX IF EXISTS payment_order_uid_key RENAME TO payment_legacy_order_uid_key;
+    ALTER INDEX IF EXISTS items_pkey RENAME TO items_legacy_pkey;
+    ALTER INDEX IF EXISTS idx_orders_date RENAME TO idx_orders_legacy_date;
+    ALTER INDEX IF EXISTS idx_items_order_uid RENAME TO idx_items_legacy_order_uid;
+
+    UPDATE orders_legacy SET date_created = CURRENT_TIMESTAMP WHERE date_created IS NULL;
+
+    CREATE TABLE orders (
+        order_uid VARCHAR(255) NOT NULL,
+        track_number VARCHAR(255) NOT NULL,
+        entry VARCHAR(255) NOT NULL,
+        locale VARCHAR(10),
+        internal_signature VARCHAR(255),
+        customer_id VARCHAR(255),
+        delivery_service VARCHAR(255),
+        shardkey VARCHAR(10),
+        sm_id INTEGER DEFAULT 0,
+        date_created TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+        oof_shard VARCHAR(10),
+        PRIMARY KEY (order_uid, date_created)
+    ) PARTITION BY RANGE (date_created);
+
+    -- Child rows carry the date of their order so that they live in the
+    -- partition of the same month.
+    CREATE TABLE delivery (
+        id SERIAL,
+        order_uid VARCHAR(255) NOT NULL,
+        date_created TIMESTAMP NOT NULL,
+        name VARCHAR(255) NOT NULL,
+        phone VARCHAR(50) NOT NULL,
+        zip VARCHAR(20),
+        city VARCHAR(255),
+        address TEXT,
+        region VARCHAR(255),
+        email VARCHAR(255),
+        PRIMARY KEY (id, date_created),
+        UNIQUE (order_uid, date_created),
+        FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE
+    ) PARTITION BY RANGE (date_created);
+
+    CREATE TABLE payment (
+        id SERIAL,
+        order_uid VARCHAR(255) NOT NULL,
+        date_created TIMESTAMP NOT NULL,
+        transaction VARCHAR(255) NOT NULL,
+        request_id VARCHAR(255),
+        currency VARCHAR(10),
+        provider VARCHAR(255),
+        amount BIGINT DEFAULT 0,
+        payment_dt BIGINT,
+        bank VARCHAR(255),
+        delivery_cost BIGINT DEFAULT 0,
+        goods_total BIGINT DEFAULT 0,
+        custom_fee BIGINT DEFAULT 0,
+        PRIMARY KEY (id, date_created),
+        UNIQUE (order_uid, date_created),
+        FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE
+    ) PARTITION BY RANGE (date_created);
+
+    CREATE TABLE items (
+        id SERIAL,
+        order_uid VARCHAR(255) NOT NULL,
+        date_created TIMESTAMP NOT NULL,
+        chrt_id INTEGER,
+        track_number VARCHAR(255),
+        price BIGINT,
+        rid VARCHAR(255),
+        name VARCHAR(255),
+        sale INTEGER,
+        size VARCHAR(50),
+        total_price BIGINT,
+        nm_id INTEGER,
+        brand VARCHAR(255),
+        status INTEGER,
+        PRIMARY KEY (id, date_created),
+        FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE
+    ) PARTITION BY RANGE (date_created);
+
+    COMMENT ON COLUMN payment.amount IS 'Minor units of payment.currency';
+    COMMENT ON COLUMN items.price IS 'Minor units of payment.currency';
+
+    CREATE INDEX idx_orders_date ON orders(date_created DESC);
+    CREATE INDEX idx_items_order_uid ON items(order_uid);
+
+    -- Partitions for every month with data, the current month and the next two.
+    PERFORM ensure_order_partitions(month::date)
+    FROM generate_series(
+        date_trunc('month', LEAST((SELECT min(date_created) FROM orders_legacy), LOCALTIMESTAMP)),
+        date_trunc('month', GREATEST((SELECT max(date_created) FROM orders_legacy), LOCALTIMESTAMP + interval '2 months')),
+        interval '1 month'
+    ) AS month;
+
+    INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature,
+        customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+    SELECT order_uid, track_number, entry, locale, internal_signature,
+        customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+    FROM orders_legacy;
+
+    INSERT INTO order_keys (order_uid, date_created)
+    SELECT order_uid, date_created FROM orders_legacy;
+
+    INSERT INTO delivery (id, order_uid, date_created, name, phone, zip, city, address, region, email)
+    SELECT d.id, d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email
+    FROM delivery_legacy d JOIN orders_legacy o USING (order_uid);
+
+    INSERT INTO payment (id, order_uid, date_created, transaction, request_id, currency, provider,
+        amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+    SELECT p.id, p.order_uid, o.date_created, p.transaction, p.request_id, p.currency, p.provider,
+        p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+    FROM payment_legacy p JOIN orders_legacy o USING (order_uid);
+
+    INSERT INTO items (id, order_uid, date_created, chrt_id, track_number, price, rid, name,
+        sale, size, total_price, nm_id, brand, status)
+    SELECT i.id, i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name,
+        i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+    FROM items_legacy i JOIN orders_legacy o USING (order_uid);
+
+    -- Continue the ids where the old tables left off.
+    PERFORM setval(pg_get_serial_sequence('delivery', 'id'), COALESCE((SELECT max(id) FROM delivery), 0) + 1, false);
+    PERFORM setval(pg_get_serial_sequence('payment', 'id'), COALESCE((SELECT max(id) FROM payment), 0) + 1, false);
+    PERFORM setval(pg_get_serial_sequence('items', 'id'), COALESCE((SELECT max(id) FROM items), 0) + 1, false);
+
+    DROP TABLE items_legacy, payment_legacy, delivery_legacy, orders_legacy;
+END;
+$$;
+
+COMMIT;
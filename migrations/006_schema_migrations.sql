@@ -0,0 +1,15 @@
+-- Migrations applied to this database, recorded by make migrate after each
+-- file succeeds and read by orderctl migrate status
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version VARCHAR(255) PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Migrations run in order, so everything before this one is applied
+INSERT INTO schema_migrations (version) VALUES
+    ('001_init_schema'),
+    ('002_money_bigint'),
+    ('003_outbox'),
+    ('004_webhooks'),
+    ('005_partitioning')
+ON CONFLICT (version) DO NOTHING;
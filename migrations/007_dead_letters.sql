@@ -0,0 +1,14 @@
+-- Messages the subscriber could not decode or validate. They are
+-- acknowledged so they are not redelivered forever, and kept here until
+-- they are fixed and replayed with orderctl dlq replay
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id BIGSERIAL PRIMARY KEY,
+    subject VARCHAR(255) NOT NULL,
+    sequence BIGINT NOT NULL,
+    data BYTEA NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    replayed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_pending ON dead_letters(id) WHERE replayed_at IS NULL;
@@ -0,0 +1,8 @@
+-- How far the service has processed each NATS channel: every message up to
+-- and including sequence is stored. It is written in the same transaction
+-- as the orders, so it never disagrees with them
+CREATE TABLE IF NOT EXISTS consumer_positions (
+    subject VARCHAR(255) PRIMARY KEY,
+    sequence BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- The index on order_keys.date_created that 005_partitioning creates, for
+-- databases partitioned before it was added there
+CREATE INDEX IF NOT EXISTS idx_order_keys_date ON order_keys (date_created);
@@ -0,0 +1,6 @@
+-- Outbox events the relay gave up on after OUTBOX_MAX_ATTEMPTS failures.
+-- They are no longer pending, so they cannot hold up the events behind them.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS dead_at TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(id) WHERE sent_at IS NULL AND dead_at IS NULL;